@@ -0,0 +1,73 @@
+package main
+
+import (
+	"awesomeProject/internal/api"
+	"awesomeProject/internal/auth"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// newAuthenticator собирает аутентификацию из окружения: API_KEYS ("key:subject:role,..."),
+// JWT_HMAC_SECRET и/или JWT_JWKS_FILE. Если ничего не задано, API остаётся открытым.
+func newAuthenticator(logger *slog.Logger) (auth.Authenticator, error) {
+	var list []auth.Authenticator
+	if spec := os.Getenv("API_KEYS"); spec != "" {
+		keys, err := auth.ParseAPIKeys(spec)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, keys)
+		logger.Info("api key authentication enabled", slog.Int("keys", keys.Len()))
+	}
+	secret, jwksFile := os.Getenv("JWT_HMAC_SECRET"), os.Getenv("JWT_JWKS_FILE")
+	if secret != "" || jwksFile != "" {
+		cfg := auth.JWTConfig{
+			HMACSecret: []byte(secret),
+			Issuer:     os.Getenv("JWT_ISSUER"),
+			Audience:   os.Getenv("JWT_AUDIENCE"),
+			RoleClaim:  os.Getenv("JWT_ROLE_CLAIM"),
+			Leeway:     getEnvDuration("JWT_LEEWAY", 30*time.Second),
+		}
+		if jwksFile != "" {
+			jwks, err := auth.LoadJWKS(jwksFile)
+			if err != nil {
+				return nil, err
+			}
+			cfg.JWKS = jwks
+		}
+		v, err := auth.NewJWTVerifier(cfg)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+		logger.Info("jwt authentication enabled", slog.Bool("hmac", secret != ""), slog.String("jwks", jwksFile))
+	}
+	if len(list) == 0 {
+		logger.Warn("authentication is disabled: set API_KEYS, JWT_HMAC_SECRET or JWT_JWKS_FILE")
+		return nil, nil
+	}
+	return auth.Chain(list...), nil
+}
+
+// piiPolicy читает PII_SCOPE, PII_ROLES (роли через запятую, видящие PII без scope)
+// и PII_MASK_ANONYMOUS.
+func piiPolicy() (api.PIIPolicy, error) {
+	p := api.PIIPolicy{
+		Scope:         getEnv("PII_SCOPE", "pii"),
+		MaskAnonymous: getEnv("PII_MASK_ANONYMOUS", "false") == "true",
+	}
+	for _, name := range strings.Split(getEnv("PII_ROLES", "admin"), ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		role, err := auth.ParseRole(name)
+		if err != nil {
+			return p, fmt.Errorf("PII_ROLES: %w", err)
+		}
+		p.Roles = append(p.Roles, role)
+	}
+	return p, nil
+}
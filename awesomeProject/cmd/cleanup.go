@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+type idempotencyPurger interface {
+	PurgeIdempotencyKeys(ctx context.Context, olderThan time.Time) (int64, error)
+}
+
+// purgeIdempotencyKeys раз в interval удаляет ключи идемпотентности старше ttl, пока не отменён
+// ctx, — так же, как Relay чистит опубликованные события outbox.
+func purgeIdempotencyKeys(ctx context.Context, store idempotencyPurger, ttl, interval time.Duration,
+	logger *slog.Logger) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		n, err := store.PurgeIdempotencyKeys(ctx, time.Now().Add(-ttl))
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Error("idempotency keys cleanup failed", slog.Any("err", err))
+			}
+			continue
+		}
+		if n > 0 {
+			logger.Info("idempotency keys cleaned up", slog.Int64("deleted", n))
+		}
+	}
+}
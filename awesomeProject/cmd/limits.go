@@ -0,0 +1,51 @@
+package main
+
+import (
+	"awesomeProject/internal/api"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// limitConfig читает лимиты API: RATE_LIMIT_READ, RATE_LIMIT_WRITE, RATE_LIMIT_BULK в виде
+// "запросов_в_секунду[:всплеск]" на клиента ("0" выключает), RATE_LIMIT_IP в том же виде на IP
+// до аутентификации, MAX_IN_FLIGHT и IN_FLIGHT_QUEUE_TIMEOUT.
+func limitConfig() (api.LimitConfig, error) {
+	cfg := api.LimitConfig{
+		Routes:            make(map[string]api.RateLimit),
+		MaxInFlight:       getEnvInt("MAX_IN_FLIGHT", 64),
+		QueueTimeout:      getEnvDuration("IN_FLIGHT_QUEUE_TIMEOUT", 100*time.Millisecond),
+		TrustForwardedFor: getEnv("TRUST_FORWARDED_FOR", "false") == "true",
+	}
+	defaults := map[string]string{api.RouteRead: "50:100", api.RouteWrite: "10:20", api.RouteBulk: "1:2"}
+	for class, def := range defaults {
+		env := "RATE_LIMIT_" + strings.ToUpper(class)
+		lim, err := parseRateLimit(getEnv(env, def))
+		if err != nil {
+			return cfg, fmt.Errorf("%s: %w", env, err)
+		}
+		cfg.Routes[class] = lim
+	}
+	lim, err := parseRateLimit(getEnv("RATE_LIMIT_IP", "100:200"))
+	if err != nil {
+		return cfg, fmt.Errorf("RATE_LIMIT_IP: %w", err)
+	}
+	cfg.PerIP = lim
+	return cfg, nil
+}
+
+func parseRateLimit(s string) (api.RateLimit, error) {
+	rate, burst, hasBurst := strings.Cut(strings.TrimSpace(s), ":")
+	r, err := strconv.ParseFloat(rate, 64)
+	if err != nil || r < 0 {
+		return api.RateLimit{}, fmt.Errorf("invalid rate %q, want rate[:burst]", s)
+	}
+	lim := api.RateLimit{Rate: r}
+	if hasBurst {
+		if lim.Burst, err = strconv.Atoi(burst); err != nil || lim.Burst < 1 {
+			return api.RateLimit{}, fmt.Errorf("invalid burst %q", burst)
+		}
+	}
+	return lim, nil
+}
@@ -3,35 +3,71 @@ package main
 import (
 	"awesomeProject/kafka"
 	"context"
+	"errors"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"awesomeProject/internal/api"
+	"awesomeProject/internal/auth"
 	"awesomeProject/internal/cache"
-	"awesomeProject/internal/db"
-	"awesomeProject/internal/repository"
+	"awesomeProject/internal/envelope"
+	"awesomeProject/internal/lifecycle"
+	"awesomeProject/internal/money"
+	"awesomeProject/internal/outbox"
 	"awesomeProject/internal/service"
 )
 
 func main() {
-	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
-	sqlDB, err := db.InitDB(logger)
+	logger := slog.New(auth.LogHandler(slog.NewTextHandler(os.Stdout, nil)))
+	authn, err := newAuthenticator(logger)
+	if err != nil {
+		logger.Error("auth config invalid", slog.Any("err", err))
+		os.Exit(1)
+	}
+	piiPol, err := piiPolicy()
+	if err != nil {
+		logger.Error("pii policy invalid", slog.Any("err", err))
+		os.Exit(1)
+	}
+	limits, err := limitConfig()
+	if err != nil {
+		logger.Error("rate limits invalid", slog.Any("err", err))
+		os.Exit(1)
+	}
+	var rates *money.Rates
+	if path := getEnv("EXCHANGE_RATES_FILE", ""); path != "" {
+		if rates, err = money.LoadRates(path); err != nil {
+			logger.Error("exchange rates invalid", slog.Any("err", err))
+			os.Exit(1)
+		}
+		logger.Info("exchange rates loaded", slog.String("base", rates.Base()))
+	}
+	keyring, err := envelope.FromEnv()
+	if err != nil {
+		logger.Error("encryption keys invalid", slog.Any("err", err))
+		os.Exit(1)
+	}
+	if keyring != nil {
+		logger.Info("delivery PII encryption enabled", slog.String("primary_key", keyring.Primary()))
+	}
+	store, err := openStorage(keyring, logger)
 	if err != nil {
 		logger.Error("db init failed", slog.Any("err", err))
 		os.Exit(1)
 	}
-	defer sqlDB.Close()
-	repo := repository.NewRepository(sqlDB)
 	redisCache, err := cache.NewCache(logger)
 	if err != nil {
 		logger.Error("redis init failed", slog.Any("err", err))
 		os.Exit(1)
 	}
-	svc := service.NewService(repo, redisCache, logger)
+	redisCache.WithKeyring(keyring)
+	svc := service.NewService(store.orders, redisCache, logger)
 	{
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		if err := svc.Warmup(ctx); err != nil {
@@ -39,17 +75,78 @@ func main() {
 		}
 		cancel()
 	}
-	consumer := kafka.NewConsumer(svc, logger)
+	var offsets kafka.OffsetStore
+	if getEnv("KAFKA_OFFSETS_IN_DB", "false") == "true" {
+		offsets = store.orders
+	}
+	consumer, err := kafka.NewConsumer(svc, offsets, logger)
+	if err != nil {
+		logger.Error("kafka consumer config invalid", slog.Any("err", err))
+		os.Exit(1)
+	}
+	if topic := getEnv("KAFKA_DLQ_TOPIC", ""); topic != "" {
+		dlq := kafka.NewWriter(topic)
+		defer dlq.Close()
+		consumer.WithDeadLetter(dlq)
+		logger.Info("kafka dead letter queue enabled", slog.String("topic", topic))
+	}
 	kctx, kcancel := context.WithCancel(context.Background())
 	go func() {
 		if err := consumer.Run(kctx); err != nil {
 			logger.Error("kafka consumer stopped with error", slog.Any("err", err))
 		}
 	}()
-	srv := &http.Server{
-		Addr:    ":" + getEnv("HTTP_PORT", "8081"),
-		Handler: buildMux(svc),
+	events := kafka.NewWriter(getEnv("OUTBOX_TOPIC", "order-events"))
+	relays := make([]*outbox.Relay, len(store.shards))
+	rctx, rcancel := context.WithCancel(context.Background())
+	for i, shard := range store.shards {
+		relays[i] = outbox.NewRelay(shard, events, outbox.Config{
+			BatchSize:       getEnvInt("OUTBOX_BATCH_SIZE", 100),
+			PollInterval:    getEnvDuration("OUTBOX_POLL_INTERVAL", time.Second),
+			Retention:       getEnvDuration("OUTBOX_RETENTION", 24*time.Hour),
+			CleanupInterval: getEnvDuration("OUTBOX_CLEANUP_INTERVAL", 10*time.Minute),
+		}, logger)
+		go func() {
+			if err := relays[i].Run(rctx); err != nil {
+				logger.Error("outbox relay stopped with error", slog.Any("err", err))
+			}
+		}()
 	}
+	idempotencyTTL := getEnvDuration("IDEMPOTENCY_TTL", 24*time.Hour)
+	ictx, icancel := context.WithCancel(context.Background())
+	purged := make(chan struct{})
+	go func() {
+		defer close(purged)
+		purgeIdempotencyKeys(ictx, store.primary, idempotencyTTL,
+			getEnvDuration("IDEMPOTENCY_CLEANUP_INTERVAL", 10*time.Minute), logger)
+	}()
+	router := api.NewRouter(svc, api.Config{
+		WebDir:         getEnv("WEB_DIR", "./web"),
+		Idempotency:    store.primary,
+		IdempotencyTTL: idempotencyTTL,
+		BulkMaxBytes:   int64(getEnvInt("BULK_MAX_BYTES", 10<<20)),
+		BulkBatchSize:  getEnvInt("BULK_BATCH_SIZE", 100),
+		MaxBodyBytes:   int64(getEnvInt("MAX_BODY_BYTES", 1<<20)),
+		StrictJSON:     getEnv("STRICT_JSON", "false") == "true",
+		Auth:           authn,
+		PII:            piiPol,
+		Limits:         limits,
+		Logger:         logger,
+		Reports:        service.NewReports(store.orders, rates),
+		CORS: api.CORSConfig{
+			AllowedOrigins:   splitList(getEnv("CORS_ALLOWED_ORIGINS", "")),
+			AllowCredentials: getEnv("CORS_ALLOW_CREDENTIALS", "false") == "true",
+			MaxAge:           getEnvDuration("CORS_MAX_AGE", 10*time.Minute),
+		},
+		Gzip: getEnv("HTTP_GZIP", "true") == "true",
+	})
+	srv := api.NewServer(":"+getEnv("HTTP_PORT", "8081"), router, api.ServerConfig{
+		ReadHeaderTimeout: getEnvDuration("HTTP_READ_HEADER_TIMEOUT", 5*time.Second),
+		ReadTimeout:       getEnvDuration("HTTP_READ_TIMEOUT", 30*time.Second),
+		WriteTimeout:      getEnvDuration("HTTP_WRITE_TIMEOUT", 60*time.Second),
+		IdleTimeout:       getEnvDuration("HTTP_IDLE_TIMEOUT", 120*time.Second),
+		MaxHeaderBytes:    getEnvInt("HTTP_MAX_HEADER_BYTES", 64<<10),
+	}, logger)
 	go func() {
 		logger.Info("http server listening", slog.String("addr", srv.Addr))
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -60,19 +157,61 @@ func main() {
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 	<-sigCh
 	logger.Info("shutting down...")
+	go func() {
+		<-sigCh
+		logger.Warn("second signal received, exiting without graceful shutdown")
+		os.Exit(1)
+	}()
+	// Сначала перестаём принимать работу и дожидаемся текущей: HTTP-запросов и сообщений Kafka
+	// вместе с коммитом их offset'ов. Потом публикуем оставшиеся события outbox и только после
+	// этого закрываем Redis, а затем Postgres, на которые всё это опирается.
+	err = lifecycle.New(logger, getEnvDuration("SHUTDOWN_TIMEOUT", 25*time.Second)).
+		Phase("drain",
+			lifecycle.Hook{Name: "http", Stop: func(ctx context.Context) error {
+				if err := srv.Shutdown(ctx); err != nil {
+					_ = srv.Close()
+					return err
+				}
+				return nil
+			}},
+			lifecycle.Hook{Name: "kafka", Stop: func(ctx context.Context) error {
+				if err := consumer.Shutdown(ctx); err != nil {
+					kcancel()
+					return err
+				}
+				return nil
+			}},
+			lifecycle.Hook{Name: "idempotency-cleanup", Stop: func(ctx context.Context) error {
+				icancel()
+				select {
+				case <-purged:
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}},
+		).
+		// Отправляем события, записанные последними запросами и сообщениями, пока БД открыта.
+		Phase("outbox", lifecycle.Hook{Name: "relay", Stop: func(ctx context.Context) error {
+			defer events.Close()
+			var errs []error
+			for _, relay := range relays {
+				errs = append(errs, relay.Shutdown(ctx))
+			}
+			if err := errors.Join(errs...); err != nil {
+				rcancel()
+				return err
+			}
+			return nil
+		}}).
+		Phase("cache", lifecycle.Hook{Name: "redis", Stop: func(context.Context) error { return redisCache.Close() }}).
+		Phase("storage", lifecycle.Hook{Name: "postgres", Stop: func(context.Context) error { return store.Close() }}).
+		Shutdown(context.Background())
 	kcancel()
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	_ = srv.Shutdown(ctx)
-	cancel()
-}
-
-func buildMux(svc *service.Service) http.Handler {
-	mux := http.NewServeMux()
-	mux.HandleFunc("/order/", api.HandlerGet(svc))
-	mux.HandleFunc("/order", api.HandlerPost(svc))
-	webDir := getEnv("WEB_DIR", "./web")
-	mux.Handle("/", http.FileServer(http.Dir(webDir)))
-	return mux
+	rcancel()
+	if err != nil {
+		os.Exit(1)
+	}
 }
 
 func getEnv(k, def string) string {
@@ -81,3 +220,31 @@ func getEnv(k, def string) string {
 	}
 	return def
 }
+
+func getEnvInt(k string, def int) int {
+	if v := os.Getenv(k); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return def
+}
+
+func getEnvDuration(k string, def time.Duration) time.Duration {
+	if v := os.Getenv(k); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return def
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
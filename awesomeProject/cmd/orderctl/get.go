@@ -0,0 +1,93 @@
+package main
+
+import (
+	"awesomeProject/internal/db"
+	"awesomeProject/internal/envelope"
+	"awesomeProject/internal/model"
+	"awesomeProject/internal/repository"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+func runGet(args []string, logger *slog.Logger) error {
+	fs := flag.NewFlagSet("get", flag.ExitOnError)
+	fromDB := fs.Bool("db", false, "read straight from Postgres (DB_* env) instead of the HTTP API")
+	apiURL := fs.String("api", getEnv("API_URL", "http://localhost:8081"), "service base URL")
+	timeout := fs.Duration("timeout", 5*time.Second, "request timeout")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("usage: orderctl get [-db] <order_uid>")
+	}
+	id := fs.Arg(0)
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	var data []byte
+	if *fromDB {
+		order, err := getFromDB(ctx, id, logger)
+		if err != nil {
+			return err
+		}
+		if data, err = json.Marshal(order); err != nil {
+			return err
+		}
+	} else {
+		endpoint := strings.TrimRight(*apiURL, "/") + "/api/v1/orders/" + url.PathEscape(id)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return err
+		}
+		authorize(req)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if data, err = io.ReadAll(resp.Body); err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(data)))
+		}
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, data, "", "  "); err != nil {
+		return err
+	}
+	out.WriteByte('\n')
+	_, err := out.WriteTo(os.Stdout)
+	return err
+}
+
+// getFromDB читает заказ из Postgres: со всех шардов SHARD_MAP_FILE или из БД DB_*.
+func getFromDB(ctx context.Context, id string, logger *slog.Logger) (model.Order, error) {
+	keyring, err := envelope.FromEnv()
+	if err != nil {
+		return model.Order{}, err
+	}
+	if getEnv("SHARD_MAP_FILE", "") != "" {
+		sharded, closeShards, err := openShards(keyring, logger)
+		if err != nil {
+			return model.Order{}, err
+		}
+		defer closeShards()
+		return sharded.GetOrderById(ctx, id)
+	}
+	sqlDB, err := db.InitDB(logger)
+	if err != nil {
+		return model.Order{}, err
+	}
+	defer sqlDB.Close()
+	return repository.NewRepository(sqlDB).WithKeyring(keyring).GetOrderById(ctx, id)
+}
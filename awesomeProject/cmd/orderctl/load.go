@@ -0,0 +1,59 @@
+package main
+
+import (
+	"awesomeProject/internal/loadtest"
+	"context"
+	"encoding/json"
+	"flag"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func runLoad(args []string, logger *slog.Logger) error {
+	fs := flag.NewFlagSet("load", flag.ExitOnError)
+	var cfg loadtest.Config
+	var local loadtest.LocalConfig
+	fs.DurationVar(&cfg.Duration, "duration", 10*time.Second, "test duration")
+	fs.IntVar(&cfg.ProduceRate, "rate", 200, "orders produced per second")
+	fs.IntVar(&cfg.ReadRate, "reads", 1000, "GET /order requests per second")
+	fs.IntVar(&cfg.Readers, "readers", 8, "concurrent readers")
+	fs.Float64Var(&cfg.MissRatio, "miss", 0.05, "fraction of reads for unknown order ids")
+	fs.DurationVar(&cfg.ReportInterval, "interval", time.Second, "reporting interval")
+	fs.Uint64Var(&cfg.Seed, "seed", 1, "generator seed")
+	fs.DurationVar(&local.DBLatency, "db-latency", 2*time.Millisecond, "simulated database latency")
+	fs.IntVar(&local.Consumers, "consumers", 1, "consumer goroutines")
+	fs.BoolVar(&local.ColdCache, "cold", false, "never populate the cache")
+	jsonOut := fs.Bool("json", false, "print the final report as JSON")
+	_ = fs.Parse(args)
+
+	env := loadtest.NewLocal(local, logger)
+	defer env.Close()
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	rep, err := loadtest.Run(ctx, cfg, env, logger)
+	if err != nil {
+		return err
+	}
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rep)
+	}
+	logger.Info("loadtest finished",
+		slog.Duration("duration", rep.Duration),
+		slog.Int64("produced", rep.Produced),
+		slog.Int64("ingested", rep.Ingested),
+		slog.Int64("final_lag", rep.FinalLag),
+		slog.Float64("ingest_per_sec", rep.IngestPerSec),
+		slog.Float64("reads_per_sec", rep.ReadsPerSec),
+		slog.Float64("cache_hit_ratio", rep.CacheHitRatio),
+		slog.Duration("read_p50", rep.ReadLatency.P50),
+		slog.Duration("read_p95", rep.ReadLatency.P95),
+		slog.Duration("read_p99", rep.ReadLatency.P99),
+		slog.Duration("ingest_p99", rep.IngestLatency.P99),
+	)
+	return nil
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"awesomeProject/internal/generator"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const usage = `orderctl - publish, replay and inspect orders
+
+Usage:
+  orderctl produce [-fake N [-seed S]] [-format json|protobuf|avro] [file ...]
+                                                    publish orders to KAFKA_TOPIC
+  orderctl post    [-fake N [-seed S]] [file ...]   POST orders to the HTTP API
+  orderctl get     [-db] <order_uid>                fetch and pretty-print an order
+  orderctl replay  (-topic T | -file F)             replay a topic or JSONL file into KAFKA_TOPIC
+  orderctl load    [-rate R] [-reads R] [-cold]   load-test local stand-ins of the service
+  orderctl reencrypt [-batch N] [-redis]          re-encrypt delivery PII with ENCRYPTION_PRIMARY_KEY
+  orderctl rebalance [-batch N] [-dry-run]        move orders to their shards per SHARD_MAP_FILE
+
+Files may contain a single JSON object, a JSON array or JSONL.
+Use "-" to read from stdin.
+HTTP commands authenticate with API_KEY or API_TOKEN (JWT) when set.
+Database commands use every shard of SHARD_MAP_FILE when it is set, DB_* otherwise.
+`
+
+func main() {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	var err error
+	args := os.Args[2:]
+	switch os.Args[1] {
+	case "produce":
+		err = runProduce(args, logger)
+	case "post":
+		err = runPost(args, logger)
+	case "get":
+		err = runGet(args, logger)
+	case "replay":
+		err = runReplay(args, logger)
+	case "load":
+		err = runLoad(args, logger)
+	case "reencrypt":
+		err = runReencrypt(args, logger)
+	case "rebalance":
+		err = runRebalance(args, logger)
+	case "help", "-h", "--help":
+		fmt.Fprint(os.Stdout, usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		logger.Error(os.Args[1]+" failed", slog.Any("err", err))
+		os.Exit(1)
+	}
+}
+
+type rawOrder struct {
+	uid  string
+	data json.RawMessage
+}
+
+func readOrderFiles(paths []string) ([]rawOrder, error) {
+	var out []rawOrder
+	for _, p := range paths {
+		var (
+			data []byte
+			err  error
+		)
+		if p == "-" {
+			data, err = io.ReadAll(bufio.NewReader(os.Stdin))
+		} else {
+			data, err = os.ReadFile(p)
+		}
+		if err != nil {
+			return nil, err
+		}
+		list, err := parseOrders(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		out = append(out, list...)
+	}
+	return out, nil
+}
+
+func parseOrders(data []byte) ([]rawOrder, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var raws []json.RawMessage
+	if data[0] == '[' {
+		if err := json.Unmarshal(data, &raws); err != nil {
+			return nil, err
+		}
+	} else {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		for {
+			var raw json.RawMessage
+			err := dec.Decode(&raw)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			raws = append(raws, raw)
+		}
+	}
+	out := make([]rawOrder, 0, len(raws))
+	for i, raw := range raws {
+		var head struct {
+			OrderUID string `json:"order_uid"`
+		}
+		if err := json.Unmarshal(raw, &head); err != nil {
+			return nil, fmt.Errorf("order #%d: %w", i, err)
+		}
+		if head.OrderUID == "" {
+			return nil, fmt.Errorf("order #%d: order_uid is required", i)
+		}
+		out = append(out, rawOrder{uid: head.OrderUID, data: raw})
+	}
+	return out, nil
+}
+
+func fakeOrders(n int, seed uint64) ([]rawOrder, error) {
+	if seed == 0 {
+		seed = uint64(time.Now().UnixNano())
+	}
+	out := make([]rawOrder, 0, n)
+	for _, o := range generator.New(seed).Orders(n) {
+		data, err := json.Marshal(o)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rawOrder{uid: o.OrderUID, data: data})
+	}
+	return out, nil
+}
+
+func collectOrders(fake int, seed uint64, files []string) ([]rawOrder, error) {
+	if fake <= 0 && len(files) == 0 {
+		return nil, errors.New("nothing to send: pass files or -fake N")
+	}
+	list, err := readOrderFiles(files)
+	if err != nil {
+		return nil, err
+	}
+	if fake > 0 {
+		generated, err := fakeOrders(fake, seed)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, generated...)
+	}
+	return list, nil
+}
+
+func getEnv(k, def string) string {
+	if v := os.Getenv(k); v != "" {
+		return v
+	}
+	return def
+}
+
+func splitAndTrim(s string) []string {
+	parts := strings.Split(s, ",")
+	out := make([]string, 0, len(parts))
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// authorize добавляет учётные данные из API_KEY или API_TOKEN к запросу к HTTP API.
+func authorize(req *http.Request) {
+	if key := os.Getenv("API_KEY"); key != "" {
+		req.Header.Set("X-API-Key", key)
+	} else if token := os.Getenv("API_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"awesomeProject/internal/model"
+	orders "awesomeProject/kafka"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func newWriter(topic string) *kafka.Writer {
+	brokers := splitAndTrim(getEnv("KAFKA_BROKERS", "localhost:9092"))
+	return &kafka.Writer{
+		Addr:                   kafka.TCP(brokers...),
+		Topic:                  topic,
+		Balancer:               &kafka.Hash{},
+		RequiredAcks:           kafka.RequireAll,
+		AllowAutoTopicCreation: true,
+		BatchTimeout:           50 * time.Millisecond,
+	}
+}
+
+func publish(ctx context.Context, w *kafka.Writer, list []rawOrder, headers ...kafka.Header) error {
+	msgs := make([]kafka.Message, 0, len(list))
+	for _, o := range list {
+		msgs = append(msgs, kafka.Message{Key: []byte(o.uid), Value: o.data, Headers: headers})
+	}
+	return w.WriteMessages(ctx, msgs...)
+}
+
+func runProduce(args []string, logger *slog.Logger) error {
+	fs := flag.NewFlagSet("produce", flag.ExitOnError)
+	fake := fs.Int("fake", 0, "number of generated orders to publish")
+	seed := fs.Uint64("seed", 0, "generator seed for reproducible fake orders (0 = random)")
+	topic := fs.String("topic", getEnv("KAFKA_TOPIC", "orders"), "target topic")
+	timeout := fs.Duration("timeout", 30*time.Second, "overall timeout")
+	format := fs.String("format", orders.FormatJSON, "message format: json, protobuf or avro")
+	schemaID := fs.Int("schema-id", orders.SchemaVersion, "avro schema id in the registry (KAFKA_SCHEMA_REGISTRY_FILE)")
+	_ = fs.Parse(args)
+
+	list, err := collectOrders(*fake, *seed, fs.Args())
+	if err != nil {
+		return err
+	}
+	headers, err := encodeOrders(list, *format, *schemaID)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	w := newWriter(*topic)
+	defer w.Close()
+	if err := publish(ctx, w, list, headers...); err != nil {
+		return err
+	}
+	for _, o := range list {
+		fmt.Println(o.uid)
+	}
+	logger.Info("orders published", slog.String("topic", *topic), slog.Int("count", len(list)))
+	return nil
+}
+
+// encodeOrders перекодирует JSON заказов в format и возвращает заголовки, которые его описывают.
+func encodeOrders(list []rawOrder, format string, schemaID int) ([]kafka.Header, error) {
+	var encode func(model.Order) ([]byte, error)
+	switch format {
+	case orders.FormatJSON:
+		return nil, nil
+	case orders.FormatProtobuf:
+		encode = orders.EncodeProtobuf
+	case orders.FormatAvro:
+		registry, err := orders.LoadRegistry(getEnv("KAFKA_SCHEMA_REGISTRY_FILE", ""))
+		if err != nil {
+			return nil, err
+		}
+		encode = func(o model.Order) ([]byte, error) { return registry.EncodeAvro(schemaID, o) }
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	for i, o := range list {
+		var order model.Order
+		if err := json.Unmarshal(o.data, &order); err != nil {
+			return nil, fmt.Errorf("%s: %w", o.uid, err)
+		}
+		data, err := encode(order)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", o.uid, err)
+		}
+		list[i].data = data
+	}
+	return []kafka.Header{
+		{Key: orders.HeaderContentType, Value: []byte(format)},
+		{Key: orders.HeaderSchemaVersion, Value: []byte(strconv.Itoa(orders.SchemaVersion))},
+	}, nil
+}
+
+func runPost(args []string, logger *slog.Logger) error {
+	fs := flag.NewFlagSet("post", flag.ExitOnError)
+	fake := fs.Int("fake", 0, "number of generated orders to post")
+	seed := fs.Uint64("seed", 0, "generator seed for reproducible fake orders (0 = random)")
+	apiURL := fs.String("api", getEnv("API_URL", "http://localhost:8081"), "service base URL")
+	timeout := fs.Duration("timeout", 5*time.Second, "per-request timeout")
+	_ = fs.Parse(args)
+
+	list, err := collectOrders(*fake, *seed, fs.Args())
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: *timeout}
+	endpoint := strings.TrimRight(*apiURL, "/") + "/api/v1/orders"
+	failed := 0
+	for _, o := range list {
+		req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(o.data))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		authorize(req)
+		resp, err := client.Do(req)
+		if err != nil {
+			logger.Error("post failed", slog.String("order_uid", o.uid), slog.Any("err", err))
+			failed++
+			continue
+		}
+		body, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			logger.Error("post rejected", slog.String("order_uid", o.uid),
+				slog.Int("status", resp.StatusCode), slog.String("body", strings.TrimSpace(string(body))))
+			failed++
+			continue
+		}
+		fmt.Println(o.uid)
+	}
+	logger.Info("orders posted", slog.Int("count", len(list)-failed), slog.Int("failed", failed))
+	if failed > 0 {
+		return fmt.Errorf("%d of %d orders failed", failed, len(list))
+	}
+	return nil
+}
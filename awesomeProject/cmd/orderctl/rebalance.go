@@ -0,0 +1,61 @@
+package main
+
+import (
+	"awesomeProject/internal/db"
+	"awesomeProject/internal/envelope"
+	"awesomeProject/internal/repository"
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+)
+
+// runRebalance переносит заказы на шарды из карты SHARD_MAP_FILE после её изменения или смены
+// shardkey у заказов. С -dry-run только считает, сколько заказов куда переедет.
+func runRebalance(args []string, logger *slog.Logger) error {
+	fs := flag.NewFlagSet("rebalance", flag.ExitOnError)
+	batch := fs.Int("batch", 500, "orders listed per query")
+	dryRun := fs.Bool("dry-run", false, "count misplaced orders without moving them")
+	_ = fs.Parse(args)
+
+	sharded, closeShards, err := openShards(nil, logger)
+	if err != nil {
+		return err
+	}
+	defer closeShards()
+	res, err := sharded.Rebalance(context.Background(), *batch, *dryRun)
+	logger.Info("rebalance finished", slog.Bool("dry_run", *dryRun), slog.Int("checked", res.Checked),
+		slog.Any("moved", res.Moved))
+	return err
+}
+
+// openShards подключается к шардам из SHARD_MAP_FILE.
+func openShards(keyring *envelope.Keyring, logger *slog.Logger) (*repository.Sharded, func(), error) {
+	path := getEnv("SHARD_MAP_FILE", "")
+	if path == "" {
+		return nil, nil, errors.New("set SHARD_MAP_FILE")
+	}
+	shardMap, err := repository.LoadShardMap(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	conns, err := db.OpenShards(shardMap.Shards, logger)
+	if err != nil {
+		return nil, nil, err
+	}
+	closeAll := func() {
+		for _, c := range conns {
+			_ = c.Close()
+		}
+	}
+	repos := make(map[string]*repository.Repository, len(conns))
+	for name, c := range conns {
+		repos[name] = repository.NewRepository(c).WithKeyring(keyring)
+	}
+	sharded, err := repository.NewSharded(shardMap, repos)
+	if err != nil {
+		closeAll()
+		return nil, nil, err
+	}
+	return sharded, closeAll, nil
+}
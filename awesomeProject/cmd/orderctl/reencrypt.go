@@ -0,0 +1,76 @@
+package main
+
+import (
+	"awesomeProject/internal/cache"
+	"awesomeProject/internal/db"
+	"awesomeProject/internal/envelope"
+	"awesomeProject/internal/repository"
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+)
+
+// runReencrypt переводит персональные данные доставки, в том числе в журнале изменений, на основной
+// ключ после ротации: новый ключ добавляется в набор и делается основным, старый остаётся для
+// чтения до конца прохода.
+func runReencrypt(args []string, logger *slog.Logger) error {
+	fs := flag.NewFlagSet("reencrypt", flag.ExitOnError)
+	batch := fs.Int("batch", 500, "rows per transaction")
+	withRedis := fs.Bool("redis", false, "also re-encrypt cached orders in Redis (REDIS_* env)")
+	_ = fs.Parse(args)
+
+	keyring, err := envelope.FromEnv()
+	if err != nil {
+		return err
+	}
+	if keyring == nil {
+		return errors.New("set ENCRYPTION_KEYS or ENCRYPTION_KEY_FILE")
+	}
+	repos := map[string]*repository.Repository{}
+	if getEnv("SHARD_MAP_FILE", "") != "" {
+		sharded, closeShards, err := openShards(keyring, logger)
+		if err != nil {
+			return err
+		}
+		defer closeShards()
+		for _, name := range sharded.Names() {
+			repos[name] = sharded.Shard(name)
+		}
+	} else {
+		sqlDB, err := db.InitDB(logger)
+		if err != nil {
+			return err
+		}
+		defer sqlDB.Close()
+		repos[""] = repository.NewRepository(sqlDB).WithKeyring(keyring)
+	}
+
+	ctx := context.Background()
+	for name, repo := range repos {
+		log := logger
+		if name != "" {
+			log = logger.With(slog.String("shard", name))
+		}
+		n, err := repo.ReencryptDeliveries(ctx, *batch)
+		log.Info("postgres re-encrypted", slog.String("primary_key", keyring.Primary()), slog.Int("rows", n))
+		if err != nil {
+			return err
+		}
+		n, err = repo.ReencryptAudit(ctx, *batch)
+		log.Info("audit log re-encrypted", slog.Int("records", n))
+		if err != nil {
+			return err
+		}
+	}
+	if *withRedis {
+		c, err := cache.NewCache(logger)
+		if err != nil {
+			return err
+		}
+		n, err := c.WithKeyring(keyring).Reencrypt(ctx)
+		logger.Info("redis re-encrypted", slog.Int("keys", n))
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func runReplay(args []string, logger *slog.Logger) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	source := fs.String("topic", "", "source topic to replay, e.g. a dead-letter topic")
+	file := fs.String("file", "", "JSONL file to replay")
+	target := fs.String("to", getEnv("KAFKA_TOPIC", "orders"), "target topic")
+	limit := fs.Int("max", 0, "stop after N messages (0 = until the source is drained)")
+	idle := fs.Duration("idle", 5*time.Second, "stop reading the source topic after this long without messages")
+	_ = fs.Parse(args)
+	if (*source == "") == (*file == "") {
+		return errors.New("exactly one of -topic or -file is required")
+	}
+
+	w := newWriter(*target)
+	defer w.Close()
+
+	if *file != "" {
+		list, err := readOrderFiles([]string{*file})
+		if err != nil {
+			return err
+		}
+		if *limit > 0 && len(list) > *limit {
+			list = list[:*limit]
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := publish(ctx, w, list); err != nil {
+			return err
+		}
+		logger.Info("file replayed", slog.String("file", *file), slog.String("to", *target), slog.Int("count", len(list)))
+		return nil
+	}
+
+	brokers := splitAndTrim(getEnv("KAFKA_BROKERS", "localhost:9092"))
+	r := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     brokers,
+		Topic:       *source,
+		GroupID:     fmt.Sprintf("orderctl-replay-%d", time.Now().UnixNano()),
+		StartOffset: kafka.FirstOffset,
+		MinBytes:    1,
+		MaxBytes:    10 << 20,
+	})
+	defer r.Close()
+
+	count := 0
+	for *limit == 0 || count < *limit {
+		ctx, cancel := context.WithTimeout(context.Background(), *idle)
+		m, err := r.FetchMessage(ctx)
+		cancel()
+		if errors.Is(err, context.DeadlineExceeded) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		wctx, wcancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err = w.WriteMessages(wctx, kafka.Message{Key: m.Key, Value: m.Value, Headers: m.Headers})
+		wcancel()
+		if err != nil {
+			return fmt.Errorf("partition %d offset %d: %w", m.Partition, m.Offset, err)
+		}
+		count++
+	}
+	logger.Info("topic replayed", slog.String("from", *source), slog.String("to", *target), slog.Int("count", count))
+	return nil
+}
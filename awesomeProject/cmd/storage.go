@@ -0,0 +1,74 @@
+package main
+
+import (
+	"awesomeProject/internal/db"
+	"awesomeProject/internal/envelope"
+	"awesomeProject/internal/repository"
+	"awesomeProject/internal/service"
+	"awesomeProject/kafka"
+	"database/sql"
+	"errors"
+	"log/slog"
+)
+
+// orderStore — хранилище заказов сервиса: одна БД (*repository.Repository) или шарды
+// (*repository.Sharded).
+type orderStore interface {
+	service.Repository
+	service.ReportStore
+	kafka.OffsetStore
+}
+
+type storage struct {
+	orders orderStore
+	// primary хранит ключи идемпотентности; при шардировании — шард по умолчанию.
+	primary *repository.Repository
+	// shards — репозитории всех БД: у каждой свой outbox, который нужно публиковать.
+	shards []*repository.Repository
+	dbs    []*sql.DB
+}
+
+// openStorage подключается к одной БД из DB_* или, если задан SHARD_MAP_FILE, ко всем шардам
+// карты.
+func openStorage(keyring *envelope.Keyring, logger *slog.Logger) (*storage, error) {
+	path := getEnv("SHARD_MAP_FILE", "")
+	if path == "" {
+		sqlDB, err := db.InitDB(logger)
+		if err != nil {
+			return nil, err
+		}
+		repo := repository.NewRepository(sqlDB).WithKeyring(keyring)
+		return &storage{orders: repo, primary: repo, shards: []*repository.Repository{repo}, dbs: []*sql.DB{sqlDB}}, nil
+	}
+	shardMap, err := repository.LoadShardMap(path)
+	if err != nil {
+		return nil, err
+	}
+	conns, err := db.OpenShards(shardMap.Shards, logger)
+	if err != nil {
+		return nil, err
+	}
+	st := &storage{}
+	repos := make(map[string]*repository.Repository, len(conns))
+	for _, name := range shardMap.Names() {
+		repos[name] = repository.NewRepository(conns[name]).WithKeyring(keyring)
+		st.shards = append(st.shards, repos[name])
+		st.dbs = append(st.dbs, conns[name])
+	}
+	sharded, err := repository.NewSharded(shardMap, repos)
+	if err != nil {
+		_ = st.Close()
+		return nil, err
+	}
+	st.orders, st.primary = sharded, sharded.Primary()
+	logger.Info("order storage is sharded", slog.Any("shards", shardMap.Names()))
+	return st, nil
+}
+
+func (st *storage) Close() error {
+	var errs []error
+	for _, c := range st.dbs {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -1,59 +1,166 @@
 package api
 
 import (
+	"awesomeProject/internal/auth"
 	"awesomeProject/internal/model"
 	"awesomeProject/internal/service"
 	"context"
-	"encoding/json"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 )
 
-func HandlerGet(svc *service.Service) http.HandlerFunc {
+const defaultTimeout = 3 * time.Second
+
+type OrderService interface {
+	GetOrderByID(ctx context.Context, id string) (model.Order, error)
+	UpsertOrder(ctx context.Context, order model.Order) (bool, error)
+	ReplaceOrder(ctx context.Context, order model.Order) (bool, error)
+	UpsertMany(ctx context.Context, list []model.Order, batchSize int) []service.BulkResult
+	PatchOrder(ctx context.Context, id string, patch []byte) (model.Order, error)
+	DeleteOrder(ctx context.Context, id string) error
+	ChangeStatus(ctx context.Context, change model.StatusChange) (model.StatusChange, error)
+	StatusHistory(ctx context.Context, id string) (model.StatusHistory, error)
+	OrderHistory(ctx context.Context, id string) ([]model.AuditRecord, error)
+}
+
+type Config struct {
+	WebDir         string
+	Timeout        time.Duration
+	Idempotency    IdempotencyStore
+	IdempotencyTTL time.Duration
+	BulkMaxBytes   int64
+	BulkBatchSize  int
+	MaxBodyBytes   int64
+	StrictJSON     bool
+	// Auth включает аутентификацию: чтение требует роли reader, запись — writer, удаление — admin.
+	Auth   auth.Authenticator
+	PII    PIIPolicy
+	Limits LimitConfig
+	// Logger включает access-лог и логирование паник; без него паники пишутся в slog.Default.
+	Logger *slog.Logger
+	CORS   CORSConfig
+	Gzip   bool
+	// Reports включает отчёты /api/v1/reports/*; без него маршруты не регистрируются.
+	Reports ReportService
+}
+
+func (cfg Config) withDefaults() Config {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = defaultMaxBodyBytes
+	}
+	if cfg.BulkMaxBytes <= 0 {
+		cfg.BulkMaxBytes = defaultBulkMaxBytes
+	}
+	if cfg.BulkBatchSize <= 0 {
+		cfg.BulkBatchSize = defaultBulkBatchSize
+	}
+	if cfg.PII.Scope == "" {
+		cfg.PII.Scope = defaultPIIScope
+	}
+	if cfg.IdempotencyTTL <= 0 {
+		cfg.IdempotencyTTL = defaultIdempotencyTTL
+	}
+	return cfg
+}
+
+func NewRouter(svc OrderService, cfg Config) http.Handler {
+	cfg = cfg.withDefaults()
+	mux := http.NewServeMux()
+	g := newGuard(cfg)
+	post := g.route(auth.RoleWriter, RouteWrite, idempotent(cfg, handlerPost(svc, cfg)))
+	registerV1(mux, svc, cfg, g, post)
+	mux.HandleFunc("POST "+v1Prefix+"/orders/bulk", g.route(auth.RoleWriter, RouteBulk, handlerBulk(svc, cfg)))
+	mux.HandleFunc("/order/", deprecated(g.route(auth.RoleReader, RouteRead, handlerGet(svc, cfg))))
+	mux.HandleFunc("/order", deprecated(post))
+	if cfg.WebDir != "" {
+		mux.Handle("/", http.FileServer(http.Dir(cfg.WebDir)))
+	}
+	return Chain(mux, Middlewares(cfg)...)
+}
+
+func HandlerGet(svc OrderService) http.HandlerFunc {
+	return handlerGet(svc, Config{}.withDefaults())
+}
+
+func HandlerPost(svc OrderService) http.HandlerFunc {
+	return handlerPost(svc, Config{}.withDefaults())
+}
+
+func handlerGet(svc OrderService, cfg Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		id := strings.TrimPrefix(r.URL.Path, "/order/")
-		if id == "" || id == r.URL.Path {
-			http.Error(w, "use /order/{order_uid}", http.StatusBadRequest)
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			writeError(w, r, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+			return
+		}
+		id, ok := orderIDFromPath(r.URL)
+		if !ok {
+			writeError(w, r, http.StatusBadRequest, "bad_path", "use /order/{order_uid}")
 			return
 		}
-		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		ctx, cancel := context.WithTimeout(r.Context(), cfg.Timeout)
 		defer cancel()
 
 		order, err := svc.GetOrderByID(ctx, id)
 		if err != nil {
-			http.Error(w, "not found", http.StatusNotFound)
+			writeServiceError(w, r, err)
 			return
 		}
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		_ = json.NewEncoder(w).Encode(order)
+		writeOrder(w, r, cfg, http.StatusOK, order)
 	}
 }
 
-func HandlerPost(svc *service.Service) http.HandlerFunc {
+// orderIDFromPath достаёт order_uid из /order/{id}. Работает по экранированному пути,
+// чтобы %2F внутри id не превращался во вложенный путь; один завершающий слэш допускается.
+func orderIDFromPath(u *url.URL) (string, bool) {
+	rest, found := strings.CutPrefix(u.EscapedPath(), "/order/")
+	if !found {
+		return "", false
+	}
+	rest = strings.TrimSuffix(rest, "/")
+	if rest == "" || strings.Contains(rest, "/") {
+		return "", false
+	}
+	id, err := url.PathUnescape(rest)
+	if err != nil || strings.TrimSpace(id) == "" {
+		return "", false
+	}
+	return id, true
+}
+
+func handlerPost(svc OrderService, cfg Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			w.Header().Set("Allow", "POST")
+			writeError(w, r, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
 			return
 		}
-		defer r.Body.Close()
 		var order model.Order
-		if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
-			http.Error(w, "invalid json", http.StatusBadRequest)
+		if !decodeJSONBody(w, r, cfg, &order) {
 			return
 		}
-		if order.Order_uid == "" {
-			http.Error(w, "order_uid is required", http.StatusBadRequest)
+		if order.OrderUID == "" {
+			writeServiceError(w, r, &service.ValidationError{Field: "order_uid", Reason: "is required"})
 			return
 		}
-		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		ctx, cancel := context.WithTimeout(r.Context(), cfg.Timeout)
 		defer cancel()
-		if err := svc.UpsertOrder(ctx, order); err != nil {
-			http.Error(w, "failed to insert order", http.StatusInternalServerError)
+		created, err := svc.UpsertOrder(ctx, order)
+		if err != nil {
+			writeServiceError(w, r, err)
+			return
+		}
+		if !created {
+			writeError(w, r, http.StatusConflict, "order_exists",
+				"order "+order.OrderUID+" already exists, use PUT to replace it")
 			return
 		}
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		w.WriteHeader(http.StatusCreated)
-		_ = json.NewEncoder(w).Encode(map[string]any{"status": "ok", "order_uid": order.Order_uid})
+		writeJSON(w, http.StatusCreated, map[string]any{"status": "ok", "order_uid": order.OrderUID})
 	}
 }
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"awesomeProject/internal/auth"
+	"errors"
+	"net/http"
+)
+
+// require пропускает запрос, только если вызывающий аутентифицирован и его роль не ниже role.
+// Без cfg.Auth аутентификация выключена и запрос проходит как есть.
+func require(cfg Config, role auth.Role, next http.HandlerFunc) http.HandlerFunc {
+	if cfg.Auth == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := cfg.Auth.Authenticate(r)
+		if err != nil {
+			msg := "authentication required"
+			if !errors.Is(err, auth.ErrNoCredentials) {
+				msg = "invalid credentials"
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="orders", ApiKey`)
+			writeError(w, r, http.StatusUnauthorized, "unauthorized", msg)
+			return
+		}
+		if !id.Role.Allows(role) {
+			writeError(w, r, http.StatusForbidden, "forbidden", "role "+id.Role.String()+" cannot perform this action, "+
+				role.String()+" is required")
+			return
+		}
+		if info := requestInfoFrom(r.Context()); info != nil {
+			info.subject = id.Subject
+		}
+		next(w, r.WithContext(auth.WithIdentity(r.Context(), id)))
+	}
+}
@@ -0,0 +1,164 @@
+package api
+
+import (
+	"awesomeProject/internal/model"
+	"awesomeProject/internal/service"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+)
+
+const (
+	defaultBulkMaxBytes  = 10 << 20
+	defaultBulkBatchSize = 100
+)
+
+type bulkItem struct {
+	order model.Order
+	err   error
+}
+
+type bulkSummary struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Invalid int `json:"invalid"`
+	Failed  int `json:"failed"`
+}
+
+type bulkResponse struct {
+	Results []service.BulkResult `json:"results"`
+	Summary bulkSummary          `json:"summary"`
+}
+
+// handlerBulk принимает JSON-массив или NDJSON (Content-Type: application/x-ndjson) и отвечает
+// результатом по каждому заказу; некорректные заказы не прерывают обработку остальных.
+func handlerBulk(svc OrderService, cfg Config) http.HandlerFunc {
+	maxBytes, batchSize := cfg.BulkMaxBytes, cfg.BulkBatchSize
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		mediaType, ok := checkContentType(w, r, ndjsonTypes...)
+		if !ok {
+			return
+		}
+		body := http.MaxBytesReader(w, r.Body, maxBytes)
+
+		var (
+			items []bulkItem
+			err   error
+		)
+		if slices.Contains(ndjsonTypes, mediaType) {
+			items, err = readNDJSON(body, cfg.StrictJSON)
+		} else {
+			items, err = readJSONArray(body, cfg.StrictJSON)
+		}
+		if err != nil {
+			writeBodyError(w, r, err, maxBytes)
+			return
+		}
+		if len(items) == 0 {
+			writeError(w, r, http.StatusBadRequest, "empty_batch", "no orders in request body")
+			return
+		}
+
+		valid := make([]model.Order, 0, len(items))
+		for _, it := range items {
+			if it.err == nil {
+				valid = append(valid, it.order)
+			}
+		}
+		batches := (len(valid) + batchSize - 1) / batchSize
+		ctx, cancel := context.WithTimeout(r.Context(), cfg.Timeout*time.Duration(max(batches, 1)))
+		defer cancel()
+		written := svc.UpsertMany(ctx, valid, batchSize)
+
+		resp := bulkResponse{Results: make([]service.BulkResult, 0, len(items))}
+		next := 0
+		for i, it := range items {
+			var res service.BulkResult
+			if it.err != nil {
+				res = service.BulkResult{Status: service.BulkInvalid, Reason: it.err.Error()}
+			} else {
+				res = written[next]
+				next++
+			}
+			res.Index = i
+			switch res.Status {
+			case service.BulkCreated:
+				resp.Summary.Created++
+			case service.BulkUpdated:
+				resp.Summary.Updated++
+			case service.BulkInvalid:
+				resp.Summary.Invalid++
+			default:
+				resp.Summary.Failed++
+			}
+			resp.Results = append(resp.Results, res)
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+var ndjsonTypes = []string{"application/x-ndjson", "application/jsonl"}
+
+func decodeBulkOrder(raw []byte, strict bool) bulkItem {
+	var order model.Order
+	if err := decodeStrict(raw, &order, strict); err != nil {
+		return bulkItem{err: err}
+	}
+	return bulkItem{order: order}
+}
+
+func readJSONArray(r io.Reader, strict bool) ([]bulkItem, error) {
+	dec := json.NewDecoder(r)
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, describeJSONError(err, dec)
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return nil, &bodyError{http.StatusBadRequest, "invalid_json", "expected a JSON array of orders"}
+	}
+	var items []bulkItem
+	for dec.More() {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			err = describeJSONError(err, dec)
+			var bodyErr *bodyError
+			if errors.As(err, &bodyErr) {
+				bodyErr.msg = fmt.Sprintf("order #%d: %s", len(items), bodyErr.msg)
+			}
+			return nil, err
+		}
+		items = append(items, decodeBulkOrder(raw, strict))
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, describeJSONError(err, dec)
+	}
+	if err := expectEOF(dec); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func readNDJSON(r io.Reader, strict bool) ([]bulkItem, error) {
+	br := bufio.NewReader(r)
+	var items []bulkItem
+	for {
+		line, err := br.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			items = append(items, decodeBulkOrder(line, strict))
+		}
+		if errors.Is(err, io.EOF) {
+			return items, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
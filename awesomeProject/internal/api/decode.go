@@ -0,0 +1,141 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+const defaultMaxBodyBytes = 1 << 20
+
+var errTrailingData = errors.New("unexpected data after the JSON value")
+
+type bodyError struct {
+	status int
+	code   string
+	msg    string
+}
+
+func (e *bodyError) Error() string { return e.msg }
+
+// checkContentType пропускает запросы без Content-Type, application/json, типы с суффиксом +json
+// и явно перечисленные extra; остальное отклоняется 415.
+func checkContentType(w http.ResponseWriter, r *http.Request, extra ...string) (string, bool) {
+	ct := r.Header.Get("Content-Type")
+	if ct == "" {
+		return "application/json", true
+	}
+	mediaType, _, err := mime.ParseMediaType(ct)
+	if err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") ||
+		slices.Contains(extra, mediaType)) {
+		return mediaType, true
+	}
+	allowed := append([]string{"application/json"}, extra...)
+	w.Header().Set("Accept", strings.Join(allowed, ", "))
+	writeError(w, r, http.StatusUnsupportedMediaType, "unsupported_media_type",
+		fmt.Sprintf("Content-Type %q is not supported, use %s", ct, strings.Join(allowed, " or ")))
+	return "", false
+}
+
+// decodeJSONBody читает ровно один JSON-объект из тела с учётом лимита и строгого режима.
+// При ошибке ответ уже записан и возвращается false.
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, cfg Config, dst any, extraTypes ...string) bool {
+	defer r.Body.Close()
+	if _, ok := checkContentType(w, r, extraTypes...); !ok {
+		return false
+	}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, cfg.MaxBodyBytes))
+	if cfg.StrictJSON {
+		dec.DisallowUnknownFields()
+	}
+	if err := decodeOne(dec, dst); err != nil {
+		writeBodyError(w, r, err, cfg.MaxBodyBytes)
+		return false
+	}
+	return true
+}
+
+func decodeStrict(data []byte, dst any, strict bool) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	return decodeOne(dec, dst)
+}
+
+func decodeOne(dec *json.Decoder, dst any) error {
+	if err := dec.Decode(dst); err != nil {
+		return describeJSONError(err, dec)
+	}
+	return expectEOF(dec)
+}
+
+// expectEOF отклоняет данные после первого JSON-значения: "{...}{...}" или "{...} мусор".
+func expectEOF(dec *json.Decoder) error {
+	_, err := dec.Token()
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.Is(err, io.EOF):
+		return nil
+	case errors.As(err, &tooLarge):
+		return err
+	}
+	return &bodyError{http.StatusBadRequest, "trailing_data",
+		fmt.Sprintf("%v at offset %d", errTrailingData, dec.InputOffset())}
+}
+
+func describeJSONError(err error, dec *json.Decoder) error {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+		tooLarge  *http.MaxBytesError
+		bodyErr   *bodyError
+	)
+	switch {
+	case errors.As(err, &tooLarge), errors.As(err, &bodyErr):
+		return err
+	case errors.Is(err, io.EOF):
+		return &bodyError{http.StatusBadRequest, "empty_body", "request body is empty"}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return &bodyError{http.StatusBadRequest, "invalid_json",
+			fmt.Sprintf("truncated JSON at offset %d", dec.InputOffset())}
+	case errors.As(err, &syntaxErr):
+		return &bodyError{http.StatusBadRequest, "invalid_json",
+			fmt.Sprintf("malformed JSON at offset %d: %v", syntaxErr.Offset, syntaxErr)}
+	case errors.As(err, &typeErr):
+		field := typeErr.Field
+		if field == "" {
+			field = "(root)"
+		}
+		return &bodyError{http.StatusBadRequest, "invalid_field",
+			fmt.Sprintf("field %q at offset %d: expected %s, got JSON %s", field, typeErr.Offset, typeErr.Type, typeErr.Value)}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.TrimPrefix(err.Error(), "json: unknown field ")
+		return &bodyError{http.StatusBadRequest, "unknown_field",
+			fmt.Sprintf("unknown field %s at offset %d", field, dec.InputOffset())}
+	default:
+		return &bodyError{http.StatusBadRequest, "invalid_json", err.Error()}
+	}
+}
+
+func writeBodyError(w http.ResponseWriter, r *http.Request, err error, limit int64) {
+	var (
+		tooLarge *http.MaxBytesError
+		bodyErr  *bodyError
+	)
+	switch {
+	case errors.As(err, &tooLarge):
+		writeError(w, r, http.StatusRequestEntityTooLarge, "body_too_large",
+			fmt.Sprintf("request body exceeds %d bytes", limit))
+	case errors.As(err, &bodyErr):
+		writeError(w, r, bodyErr.status, bodyErr.code, bodyErr.msg)
+	default:
+		writeError(w, r, http.StatusBadRequest, "invalid_json", err.Error())
+	}
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"awesomeProject/internal/model"
+	"awesomeProject/internal/repository"
+	"awesomeProject/internal/service"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+const (
+	headerRequestID = "X-Request-ID"
+
+	StatusClientClosedRequest = 499
+)
+
+type ErrorResponse struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id"`
+	Field     string `json:"field,omitempty"`
+}
+
+type requestIDKey struct{}
+
+func RequestID(r *http.Request) string {
+	if id, ok := r.Context().Value(requestIDKey{}).(string); ok && id != "" {
+		return id
+	}
+	return r.Header.Get(headerRequestID)
+}
+
+func newRequestID() string {
+	buf := make([]byte, 8)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// withRequestID гарантирует, что у запроса есть ID: берёт X-Request-ID клиента или генерирует новый.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(headerRequestID)
+		if id == "" || len(id) > 128 {
+			id = newRequestID()
+		}
+		w.Header().Set(headerRequestID, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	writeJSON(w, status, ErrorResponse{Code: code, Message: message, RequestID: RequestID(r)})
+}
+
+func writeServiceError(w http.ResponseWriter, r *http.Request, err error) {
+	var verr *service.ValidationError
+	switch {
+	case errors.As(err, &verr):
+		writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse{
+			Code:      "validation_failed",
+			Message:   verr.Error(),
+			RequestID: RequestID(r),
+			Field:     verr.Field,
+		})
+	case errors.Is(err, service.ErrInvalidOrder):
+		writeError(w, r, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+	case errors.Is(err, model.ErrInvalidTransition):
+		writeError(w, r, http.StatusConflict, "invalid_transition", err.Error())
+	case errors.Is(err, repository.ErrNotFound):
+		writeError(w, r, http.StatusNotFound, "not_found", "order not found")
+	case errors.Is(err, context.DeadlineExceeded):
+		writeError(w, r, http.StatusGatewayTimeout, "timeout", "request timed out")
+	case errors.Is(err, context.Canceled):
+		writeError(w, r, StatusClientClosedRequest, "canceled", "request canceled")
+	case errors.Is(err, service.ErrDependency):
+		writeError(w, r, http.StatusServiceUnavailable, "unavailable", "storage is temporarily unavailable")
+	default:
+		writeError(w, r, http.StatusInternalServerError, "internal", "internal error")
+	}
+}
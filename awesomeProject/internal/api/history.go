@@ -0,0 +1,35 @@
+package api
+
+import (
+	"awesomeProject/internal/model"
+	"awesomeProject/internal/pii"
+	"context"
+	"net/http"
+)
+
+type historyResponse struct {
+	OrderUID string              `json:"order_uid"`
+	History  []model.AuditRecord `json:"history"`
+}
+
+// v1History отдаёт журнал изменений заказа. Снимки заказа маскируются по той же политике PII,
+// что и сам заказ; значения персональных данных в diff замаскированы всегда.
+func v1History(svc OrderService, cfg Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathOrderID(w, r)
+		if !ok {
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), cfg.Timeout)
+		defer cancel()
+		list, err := svc.OrderHistory(ctx, id)
+		if err != nil {
+			writeServiceError(w, r, err)
+			return
+		}
+		if !cfg.PII.allows(r) {
+			list = pii.Redact(list)
+		}
+		writeJSON(w, http.StatusOK, historyResponse{OrderUID: id, History: list})
+	}
+}
@@ -0,0 +1,202 @@
+package api
+
+import (
+	"awesomeProject/internal/auth"
+	"awesomeProject/internal/repository"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	headerIdempotencyKey  = "Idempotency-Key"
+	headerReplayed        = "Idempotent-Replayed"
+	defaultIdempotencyTTL = 24 * time.Hour
+	maxIdempotencyKeyLen  = 255
+)
+
+type IdempotencyStore interface {
+	ReserveIdempotencyKey(ctx context.Context, key, fingerprint string, ttl time.Duration) (*repository.IdempotencyRecord, error)
+	CompleteIdempotencyKey(ctx context.Context, key string, status int, body []byte) error
+	ReleaseIdempotencyKey(ctx context.Context, key string) error
+}
+
+// idempotent повторяет сохранённый ответ для уже выполненного запроса с тем же Idempotency-Key.
+// Ключ с другим телом запроса отклоняется 422, ключ, по которому запрос ещё выполняется, — 409.
+// Ответы 5xx не сохраняются: ключ освобождается, чтобы клиент мог повторить запрос.
+// Ключи разных субъектов аутентификации не пересекаются.
+func idempotent(cfg Config, next http.HandlerFunc) http.HandlerFunc {
+	store, ttl := cfg.Idempotency, cfg.IdempotencyTTL
+	if store == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(headerIdempotencyKey)
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			writeError(w, r, http.StatusBadRequest, "invalid_idempotency_key", "Idempotency-Key is too long")
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, cfg.MaxBodyBytes))
+		_ = r.Body.Close()
+		if err != nil {
+			writeBodyError(w, r, err, cfg.MaxBodyBytes)
+			return
+		}
+		sum := sha256.Sum256([]byte(r.Method + " " + r.URL.Path + "\n" + string(body)))
+		fingerprint := hex.EncodeToString(sum[:])
+		key = scopedIdempotencyKey(r.Context(), key)
+
+		rec, err := store.ReserveIdempotencyKey(r.Context(), key, fingerprint, ttl)
+		if err != nil {
+			writeError(w, r, http.StatusServiceUnavailable, "unavailable", "idempotency storage is unavailable")
+			return
+		}
+		if rec != nil {
+			switch {
+			case rec.Fingerprint != fingerprint:
+				writeError(w, r, http.StatusUnprocessableEntity, "idempotency_key_reused",
+					"Idempotency-Key was already used with a different request")
+			case rec.Status == 0:
+				writeError(w, r, http.StatusConflict, "idempotency_in_progress",
+					"a request with this Idempotency-Key is still being processed")
+			default:
+				w.Header().Set("Content-Type", "application/json; charset=utf-8")
+				w.Header().Set(headerReplayed, "true")
+				w.WriteHeader(rec.Status)
+				_, _ = w.Write(rec.Body)
+			}
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		cw := &captureWriter{ResponseWriter: w, status: http.StatusOK}
+		ctx := context.WithoutCancel(r.Context())
+		defer func() {
+			if p := recover(); p != nil {
+				_ = store.ReleaseIdempotencyKey(ctx, key)
+				panic(p)
+			}
+		}()
+		next(cw, r)
+
+		if cw.status >= http.StatusInternalServerError {
+			_ = store.ReleaseIdempotencyKey(ctx, key)
+			return
+		}
+		_ = store.CompleteIdempotencyKey(ctx, key, cw.status, cw.buf.Bytes())
+	}
+}
+
+// scopedIdempotencyKey добавляет к ключу клиента субъекта аутентификации; длина субъекта
+// в префиксе не даёт паре (субъект, ключ) совпасть с другой при склейке.
+func scopedIdempotencyKey(ctx context.Context, key string) string {
+	id, ok := auth.FromContext(ctx)
+	if !ok {
+		return key
+	}
+	return strconv.Itoa(len(id.Subject)) + ":" + id.Subject + ":" + key
+}
+
+type captureWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	buf         bytes.Buffer
+}
+
+func (w *captureWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *captureWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	w.buf.Write(p)
+	return w.ResponseWriter.Write(p)
+}
+
+// MemoryIdempotencyStore хранит ключи в памяти процесса. Просроченные ключи удаляются
+// проходом по всей карте не реже раза в TTL, а не только при повторе того же ключа.
+type MemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]memIdempotencyRecord
+	swept   time.Time
+}
+
+type memIdempotencyRecord struct {
+	repository.IdempotencyRecord
+	createdAt time.Time
+}
+
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{records: make(map[string]memIdempotencyRecord)}
+}
+
+func (s *MemoryIdempotencyStore) ReserveIdempotencyKey(_ context.Context, key, fingerprint string,
+	ttl time.Duration) (*repository.IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now := time.Now(); now.Sub(s.swept) >= ttl {
+		s.purge(now.Add(-ttl))
+		s.swept = now
+	}
+	if rec, ok := s.records[key]; ok && time.Since(rec.createdAt) < ttl {
+		out := rec.IdempotencyRecord
+		return &out, nil
+	}
+	s.records[key] = memIdempotencyRecord{
+		IdempotencyRecord: repository.IdempotencyRecord{Fingerprint: fingerprint},
+		createdAt:         time.Now(),
+	}
+	return nil, nil
+}
+
+func (s *MemoryIdempotencyStore) CompleteIdempotencyKey(_ context.Context, key string, status int, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec := s.records[key]
+	rec.Status = status
+	rec.Body = append([]byte(nil), body...)
+	s.records[key] = rec
+	return nil
+}
+
+func (s *MemoryIdempotencyStore) ReleaseIdempotencyKey(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rec, ok := s.records[key]; ok && rec.Status == 0 {
+		delete(s.records, key)
+	}
+	return nil
+}
+
+// PurgeIdempotencyKeys удаляет ключи, занятые раньше olderThan.
+func (s *MemoryIdempotencyStore) PurgeIdempotencyKeys(_ context.Context, olderThan time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.purge(olderThan), nil
+}
+
+func (s *MemoryIdempotencyStore) purge(olderThan time.Time) int64 {
+	var n int64
+	for key, rec := range s.records {
+		if rec.createdAt.Before(olderThan) {
+			delete(s.records, key)
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,429 @@
+package api
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Middleware func(http.Handler) http.Handler
+
+// Chain оборачивает h так, что первый middleware оказывается внешним.
+func Chain(h http.Handler, mws ...Middleware) http.Handler {
+	for _, mw := range slices.Backward(mws) {
+		h = mw(h)
+	}
+	return h
+}
+
+// Middlewares — цепочка, через которую NewRouter пропускает все запросы; ею же можно обернуть
+// собственный обработчик. withRecovery стоит внутри withGzip: иначе при панике gzipWriter
+// успевает отправить накопленное начало ответа, и вместо JSON-ошибки клиент получает обрывок с 200.
+func Middlewares(cfg Config) []Middleware {
+	return []Middleware{
+		withRequestID,
+		withAccessLog(cfg.Logger),
+		withCORS(cfg.CORS),
+		withGzip(cfg.Gzip),
+		withRecovery(cfg.Logger),
+	}
+}
+
+// responseRecorder запоминает статус и размер ответа для access-лога и recovery.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *responseRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseRecorder) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *responseRecorder) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w *responseRecorder) Flush() {
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func recorder(w http.ResponseWriter) *responseRecorder {
+	if rec, ok := w.(*responseRecorder); ok {
+		return rec
+	}
+	return &responseRecorder{ResponseWriter: w}
+}
+
+// requestInfo собирает сведения, которые становятся известны глубже по цепочке (например,
+// субъект после аутентификации), чтобы access-лог мог их вывести.
+type requestInfo struct {
+	subject string
+}
+
+type requestInfoKey struct{}
+
+func withRequestInfo(ctx context.Context, info *requestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+func requestInfoFrom(ctx context.Context) *requestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(*requestInfo)
+	return info
+}
+
+// withAccessLog пишет по строке на запрос: метод, путь, статус, размер, длительность, request_id
+// и, если запрос прошёл аутентификацию, субъекта. Без логгера выключен.
+func withAccessLog(logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		if logger == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := recorder(w)
+			info := &requestInfo{}
+			next.ServeHTTP(rec, r.WithContext(withRequestInfo(r.Context(), info)))
+
+			status := rec.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			level := slog.LevelInfo
+			if status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", status),
+				slog.Int64("bytes", rec.bytes),
+				slog.Duration("duration", time.Since(start)),
+				slog.String("request_id", RequestID(r)),
+				slog.String("remote", r.RemoteAddr),
+			}
+			if info.subject != "" {
+				attrs = append(attrs, slog.String("subject", info.subject))
+			}
+			logger.LogAttrs(r.Context(), level, "http request", attrs...)
+		})
+	}
+}
+
+// withRecovery превращает панику обработчика в 500 с JSON-ошибкой, если ответ ещё не ушёл
+// клиенту: начало ответа, которое gzipWriter держит в буфере, отбрасывается.
+// http.ErrAbortHandler пробрасывается дальше: им обработчик сознательно обрывает соединение.
+func withRecovery(logger *slog.Logger) Middleware {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := recorder(w)
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+				logger.ErrorContext(r.Context(), "http handler panic",
+					slog.String("method", r.Method), slog.String("path", r.URL.Path),
+					slog.String("request_id", RequestID(r)), slog.Any("panic", p),
+					slog.String("stack", string(debug.Stack())))
+				if rec.status != 0 && discardResponse(rec.ResponseWriter) {
+					rec.status, rec.bytes = 0, 0
+				}
+				if rec.status == 0 {
+					writeError(rec, r, http.StatusInternalServerError, "internal", "internal error")
+				}
+			}()
+			next.ServeHTTP(rec, r)
+		})
+	}
+}
+
+type CORSConfig struct {
+	// AllowedOrigins — разрешённые Origin; "*" разрешает любой. Пустой список выключает CORS.
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+var (
+	defaultCORSMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+	defaultCORSHeaders = []string{"Content-Type", "Authorization", "X-API-Key", headerIdempotencyKey, headerRequestID}
+	defaultCORSExposed = []string{headerRequestID, "Retry-After", "Location", "Deprecation", "Link"}
+)
+
+func withCORS(cfg CORSConfig) Middleware {
+	return func(next http.Handler) http.Handler {
+		if len(cfg.AllowedOrigins) == 0 {
+			return next
+		}
+		methods := strings.Join(cmpOr(cfg.AllowedMethods, defaultCORSMethods), ", ")
+		headers := strings.Join(cmpOr(cfg.AllowedHeaders, defaultCORSHeaders), ", ")
+		exposed := strings.Join(cmpOr(cfg.ExposedHeaders, defaultCORSExposed), ", ")
+		anyOrigin := slices.Contains(cfg.AllowedOrigins, "*")
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			h := w.Header()
+			h.Add("Vary", "Origin")
+			if !anyOrigin && !slices.Contains(cfg.AllowedOrigins, origin) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if anyOrigin && !cfg.AllowCredentials {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Set("Access-Control-Allow-Origin", origin)
+			}
+			if cfg.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				h.Add("Vary", "Access-Control-Request-Method")
+				h.Add("Vary", "Access-Control-Request-Headers")
+				h.Set("Access-Control-Allow-Methods", methods)
+				h.Set("Access-Control-Allow-Headers", headers)
+				if cfg.MaxAge > 0 {
+					h.Set("Access-Control-Max-Age", strconv.Itoa(int(cfg.MaxAge.Seconds())))
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			h.Set("Access-Control-Expose-Headers", exposed)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func cmpOr(v, def []string) []string {
+	if len(v) == 0 {
+		return def
+	}
+	return v
+}
+
+const gzipMinSize = 512
+
+var gzipPool = sync.Pool{New: func() any {
+	zw, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+	return zw
+}}
+
+// withGzip сжимает ответы для клиентов с Accept-Encoding: gzip. Решение принимается по первым
+// gzipMinSize байтам: короткие ответы и ответы с уже заданным Content-Encoding идут как есть.
+func withGzip(enabled bool) Middleware {
+	return func(next http.Handler) http.Handler {
+		if !enabled {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			if r.Method == http.MethodHead || !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			gw := &gzipWriter{ResponseWriter: w}
+			defer gw.close()
+			next.ServeHTTP(gw, r)
+		})
+	}
+}
+
+// acceptsGzip разбирает Accept-Encoding; "gzip;q=0" означает явный отказ от сжатия.
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		enc, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(enc), "gzip") {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			v, err := strconv.ParseFloat(q, 64)
+			return err == nil && v > 0
+		}
+		return true
+	}
+	return false
+}
+
+type gzipWriter struct {
+	http.ResponseWriter
+	status  int
+	buf     []byte
+	zw      *gzip.Writer
+	decided bool
+}
+
+func (w *gzipWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified {
+		w.decide(false)
+	}
+}
+
+func (w *gzipWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.decided {
+		if w.zw != nil {
+			return w.zw.Write(p)
+		}
+		return w.ResponseWriter.Write(p)
+	}
+	w.buf = append(w.buf, p...)
+	if len(w.buf) >= gzipMinSize {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// decide фиксирует заголовки и выбирает, сжимать ли ответ, после чего сбрасывает накопленный буфер.
+func (w *gzipWriter) decide(compress bool) error {
+	if w.decided {
+		return nil
+	}
+	w.decided = true
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" {
+		compress = false
+	}
+	if compress {
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		w.zw = gzipPool.Get().(*gzip.Writer)
+		w.zw.Reset(w.ResponseWriter)
+	}
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if len(w.buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.zw != nil {
+		_, err = w.zw.Write(w.buf)
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf)
+	}
+	w.buf = nil
+	return err
+}
+
+// discard отбрасывает начало ответа, пока оно только в буфере и заголовки не отправлены,
+// чтобы вместо него можно было записать другой ответ. Сообщает, удалось ли это.
+func (w *gzipWriter) discard() bool {
+	if w.decided {
+		return false
+	}
+	w.status, w.buf = 0, nil
+	return true
+}
+
+// discardResponse отбрасывает ещё не отправленный ответ, если w это умеет (см. gzipWriter.discard).
+func discardResponse(w http.ResponseWriter) bool {
+	d, ok := w.(interface{ discard() bool })
+	return ok && d.discard()
+}
+
+func (w *gzipWriter) Flush() {
+	_ = w.decide(true)
+	if w.zw != nil {
+		_ = w.zw.Flush()
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *gzipWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w *gzipWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("gzip: hijacking is not supported")
+}
+
+func (w *gzipWriter) close() {
+	_ = w.decide(false)
+	if w.zw != nil {
+		_ = w.zw.Close()
+		gzipPool.Put(w.zw)
+		w.zw = nil
+	}
+}
+
+// ServerConfig — таймауты http.Server; нулевые поля получают значения по умолчанию.
+type ServerConfig struct {
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+}
+
+// NewServer создаёт http.Server с таймаутами, без которых медленный клиент может держать
+// соединение и горутину сколь угодно долго.
+func NewServer(addr string, h http.Handler, cfg ServerConfig, logger *slog.Logger) *http.Server {
+	if cfg.ReadHeaderTimeout <= 0 {
+		cfg.ReadHeaderTimeout = 5 * time.Second
+	}
+	if cfg.ReadTimeout <= 0 {
+		cfg.ReadTimeout = 30 * time.Second
+	}
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = 60 * time.Second
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = 120 * time.Second
+	}
+	if cfg.MaxHeaderBytes <= 0 {
+		cfg.MaxHeaderBytes = 64 << 10
+	}
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           h,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+	if logger != nil {
+		srv.ErrorLog = slog.NewLogLogger(logger.Handler(), slog.LevelWarn)
+	}
+	return srv
+}
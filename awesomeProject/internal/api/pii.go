@@ -0,0 +1,36 @@
+package api
+
+import (
+	"awesomeProject/internal/auth"
+	"awesomeProject/internal/model"
+	"awesomeProject/internal/pii"
+	"net/http"
+	"slices"
+)
+
+const defaultPIIScope = "pii"
+
+// PIIPolicy решает, кто видит персональные данные доставки без маскировки: вызывающие со
+// scope Scope или с одной из ролей Roles. Запросы без личности (аутентификация выключена)
+// маскируются, только если MaskAnonymous.
+type PIIPolicy struct {
+	Scope         string
+	Roles         []auth.Role
+	MaskAnonymous bool
+}
+
+func (p PIIPolicy) allows(r *http.Request) bool {
+	id, ok := auth.FromContext(r.Context())
+	if !ok {
+		return !p.MaskAnonymous
+	}
+	return id.HasScope(p.Scope) || slices.Contains(p.Roles, id.Role)
+}
+
+// writeOrder отдаёт заказ, маскируя доставку, если политика не разрешает вызывающему видеть PII.
+func writeOrder(w http.ResponseWriter, r *http.Request, cfg Config, status int, order model.Order) {
+	if !cfg.PII.allows(r) {
+		order = pii.Redact(order)
+	}
+	writeJSON(w, status, order)
+}
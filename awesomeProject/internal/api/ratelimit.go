@@ -0,0 +1,213 @@
+package api
+
+import (
+	"awesomeProject/internal/auth"
+	"awesomeProject/internal/repository"
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Классы маршрутов, для которых задаются отдельные лимиты.
+const (
+	RouteRead  = "read"
+	RouteWrite = "write"
+	RouteBulk  = "bulk"
+)
+
+const maxBuckets = 100_000
+
+// RateLimit — token bucket: Rate запросов в секунду в среднем и всплеск до Burst.
+// Нулевой Rate выключает лимит.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+type LimitConfig struct {
+	// Routes задаёт лимиты на клиента по классам маршрутов (RouteRead, RouteWrite, RouteBulk).
+	// Клиент — аутентифицированный субъект, а без аутентификации — IP.
+	Routes map[string]RateLimit
+	// PerIP — общий лимит на IP для всех маршрутов. Он проверяется до аутентификации и не даёт
+	// перебирать ключи и нагружать проверку учётных данных с одного адреса.
+	PerIP RateLimit
+	// MaxInFlight ограничивает число одновременно обрабатываемых запросов к API; лишние ждут
+	// не дольше QueueTimeout и получают 503 с Retry-After.
+	MaxInFlight  int
+	QueueTimeout time.Duration
+	// TrustForwardedFor берёт IP клиента из X-Forwarded-For (только за доверенным прокси).
+	TrustForwardedFor bool
+}
+
+type guard struct {
+	cfg      Config
+	buckets  map[string]*tokenBuckets
+	perIP    *tokenBuckets
+	inflight chan struct{}
+}
+
+func newGuard(cfg Config) *guard {
+	g := &guard{cfg: cfg, buckets: make(map[string]*tokenBuckets)}
+	for class, lim := range cfg.Limits.Routes {
+		if lim.Rate > 0 {
+			g.buckets[class] = newTokenBuckets(lim, time.Now)
+		}
+	}
+	if cfg.Limits.PerIP.Rate > 0 {
+		g.perIP = newTokenBuckets(cfg.Limits.PerIP, time.Now)
+	}
+	if cfg.Limits.MaxInFlight > 0 {
+		g.inflight = make(chan struct{}, cfg.Limits.MaxInFlight)
+	}
+	return g
+}
+
+// route собирает цепочку маршрута: лимит IP, аутентификация и роль, лимит клиента, общий лимит
+// параллельности.
+func (g *guard) route(role auth.Role, class string, next http.HandlerFunc) http.HandlerFunc {
+	return g.throttle(g.perIP, g.ipKey, require(g.cfg, role, g.limit(class, g.shed(g.source(next)))))
+}
+
+// source записывает в контекст запроса, кто вносит изменение, для журнала изменений и истории
+// статусов: "http:" и тот же ключ клиента, что у лимитов, — субъект или адрес.
+func (g *guard) source(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		next(w, r.WithContext(repository.WithSource(r.Context(), "http:"+g.clientKey(r))))
+	}
+}
+
+func (g *guard) limit(class string, next http.HandlerFunc) http.HandlerFunc {
+	return g.throttle(g.buckets[class], g.clientKey, next)
+}
+
+// throttle отвечает 429, когда у клиента, которого определяет key, закончились токены tb.
+func (g *guard) throttle(tb *tokenBuckets, key func(*http.Request) string, next http.HandlerFunc) http.HandlerFunc {
+	if tb == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		ok, retry := tb.allow(key(r))
+		if !ok {
+			w.Header().Set("Retry-After", retryAfter(retry))
+			writeError(w, r, http.StatusTooManyRequests, "rate_limited", "too many requests, slow down")
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (g *guard) shed(next http.HandlerFunc) http.HandlerFunc {
+	if g.inflight == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case g.inflight <- struct{}{}:
+		default:
+			if !g.wait(r.Context()) {
+				w.Header().Set("Retry-After", "1")
+				writeError(w, r, http.StatusServiceUnavailable, "overloaded", "server is overloaded, retry later")
+				return
+			}
+		}
+		defer func() { <-g.inflight }()
+		next(w, r)
+	}
+}
+
+func (g *guard) wait(ctx context.Context) bool {
+	if g.cfg.Limits.QueueTimeout <= 0 {
+		return false
+	}
+	t := time.NewTimer(g.cfg.Limits.QueueTimeout)
+	defer t.Stop()
+	select {
+	case g.inflight <- struct{}{}:
+		return true
+	case <-t.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (g *guard) clientKey(r *http.Request) string {
+	if id, ok := auth.FromContext(r.Context()); ok {
+		return "sub:" + id.Subject
+	}
+	return g.ipKey(r)
+}
+
+func (g *guard) ipKey(r *http.Request) string {
+	if g.cfg.Limits.TrustForwardedFor {
+		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+			first, _, _ := strings.Cut(xff, ",")
+			return "ip:" + strings.TrimSpace(first)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+func retryAfter(d time.Duration) string {
+	return strconv.Itoa(max(1, int(math.Ceil(d.Seconds()))))
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type tokenBuckets struct {
+	mu    sync.Mutex
+	limit RateLimit
+	now   func() time.Time
+	m     map[string]*bucket
+}
+
+func newTokenBuckets(limit RateLimit, now func() time.Time) *tokenBuckets {
+	if limit.Burst < 1 {
+		limit.Burst = max(1, int(math.Ceil(limit.Rate)))
+	}
+	return &tokenBuckets{limit: limit, now: now, m: make(map[string]*bucket)}
+}
+
+// allow списывает токен клиента key; при отказе возвращает, через сколько появится следующий.
+func (t *tokenBuckets) allow(key string) (bool, time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	b, ok := t.m[key]
+	if !ok {
+		if len(t.m) >= maxBuckets {
+			t.evict(now)
+		}
+		b = &bucket{tokens: float64(t.limit.Burst), last: now}
+		t.m[key] = b
+	}
+	b.tokens = min(float64(t.limit.Burst), b.tokens+now.Sub(b.last).Seconds()*t.limit.Rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / t.limit.Rate * float64(time.Second))
+}
+
+// evict удаляет корзины, которые успели наполниться полностью: для них новая корзина ничем не отличается.
+func (t *tokenBuckets) evict(now time.Time) {
+	full := time.Duration(float64(t.limit.Burst) / t.limit.Rate * float64(time.Second))
+	for k, b := range t.m {
+		if now.Sub(b.last) >= full {
+			delete(t.m, k)
+		}
+	}
+}
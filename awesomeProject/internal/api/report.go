@@ -0,0 +1,57 @@
+package api
+
+import (
+	"awesomeProject/internal/service"
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const defaultReportWindow = 30 * 24 * time.Hour
+
+type ReportService interface {
+	Revenue(ctx context.Context, from, to time.Time, currency string) (service.RevenueReport, error)
+}
+
+// v1Revenue отдаёт выручку по валютам и итог в валюте ?currency= (по умолчанию — базовая валюта
+// таблицы курсов). ?from= и ?to= принимают RFC 3339 или дату YYYY-MM-DD; дата в to включается
+// целиком. Без них отчёт строится за последние 30 дней.
+func v1Revenue(cfg Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		to, err := parseReportTime(q.Get("to"), time.Now().UTC(), true)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "bad_query", "to: "+err.Error())
+			return
+		}
+		from, err := parseReportTime(q.Get("from"), to.Add(-defaultReportWindow), false)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "bad_query", "from: "+err.Error())
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), cfg.Timeout)
+		defer cancel()
+		report, err := cfg.Reports.Revenue(ctx, from, to, strings.ToUpper(q.Get("currency")))
+		if err != nil {
+			writeServiceError(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
+	}
+}
+
+// parseReportTime разбирает границу периода. Дата без времени в верхней границе означает конец
+// этого дня.
+func parseReportTime(s string, def time.Time, end bool) (time.Time, error) {
+	if s == "" {
+		return def, nil
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		if end {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
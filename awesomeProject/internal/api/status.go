@@ -0,0 +1,55 @@
+package api
+
+import (
+	"awesomeProject/internal/model"
+	"context"
+	"net/http"
+)
+
+type statusRequest struct {
+	Status model.OrderStatus `json:"status"`
+	Reason string            `json:"reason"`
+}
+
+func v1StatusGet(svc OrderService, cfg Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathOrderID(w, r)
+		if !ok {
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), cfg.Timeout)
+		defer cancel()
+		h, err := svc.StatusHistory(ctx, id)
+		if err != nil {
+			writeServiceError(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, h)
+	}
+}
+
+// v1StatusPost меняет статус заказа. Недопустимый из текущего статуса переход — 409.
+func v1StatusPost(svc OrderService, cfg Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathOrderID(w, r)
+		if !ok {
+			return
+		}
+		var req statusRequest
+		if !decodeJSONBody(w, r, cfg, &req) {
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), cfg.Timeout)
+		defer cancel()
+		change, err := svc.ChangeStatus(ctx, model.StatusChange{
+			OrderUID: id,
+			To:       req.Status,
+			Reason:   req.Reason,
+		})
+		if err != nil {
+			writeServiceError(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, change)
+	}
+}
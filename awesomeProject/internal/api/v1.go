@@ -0,0 +1,160 @@
+package api
+
+import (
+	"awesomeProject/internal/auth"
+	"awesomeProject/internal/model"
+	"awesomeProject/internal/service"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+)
+
+const (
+	v1Prefix       = "/api/v1"
+	ordersAllow    = "POST"
+	orderIDAllow   = "GET, HEAD, PUT, PATCH, DELETE"
+	statusAllow    = "GET, HEAD, POST"
+	historyAllow   = "GET, HEAD"
+	reportsAllow   = "GET, HEAD"
+	mergePatchType = "application/merge-patch+json"
+)
+
+func registerV1(mux *http.ServeMux, svc OrderService, cfg Config, g *guard, post http.HandlerFunc) {
+	mux.HandleFunc("POST "+v1Prefix+"/orders", post)
+	mux.HandleFunc(v1Prefix+"/orders", methodNotAllowed(ordersAllow))
+
+	mux.HandleFunc("GET "+v1Prefix+"/orders/{order_uid}", g.route(auth.RoleReader, RouteRead, v1Get(svc, cfg)))
+	mux.HandleFunc("PUT "+v1Prefix+"/orders/{order_uid}", g.route(auth.RoleWriter, RouteWrite, v1Put(svc, cfg)))
+	mux.HandleFunc("PATCH "+v1Prefix+"/orders/{order_uid}", g.route(auth.RoleWriter, RouteWrite, v1Patch(svc, cfg)))
+	mux.HandleFunc("DELETE "+v1Prefix+"/orders/{order_uid}", g.route(auth.RoleAdmin, RouteWrite, v1Delete(svc, cfg)))
+	mux.HandleFunc(v1Prefix+"/orders/{order_uid}", methodNotAllowed(orderIDAllow))
+
+	mux.HandleFunc("GET "+v1Prefix+"/orders/{order_uid}/status", g.route(auth.RoleReader, RouteRead, v1StatusGet(svc, cfg)))
+	mux.HandleFunc("POST "+v1Prefix+"/orders/{order_uid}/status", g.route(auth.RoleWriter, RouteWrite, v1StatusPost(svc, cfg)))
+	mux.HandleFunc(v1Prefix+"/orders/{order_uid}/status", methodNotAllowed(statusAllow))
+
+	mux.HandleFunc("GET "+v1Prefix+"/orders/{order_uid}/history", g.route(auth.RoleReader, RouteRead, v1History(svc, cfg)))
+	mux.HandleFunc(v1Prefix+"/orders/{order_uid}/history", methodNotAllowed(historyAllow))
+
+	if cfg.Reports != nil {
+		mux.HandleFunc("GET "+v1Prefix+"/reports/revenue", g.route(auth.RoleReader, RouteRead, v1Revenue(cfg)))
+		mux.HandleFunc(v1Prefix+"/reports/revenue", methodNotAllowed(reportsAllow))
+	}
+}
+
+func methodNotAllowed(allow string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", allow)
+		writeError(w, r, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+	}
+}
+
+// deprecated помечает старые маршруты /order и /order/{id} как устаревшие алиасы /api/v1/orders.
+func deprecated(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		successor := v1Prefix + "/orders"
+		if id, ok := orderIDFromPath(r.URL); ok {
+			successor += "/" + url.PathEscape(id)
+		}
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+successor+`>; rel="successor-version"`)
+		next(w, r)
+	}
+}
+
+func pathOrderID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id := r.PathValue("order_uid")
+	if id == "" {
+		writeError(w, r, http.StatusBadRequest, "bad_path", "order_uid is required")
+		return "", false
+	}
+	return id, true
+}
+
+func v1Get(svc OrderService, cfg Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathOrderID(w, r)
+		if !ok {
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), cfg.Timeout)
+		defer cancel()
+		order, err := svc.GetOrderByID(ctx, id)
+		if err != nil {
+			writeServiceError(w, r, err)
+			return
+		}
+		writeOrder(w, r, cfg, http.StatusOK, order)
+	}
+}
+
+func v1Put(svc OrderService, cfg Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathOrderID(w, r)
+		if !ok {
+			return
+		}
+		var order model.Order
+		if !decodeJSONBody(w, r, cfg, &order) {
+			return
+		}
+		if order.OrderUID == "" {
+			order.OrderUID = id
+		}
+		if order.OrderUID != id {
+			writeServiceError(w, r, &service.ValidationError{Field: "order_uid", Reason: "does not match the path"})
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), cfg.Timeout)
+		defer cancel()
+		created, err := svc.ReplaceOrder(ctx, order)
+		if err != nil {
+			writeServiceError(w, r, err)
+			return
+		}
+		status := http.StatusOK
+		if created {
+			status = http.StatusCreated
+			w.Header().Set("Location", v1Prefix+"/orders/"+url.PathEscape(id))
+		}
+		writeOrder(w, r, cfg, status, order)
+	}
+}
+
+func v1Patch(svc OrderService, cfg Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathOrderID(w, r)
+		if !ok {
+			return
+		}
+		var patch json.RawMessage
+		if !decodeJSONBody(w, r, cfg, &patch, mergePatchType) {
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), cfg.Timeout)
+		defer cancel()
+		order, err := svc.PatchOrder(ctx, id, patch)
+		if err != nil {
+			writeServiceError(w, r, err)
+			return
+		}
+		writeOrder(w, r, cfg, http.StatusOK, order)
+	}
+}
+
+func v1Delete(svc OrderService, cfg Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathOrderID(w, r)
+		if !ok {
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), cfg.Timeout)
+		defer cancel()
+		if err := svc.DeleteOrder(ctx, id); err != nil {
+			writeServiceError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const HeaderAPIKey = "X-API-Key"
+
+// APIKeys проверяет статические ключи из заголовка X-API-Key или Authorization: ApiKey <key>.
+// Ключи хранятся как sha256, чтобы сравнение не зависело от длины совпавшего префикса.
+type APIKeys struct {
+	keys map[[sha256.Size]byte]Identity
+}
+
+func NewAPIKeys() *APIKeys {
+	return &APIKeys{keys: make(map[[sha256.Size]byte]Identity)}
+}
+
+func (a *APIKeys) Add(key, subject string, role Role, scopes ...string) {
+	a.keys[sha256.Sum256([]byte(key))] = Identity{Subject: subject, Role: role, Method: "api_key", Scopes: scopes}
+}
+
+func (a *APIKeys) Len() int { return len(a.keys) }
+
+// ParseAPIKeys разбирает список вида "key:subject:role,key2:subject2:role2:scope1+scope2" (формат API_KEYS).
+func ParseAPIKeys(spec string) (*APIKeys, error) {
+	a := NewAPIKeys()
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) < 3 || len(parts) > 4 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("api key entry %q: want key:subject:role[:scopes]", redact(entry))
+		}
+		role, err := ParseRole(parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("api key for %s: %w", parts[1], err)
+		}
+		var scopes []string
+		if len(parts) == 4 {
+			scopes = splitScopes(parts[3], "+")
+		}
+		a.Add(parts[0], parts[1], role, scopes...)
+	}
+	return a, nil
+}
+
+func (a *APIKeys) Authenticate(r *http.Request) (Identity, error) {
+	key := r.Header.Get(HeaderAPIKey)
+	if key == "" {
+		scheme, rest, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !strings.EqualFold(scheme, "ApiKey") {
+			return Identity{}, ErrNoCredentials
+		}
+		key = strings.TrimSpace(rest)
+	}
+	id, ok := a.keys[sha256.Sum256([]byte(key))]
+	if !ok {
+		return Identity{}, fmt.Errorf("%w: unknown api key", ErrInvalidToken)
+	}
+	return id, nil
+}
+
+func redact(entry string) string {
+	if key, rest, ok := strings.Cut(entry, ":"); ok && len(key) > 4 {
+		return key[:4] + "***:" + rest
+	}
+	return "***"
+}
+
+func splitScopes(s, sep string) []string {
+	var out []string
+	for _, p := range strings.Split(s, sep) {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
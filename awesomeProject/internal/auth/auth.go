@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+var (
+	// ErrNoCredentials возвращается аутентификатором, когда в запросе нет его учётных данных;
+	// Chain в этом случае пробует следующий.
+	ErrNoCredentials = errors.New("no credentials")
+	ErrInvalidToken  = errors.New("invalid credentials")
+)
+
+type Role int
+
+const (
+	RoleNone Role = iota
+	RoleReader
+	RoleWriter
+	RoleAdmin
+)
+
+func (r Role) String() string {
+	switch r {
+	case RoleReader:
+		return "reader"
+	case RoleWriter:
+		return "writer"
+	case RoleAdmin:
+		return "admin"
+	default:
+		return "none"
+	}
+}
+
+func ParseRole(s string) (Role, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "reader":
+		return RoleReader, nil
+	case "writer":
+		return RoleWriter, nil
+	case "admin":
+		return RoleAdmin, nil
+	}
+	return RoleNone, fmt.Errorf("unknown role %q", s)
+}
+
+// Allows сообщает, достаточно ли роли для действия, требующего required: роли вложены,
+// writer может всё, что reader, admin — всё, что writer.
+func (r Role) Allows(required Role) bool {
+	return r >= required
+}
+
+type Identity struct {
+	Subject string
+	Role    Role
+	Method  string
+	Scopes  []string
+}
+
+func (id Identity) HasScope(scope string) bool {
+	return slices.Contains(id.Scopes, scope)
+}
+
+type Authenticator interface {
+	Authenticate(r *http.Request) (Identity, error)
+}
+
+type chain []Authenticator
+
+// Chain пробует аутентификаторы по порядку; первый, нашедший свои учётные данные, решает исход.
+func Chain(list ...Authenticator) Authenticator {
+	return chain(list)
+}
+
+func (c chain) Authenticate(r *http.Request) (Identity, error) {
+	for _, a := range c {
+		id, err := a.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return id, err
+	}
+	return Identity{}, ErrNoCredentials
+}
+
+type identityKey struct{}
+
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+func FromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	return id, ok
+}
+
+type logHandler struct {
+	slog.Handler
+}
+
+// LogHandler добавляет к записям, залогированным с контекстом запроса, субъекта и роль вызывающего.
+func LogHandler(h slog.Handler) slog.Handler {
+	return logHandler{h}
+}
+
+func (h logHandler) Handle(ctx context.Context, rec slog.Record) error {
+	if id, ok := FromContext(ctx); ok {
+		rec.AddAttrs(slog.String("auth_subject", id.Subject), slog.String("auth_role", id.Role.String()))
+	}
+	return h.Handler.Handle(ctx, rec)
+}
+
+func (h logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return logHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h logHandler) WithGroup(name string) slog.Handler {
+	return logHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,281 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+const defaultRoleClaim = "role"
+
+type JWTConfig struct {
+	// HMACSecret включает HS256/HS384/HS512 с общим секретом.
+	HMACSecret []byte
+	// JWKS — ключи проверки подписи (RSA для RS256/384/512, oct для HS*), выбираются по kid.
+	JWKS *JWKS
+	// Issuer и Audience, если заданы, обязаны совпасть с iss и одним из aud токена.
+	Issuer   string
+	Audience string
+	// RoleClaim — claim с ролью (строка или массив строк, берётся наибольшая); по умолчанию "role".
+	RoleClaim string
+	Leeway    time.Duration
+}
+
+// JWTVerifier проверяет Bearer-токены локально, без обращения к внешнему провайдеру.
+type JWTVerifier struct {
+	cfg JWTConfig
+	now func() time.Time
+}
+
+func NewJWTVerifier(cfg JWTConfig) (*JWTVerifier, error) {
+	if len(cfg.HMACSecret) == 0 && cfg.JWKS == nil {
+		return nil, errors.New("jwt: either an HMAC secret or a JWKS is required")
+	}
+	if cfg.RoleClaim == "" {
+		cfg.RoleClaim = defaultRoleClaim
+	}
+	return &JWTVerifier{cfg: cfg, now: time.Now}, nil
+}
+
+func (v *JWTVerifier) Authenticate(r *http.Request) (Identity, error) {
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return Identity{}, ErrNoCredentials
+	}
+	id, err := v.Verify(strings.TrimSpace(token))
+	if err != nil {
+		return Identity{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	return id, nil
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type jwtClaims struct {
+	Subject   string     `json:"sub"`
+	Issuer    string     `json:"iss"`
+	Audience  stringList `json:"aud"`
+	ExpiresAt *int64     `json:"exp"`
+	NotBefore *int64     `json:"nbf"`
+	Scope     string     `json:"scope"`
+	Scp       stringList `json:"scp"`
+}
+
+// Verify проверяет подпись, сроки, iss/aud и возвращает субъекта с ролью из RoleClaim.
+func (v *JWTVerifier) Verify(token string) (Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Identity{}, errors.New("malformed token")
+	}
+	var hdr jwtHeader
+	if err := decodeSegment(parts[0], &hdr); err != nil {
+		return Identity{}, fmt.Errorf("header: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Identity{}, errors.New("signature is not base64url")
+	}
+	if err := v.verifySignature(hdr, parts[0]+"."+parts[1], sig); err != nil {
+		return Identity{}, err
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return Identity{}, errors.New("payload is not base64url")
+	}
+	var claims jwtClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return Identity{}, fmt.Errorf("claims: %w", err)
+	}
+	now := v.now()
+	if claims.ExpiresAt == nil || now.After(time.Unix(*claims.ExpiresAt, 0).Add(v.cfg.Leeway)) {
+		return Identity{}, errors.New("token is expired or has no exp")
+	}
+	if claims.NotBefore != nil && now.Add(v.cfg.Leeway).Before(time.Unix(*claims.NotBefore, 0)) {
+		return Identity{}, errors.New("token is not valid yet")
+	}
+	if v.cfg.Issuer != "" && claims.Issuer != v.cfg.Issuer {
+		return Identity{}, fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	}
+	if v.cfg.Audience != "" && !slices.Contains(claims.Audience, v.cfg.Audience) {
+		return Identity{}, errors.New("token is not issued for this audience")
+	}
+	if claims.Subject == "" {
+		return Identity{}, errors.New("token has no sub")
+	}
+
+	var extra map[string]json.RawMessage
+	_ = json.Unmarshal(payload, &extra)
+	var roles stringList
+	if raw, ok := extra[v.cfg.RoleClaim]; ok {
+		if err := json.Unmarshal(raw, &roles); err != nil {
+			return Identity{}, fmt.Errorf("claim %s: %w", v.cfg.RoleClaim, err)
+		}
+	}
+	role := RoleNone
+	for _, name := range roles {
+		if r, err := ParseRole(name); err == nil && r > role {
+			role = r
+		}
+	}
+	scopes := append(splitScopes(claims.Scope, " "), claims.Scp...)
+	return Identity{Subject: claims.Subject, Role: role, Method: "jwt", Scopes: scopes}, nil
+}
+
+func (v *JWTVerifier) verifySignature(hdr jwtHeader, signed string, sig []byte) error {
+	switch hdr.Alg {
+	case "HS256", "HS384", "HS512":
+		secret := v.cfg.HMACSecret
+		if v.cfg.JWKS != nil {
+			if k, ok := v.cfg.JWKS.secrets[hdr.Kid]; ok {
+				secret = k
+			}
+		}
+		if len(secret) == 0 {
+			return fmt.Errorf("no HMAC key for kid %q", hdr.Kid)
+		}
+		mac := hmac.New(hmacHash(hdr.Alg), secret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return errors.New("signature mismatch")
+		}
+		return nil
+	case "RS256", "RS384", "RS512":
+		if v.cfg.JWKS == nil {
+			return fmt.Errorf("algorithm %s requires a JWKS", hdr.Alg)
+		}
+		key, ok := v.cfg.JWKS.rsa[hdr.Kid]
+		if !ok {
+			return fmt.Errorf("unknown kid %q", hdr.Kid)
+		}
+		h, digest := rsaHash(hdr.Alg, signed)
+		if err := rsa.VerifyPKCS1v15(key, h, digest, sig); err != nil {
+			return errors.New("signature mismatch")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported algorithm %q", hdr.Alg)
+	}
+}
+
+func hmacHash(alg string) func() hash.Hash {
+	switch alg {
+	case "HS384":
+		return sha512.New384
+	case "HS512":
+		return sha512.New
+	}
+	return sha256.New
+}
+
+func rsaHash(alg, signed string) (crypto.Hash, []byte) {
+	switch alg {
+	case "RS384":
+		sum := sha512.Sum384([]byte(signed))
+		return crypto.SHA384, sum[:]
+	case "RS512":
+		sum := sha512.Sum512([]byte(signed))
+		return crypto.SHA512, sum[:]
+	}
+	sum := sha256.Sum256([]byte(signed))
+	return crypto.SHA256, sum[:]
+}
+
+func decodeSegment(seg string, dst any) error {
+	raw, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return errors.New("not base64url")
+	}
+	return json.Unmarshal(raw, dst)
+}
+
+// stringList принимает и строку, и массив строк: так в JWT кодируются aud и роли.
+type stringList []string
+
+func (l *stringList) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*l = stringList{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return errors.New("want a string or an array of strings")
+	}
+	*l = many
+	return nil
+}
+
+// JWKS — локально сконфигурированный набор ключей в формате RFC 7517.
+type JWKS struct {
+	rsa     map[string]*rsa.PublicKey
+	secrets map[string][]byte
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	K   string `json:"k"`
+}
+
+func LoadJWKS(path string) (*JWKS, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseJWKS(data)
+}
+
+func ParseJWKS(data []byte) (*JWKS, error) {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+	set := &JWKS{rsa: make(map[string]*rsa.PublicKey), secrets: make(map[string][]byte)}
+	for i, k := range doc.Keys {
+		switch k.Kty {
+		case "RSA":
+			n, err1 := base64.RawURLEncoding.DecodeString(k.N)
+			e, err2 := base64.RawURLEncoding.DecodeString(k.E)
+			if err1 != nil || err2 != nil || len(n) == 0 || len(e) == 0 || len(e) > 4 {
+				return nil, fmt.Errorf("jwks: key #%d (%s): bad modulus or exponent", i, k.Kid)
+			}
+			set.rsa[k.Kid] = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+		case "oct":
+			secret, err := base64.RawURLEncoding.DecodeString(k.K)
+			if err != nil || len(secret) == 0 {
+				return nil, fmt.Errorf("jwks: key #%d (%s): bad secret", i, k.Kid)
+			}
+			set.secrets[k.Kid] = secret
+		default:
+			return nil, fmt.Errorf("jwks: key #%d (%s): unsupported kty %q", i, k.Kid, k.Kty)
+		}
+	}
+	if len(set.rsa)+len(set.secrets) == 0 {
+		return nil, errors.New("jwks: no keys")
+	}
+	return set, nil
+}
@@ -1,10 +1,12 @@
 package cache
 
 import (
+	"awesomeProject/internal/envelope"
 	"awesomeProject/internal/model"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
 	"os"
 	"strconv"
@@ -27,6 +29,8 @@ type Cache struct {
 	logger *slog.Logger
 	mem    map[string]model.Order
 	mu     sync.RWMutex
+
+	keyring *envelope.Keyring
 }
 
 func NewCache(logger *slog.Logger) (*Cache, error) {
@@ -54,16 +58,37 @@ func NewCache(logger *slog.Logger) (*Cache, error) {
 	return c, nil
 }
 
+// WithKeyring включает шифрование персональных данных доставки в JSON, который хранится в Redis.
+// Копия в памяти процесса остаётся расшифрованной.
+func (c *Cache) WithKeyring(k *envelope.Keyring) *Cache {
+	c.keyring = k
+	return c
+}
+
+// Close закрывает соединения с Redis. Запись в Redis синхронная, поэтому после того, как
+// остановлены все писатели (HTTP и Kafka), в кэше не остаётся несохранённых данных.
+func (c *Cache) Close() error {
+	if c.client == nil {
+		return nil
+	}
+	return c.client.Close()
+}
+
 func (c *Cache) Set(order model.Order) {
 	c.mu.Lock()
-	c.mem[order.Order_uid] = order
+	c.mem[order.OrderUID] = order
 	c.mu.Unlock()
+	if c.client == nil {
+		return
+	}
 	ctx := context.Background()
-	data, _ := json.Marshal(order)
-	if c.client != nil {
-		if err := c.client.Set(ctx, order.Order_uid, data, c.ttl).Err(); err != nil {
-			c.logger.Error("redis set failed", slog.String("key", order.Order_uid), slog.Any("err", err))
-		}
+	data, err := c.marshal(order)
+	if err != nil {
+		c.logger.Error("redis encode failed", slog.String("key", order.OrderUID), slog.Any("err", err))
+		return
+	}
+	if err := c.client.Set(ctx, order.OrderUID, data, c.ttl).Err(); err != nil {
+		c.logger.Error("redis set failed", slog.String("key", order.OrderUID), slog.Any("err", err))
 	}
 }
 
@@ -86,9 +111,9 @@ func (c *Cache) Get(id string) (model.Order, bool) {
 		c.logger.Error("redis get failed", slog.String("key", id), slog.Any("err", err))
 		return model.Order{}, false
 	}
-	var o model.Order
-	if err := json.Unmarshal([]byte(val), &o); err != nil {
-		c.logger.Error("unmarshal from redis failed", slog.Any("err", err))
+	o, err := c.unmarshal([]byte(val))
+	if err != nil {
+		c.logger.Error("unmarshal from redis failed", slog.String("key", id), slog.Any("err", err))
 		return model.Order{}, false
 	}
 	c.mu.Lock()
@@ -96,8 +121,82 @@ func (c *Cache) Get(id string) (model.Order, bool) {
 	c.mu.Unlock()
 	return o, true
 }
+func (c *Cache) Delete(id string) {
+	c.mu.Lock()
+	delete(c.mem, id)
+	c.mu.Unlock()
+	if c.client != nil {
+		if err := c.client.Del(context.Background(), id).Err(); err != nil {
+			c.logger.Error("redis del failed", slog.String("key", id), slog.Any("err", err))
+		}
+	}
+}
+
 func (c *Cache) BulkSet(list []model.Order) {
 	for _, order := range list {
 		c.Set(order)
 	}
 }
+
+func (c *Cache) marshal(order model.Order) ([]byte, error) {
+	d, err := envelope.Seal(c.keyring, order.Delivery)
+	if err != nil {
+		return nil, err
+	}
+	order.Delivery = d
+	return json.Marshal(order)
+}
+
+func (c *Cache) unmarshal(data []byte) (model.Order, error) {
+	var o model.Order
+	if err := json.Unmarshal(data, &o); err != nil {
+		return model.Order{}, err
+	}
+	d, err := envelope.Open(c.keyring, o.Delivery)
+	if err != nil {
+		return model.Order{}, err
+	}
+	o.Delivery = d
+	return o, nil
+}
+
+// Reencrypt перезаписывает в Redis заказы, зашифрованные не основным ключом (или открытые),
+// сохраняя их TTL. Возвращает число перезаписанных ключей.
+func (c *Cache) Reencrypt(ctx context.Context) (int, error) {
+	if c.client == nil || c.keyring == nil {
+		return 0, nil
+	}
+	updated := 0
+	iter := c.client.Scan(ctx, 0, "*", 200).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		val, err := c.client.Get(ctx, key).Bytes()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return updated, err
+		}
+		var raw model.Order
+		if json.Unmarshal(val, &raw) != nil || raw.OrderUID != key {
+			continue
+		}
+		if !envelope.HasStale(c.keyring, raw.Delivery) {
+			continue
+		}
+		o, err := c.unmarshal(val)
+		if err != nil {
+			return updated, fmt.Errorf("redis key %s: %w", key, err)
+		}
+		data, err := c.marshal(o)
+		if err != nil {
+			return updated, err
+		}
+		if err := c.client.SetArgs(ctx, key, data, redis.SetArgs{KeepTTL: true, Mode: "XX"}).Err(); err != nil &&
+			!errors.Is(err, redis.Nil) {
+			return updated, err
+		}
+		updated++
+	}
+	return updated, iter.Err()
+}
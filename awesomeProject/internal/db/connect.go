@@ -27,15 +27,39 @@ func InitDB(logger *slog.Logger) (*sql.DB, error) {
 	connection := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
 		host, port, user, password, dbname)
 	var err error
-	db, err = sql.Open("postgres", connection)
+	db, err = Open(connection, logger)
+	return db, err
+}
+
+// Open подключается к Postgres по строке подключения и проверяет соединение.
+func Open(dsn string, logger *slog.Logger) (*sql.DB, error) {
+	conn, err := sql.Open("postgres", dsn)
 	if err != nil {
 		logger.Error("db open failed")
 		return nil, err
 	}
-	if err = db.Ping(); err != nil {
-		slog.Error("db ping failed")
+	if err = conn.Ping(); err != nil {
+		logger.Error("db ping failed")
+		_ = conn.Close()
 		return nil, err
 	}
 	logger.Info("successfully connected to database")
-	return db, nil
+	return conn, nil
+}
+
+// OpenShards подключается ко всем шардам карты (имя шарда — строка подключения). Если хоть
+// один недоступен, уже открытые соединения закрываются.
+func OpenShards(dsns map[string]string, logger *slog.Logger) (map[string]*sql.DB, error) {
+	out := make(map[string]*sql.DB, len(dsns))
+	for name, dsn := range dsns {
+		conn, err := Open(dsn, logger.With(slog.String("shard", name)))
+		if err != nil {
+			for _, c := range out {
+				_ = c.Close()
+			}
+			return nil, fmt.Errorf("shard %s: %w", name, err)
+		}
+		out[name] = conn
+	}
+	return out, nil
 }
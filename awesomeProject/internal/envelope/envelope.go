@@ -0,0 +1,242 @@
+// Package envelope шифрует отдельные строковые значения конвертным шифрованием: каждое
+// значение шифруется собственным случайным ключом данных (DEK), а DEK — ключом шифрования
+// ключей (KEK) из локального Keyring. ID ключа хранится рядом с шифротекстом, поэтому
+// старые значения читаются после ротации, а Keyring.Stale находит то, что нужно перешифровать.
+package envelope
+
+import (
+	"awesomeProject/internal/pii"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// prefix отличает шифротекст от открытого текста, записанного до включения шифрования.
+const prefix = "enc:v1:"
+
+const keySize = 32
+
+var (
+	ErrUnknownKey = errors.New("envelope: unknown key id")
+	ErrCorrupt    = errors.New("envelope: malformed or tampered ciphertext")
+)
+
+type Keyring struct {
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+// NewKeyring создаёт набор KEK; новые значения шифруются ключом primary.
+func NewKeyring(primary string, keys map[string][]byte) (*Keyring, error) {
+	k := &Keyring{primary: primary, keys: make(map[string]cipher.AEAD, len(keys))}
+	for id, raw := range keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("envelope: invalid key id %q", id)
+		}
+		if len(raw) != keySize {
+			return nil, fmt.Errorf("envelope: key %s must be %d bytes, got %d", id, keySize, len(raw))
+		}
+		aead, err := newAEAD(raw)
+		if err != nil {
+			return nil, err
+		}
+		k.keys[id] = aead
+	}
+	if _, ok := k.keys[primary]; !ok {
+		return nil, fmt.Errorf("envelope: primary key %q is not in the keyring", primary)
+	}
+	return k, nil
+}
+
+func (k *Keyring) Primary() string { return k.primary }
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Encrypt возвращает "enc:v1:<kid>:<wrapped DEK>:<ciphertext>" (base64url без паддинга).
+// Пустая строка не шифруется.
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	dek := make([]byte, keySize)
+	if _, err := rand.Read(dek); err != nil {
+		return "", err
+	}
+	kek := k.keys[k.primary]
+	wrapped := seal(kek, dek, []byte(k.primary))
+	data, err := newAEAD(dek)
+	if err != nil {
+		return "", err
+	}
+	ct := seal(data, []byte(plaintext), []byte(k.primary))
+	enc := base64.RawURLEncoding
+	return prefix + k.primary + ":" + enc.EncodeToString(wrapped) + ":" + enc.EncodeToString(ct), nil
+}
+
+// Decrypt расшифровывает значение, записанное Encrypt любым ключом из набора. Значение без
+// префикса считается открытым текстом, записанным до включения шифрования, и возвращается как есть.
+func (k *Keyring) Decrypt(value string) (string, error) {
+	rest, ok := strings.CutPrefix(value, prefix)
+	if !ok {
+		return value, nil
+	}
+	parts := strings.Split(rest, ":")
+	if len(parts) != 3 {
+		return "", ErrCorrupt
+	}
+	kek, ok := k.keys[parts[0]]
+	if !ok {
+		return "", fmt.Errorf("%w %q", ErrUnknownKey, parts[0])
+	}
+	enc := base64.RawURLEncoding
+	wrapped, err1 := enc.DecodeString(parts[1])
+	ct, err2 := enc.DecodeString(parts[2])
+	if err1 != nil || err2 != nil {
+		return "", ErrCorrupt
+	}
+	dek, err := open(kek, wrapped, []byte(parts[0]))
+	if err != nil {
+		return "", err
+	}
+	data, err := newAEAD(dek)
+	if err != nil {
+		return "", ErrCorrupt
+	}
+	pt, err := open(data, ct, []byte(parts[0]))
+	if err != nil {
+		return "", err
+	}
+	return string(pt), nil
+}
+
+// Stale сообщает, что значение нужно перешифровать: оно открытое или зашифровано не основным ключом.
+func (k *Keyring) Stale(value string) bool {
+	if value == "" {
+		return false
+	}
+	rest, ok := strings.CutPrefix(value, prefix)
+	if !ok {
+		return true
+	}
+	kid, _, _ := strings.Cut(rest, ":")
+	return kid != k.primary
+}
+
+func seal(aead cipher.AEAD, plaintext, aad []byte) []byte {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	_, _ = rand.Read(nonce)
+	return aead.Seal(nonce, nonce, plaintext, aad)
+}
+
+func open(aead cipher.AEAD, data, aad []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, ErrCorrupt
+	}
+	pt, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], aad)
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	return pt, nil
+}
+
+// Seal шифрует в копии v все строковые поля с тегом pii. С nil Keyring возвращает v без изменений.
+func Seal[T any](k *Keyring, v T) (T, error) {
+	if k == nil {
+		return v, nil
+	}
+	return pii.Transform(v, func(_, s string) (string, error) {
+		return k.Encrypt(s)
+	})
+}
+
+// Open расшифровывает в копии v все строковые поля с тегом pii.
+func Open[T any](k *Keyring, v T) (T, error) {
+	if k == nil {
+		return v, nil
+	}
+	return pii.Transform(v, func(_, s string) (string, error) {
+		return k.Decrypt(s)
+	})
+}
+
+// HasStale сообщает, есть ли в v поле с тегом pii, которое нужно перешифровать.
+func HasStale[T any](k *Keyring, v T) bool {
+	stale := false
+	_, _ = pii.Transform(v, func(_, s string) (string, error) {
+		stale = stale || k.Stale(s)
+		return s, nil
+	})
+	return stale
+}
+
+// Reseal перешифровывает устаревшее значение основным ключом; актуальное возвращается как есть.
+func (k *Keyring) Reseal(value string) (string, bool, error) {
+	if !k.Stale(value) {
+		return value, false, nil
+	}
+	pt, err := k.Decrypt(value)
+	if err != nil {
+		return "", false, err
+	}
+	out, err := k.Encrypt(pt)
+	return out, err == nil, err
+}
+
+type keyFile struct {
+	Primary string            `json:"primary"`
+	Keys    map[string]string `json:"keys"`
+}
+
+// FromEnv читает ключи из ENCRYPTION_KEY_FILE (JSON {"primary":"k2","keys":{"k1":"<base64>",...}})
+// или из ENCRYPTION_KEYS ("k1=<base64>,k2=<base64>") с ENCRYPTION_PRIMARY_KEY. Если ничего не
+// задано, возвращает nil: шифрование выключено.
+func FromEnv() (*Keyring, error) {
+	var kf keyFile
+	if path := os.Getenv("ENCRYPTION_KEY_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &kf); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	} else if spec := os.Getenv("ENCRYPTION_KEYS"); spec != "" {
+		kf.Keys = make(map[string]string)
+		for _, entry := range strings.Split(spec, ",") {
+			id, key, ok := strings.Cut(strings.TrimSpace(entry), "=")
+			if !ok {
+				return nil, fmt.Errorf("ENCRYPTION_KEYS: entry for %q: want id=<base64 key>", id)
+			}
+			kf.Keys[id] = key
+		}
+		kf.Primary = os.Getenv("ENCRYPTION_PRIMARY_KEY")
+	} else {
+		return nil, nil
+	}
+	keys := make(map[string][]byte, len(kf.Keys))
+	for id, b64 := range kf.Keys {
+		raw, err := base64.StdEncoding.DecodeString(b64)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %s: %w", id, err)
+		}
+		keys[id] = raw
+	}
+	if kf.Primary == "" && len(keys) == 1 {
+		for id := range keys {
+			kf.Primary = id
+		}
+	}
+	return NewKeyring(kf.Primary, keys)
+}
@@ -0,0 +1,221 @@
+package generator
+
+import (
+	"awesomeProject/internal/model"
+	"encoding/hex"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+)
+
+type region struct {
+	locale   model.Locale
+	currency string
+	phone    string
+	phoneLen int
+	cities   []string
+	regions  []string
+	streets  []string
+	names    []string
+	surnames []string
+}
+
+var regions = []region{
+	{
+		locale: model.LocaleRU, currency: "RUB", phone: "+79", phoneLen: 9,
+		cities:   []string{"Moscow", "Kazan", "Novosibirsk", "Yekaterinburg", "Samara"},
+		regions:  []string{"Moscow", "Tatarstan", "Novosibirsk Oblast", "Sverdlovsk Oblast", "Samara Oblast"},
+		streets:  []string{"Lenina", "Tverskaya", "Sadovaya", "Mira", "Gagarina"},
+		names:    []string{"Ivan", "Anna", "Sergey", "Olga", "Dmitry", "Maria"},
+		surnames: []string{"Ivanov", "Petrova", "Smirnov", "Kuznetsova", "Popov", "Sokolova"},
+	},
+	{
+		locale: model.LocaleEN, currency: "USD", phone: "+1", phoneLen: 10,
+		cities:   []string{"Boston", "Denver", "Austin", "Seattle", "Chicago"},
+		regions:  []string{"Massachusetts", "Colorado", "Texas", "Washington", "Illinois"},
+		streets:  []string{"Main St", "Oak Ave", "Maple Dr", "Pine St", "Elm St"},
+		names:    []string{"John", "Emily", "Michael", "Sarah", "David", "Laura"},
+		surnames: []string{"Smith", "Johnson", "Brown", "Miller", "Davis", "Wilson"},
+	},
+	{
+		locale: model.LocaleKK, currency: "KZT", phone: "+77", phoneLen: 9,
+		cities:   []string{"Almaty", "Astana", "Shymkent", "Karaganda"},
+		regions:  []string{"Almaty", "Astana", "Shymkent", "Karaganda Region"},
+		streets:  []string{"Abaya", "Dostyk", "Satpayeva", "Tole Bi"},
+		names:    []string{"Aidar", "Aigerim", "Nurlan", "Dana"},
+		surnames: []string{"Nurlanov", "Akhmetova", "Seitkali", "Omarova"},
+	},
+	{
+		locale: model.LocaleBE, currency: "BYN", phone: "+37529", phoneLen: 7,
+		cities:   []string{"Minsk", "Grodno", "Brest", "Gomel"},
+		regions:  []string{"Minsk", "Grodno Region", "Brest Region", "Gomel Region"},
+		streets:  []string{"Nezavisimosti", "Pobediteley", "Kalinovskogo", "Sovetskaya"},
+		names:    []string{"Alexei", "Hanna", "Pavel", "Iryna"},
+		surnames: []string{"Kovalev", "Novik", "Shevchuk", "Bondar"},
+	},
+	{
+		locale: model.LocaleHY, currency: "AMD", phone: "+374", phoneLen: 8,
+		cities:   []string{"Yerevan", "Gyumri", "Vanadzor"},
+		regions:  []string{"Yerevan", "Shirak", "Lori"},
+		streets:  []string{"Abovyan", "Mashtots", "Tigran Mets"},
+		names:    []string{"Armen", "Ani", "Tigran", "Lilit"},
+		surnames: []string{"Hakobyan", "Petrosyan", "Sargsyan", "Grigoryan"},
+	},
+}
+
+type product struct {
+	name  string
+	brand string
+	price int
+	sizes []string
+}
+
+var products = []product{
+	{"Mascaras", "Vivienne Sabo", 453, []string{"0"}},
+	{"Sneakers", "Nike", 8990, []string{"40", "41", "42", "43", "44"}},
+	{"T-shirt", "Uniqlo", 1290, []string{"S", "M", "L", "XL"}},
+	{"Backpack", "Xiaomi", 2490, []string{"0"}},
+	{"Jeans", "Levi's", 5990, []string{"28", "30", "32", "34"}},
+	{"Headphones", "JBL", 3490, []string{"0"}},
+	{"Lipstick", "Maybelline", 599, []string{"0"}},
+	{"Hoodie", "Adidas", 4590, []string{"S", "M", "L", "XL", "XXL"}},
+	{"Phone case", "Spigen", 990, []string{"0"}},
+	{"Kettle", "Bosch", 3290, []string{"0"}},
+}
+
+var (
+	entries   = []model.Entry{model.EntryWBIL, model.EntryWBRU, model.EntryWBKZ}
+	services  = []string{"meest", "cdek", "boxberry", "dpd", "wb"}
+	providers = []string{"wbpay", "sbp", "card"}
+	banks     = []string{"alpha", "sber", "tinkoff", "vtb", "kaspi"}
+	domains   = []string{"gmail.com", "mail.ru", "yandex.ru", "outlook.com"}
+	saleSteps = []int{0, 0, 5, 10, 15, 20, 25, 30, 50}
+)
+
+var baseTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+type Generator struct {
+	rnd      *rand.Rand
+	maxItems int
+}
+
+func New(seed uint64) *Generator {
+	return &Generator{
+		rnd:      rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15)),
+		maxItems: 5,
+	}
+}
+
+func (g *Generator) WithMaxItems(n int) *Generator {
+	if n > 0 {
+		g.maxItems = n
+	}
+	return g
+}
+
+func (g *Generator) Orders(n int) []model.Order {
+	out := make([]model.Order, 0, n)
+	for i := 0; i < n; i++ {
+		out = append(out, g.Order())
+	}
+	return out
+}
+
+func (g *Generator) Order() model.Order {
+	reg := regions[g.rnd.IntN(len(regions))]
+	uid := g.hex(8) + "test"
+	entry := pick(g.rnd, entries)
+	track := string(entry) + strings.ToUpper(g.hex(5)) + "TRACK"
+	created := baseTime.Add(time.Duration(g.rnd.IntN(730*24*3600)) * time.Second)
+
+	items := make([]model.Item, 0, g.maxItems)
+	goods := 0
+	seen := make(map[int]bool, g.maxItems)
+	for i, n := 0, 1+g.rnd.IntN(g.maxItems); i < n; i++ {
+		p := products[g.rnd.IntN(len(products))]
+		price := p.price + g.rnd.IntN(p.price/10+1)
+		sale := pick(g.rnd, saleSteps)
+		total := price * (100 - sale) / 100
+		goods += total
+		chrtID := 1_000_000 + g.rnd.IntN(9_000_000)
+		for seen[chrtID] {
+			chrtID++
+		}
+		seen[chrtID] = true
+		items = append(items, model.Item{
+			ChrtID:      chrtID,
+			TrackNumber: track,
+			Price:       price,
+			RID:         g.hex(9) + "test",
+			Name:        p.name,
+			Sale:        sale,
+			Size:        pick(g.rnd, p.sizes),
+			TotalPrice:  total,
+			NmID:        100_000 + g.rnd.IntN(9_900_000),
+			Brand:       p.brand,
+			Status:      model.ItemStatusAccepted,
+		})
+	}
+	deliveryCost := 500 + 100*g.rnd.IntN(16)
+	customFee := 0
+	if g.rnd.IntN(10) == 0 {
+		customFee = goods / 20
+	}
+
+	cityIdx := g.rnd.IntN(len(reg.cities))
+	name, surname := pick(g.rnd, reg.names), pick(g.rnd, reg.surnames)
+	return model.Order{
+		OrderUID:    uid,
+		TrackNumber: track,
+		Entry:       entry,
+		Delivery: model.Delivery{
+			Name:    name + " " + surname,
+			Phone:   reg.phone + g.digits(reg.phoneLen),
+			Zip:     g.digits(6),
+			City:    reg.cities[cityIdx],
+			Address: fmt.Sprintf("%s %d", pick(g.rnd, reg.streets), 1+g.rnd.IntN(120)),
+			Region:  reg.regions[cityIdx],
+			Email:   strings.ToLower(name+"."+surname) + "@" + pick(g.rnd, domains),
+		},
+		Payment: model.Payment{
+			Transaction:  uid,
+			Currency:     reg.currency,
+			Provider:     pick(g.rnd, providers),
+			Amount:       goods + deliveryCost + customFee,
+			PaymentTime:  model.UnixTime{Time: created.Add(time.Duration(g.rnd.IntN(600)) * time.Second)},
+			Bank:         pick(g.rnd, banks),
+			DeliveryCost: deliveryCost,
+			GoodsTotal:   goods,
+			CustomFee:    customFee,
+		},
+		Items:           items,
+		Locale:          reg.locale,
+		CustomerID:      strings.ToLower(surname) + g.digits(3),
+		DeliveryService: pick(g.rnd, services),
+		ShardKey:        fmt.Sprint(g.rnd.IntN(10)),
+		SmID:            1 + g.rnd.IntN(100),
+		DateCreated:     created,
+		OOFShard:        fmt.Sprint(1 + g.rnd.IntN(2)),
+	}
+}
+
+func (g *Generator) hex(n int) string {
+	buf := make([]byte, n)
+	for i := range buf {
+		buf[i] = byte(g.rnd.UintN(256))
+	}
+	return hex.EncodeToString(buf)
+}
+
+func (g *Generator) digits(n int) string {
+	var sb strings.Builder
+	for i := 0; i < n; i++ {
+		sb.WriteByte(byte('0' + g.rnd.IntN(10)))
+	}
+	return sb.String()
+}
+
+func pick[T any](r *rand.Rand, list []T) T {
+	return list[r.IntN(len(list))]
+}
@@ -0,0 +1,120 @@
+// Package lifecycle останавливает компоненты сервиса в порядке зависимостей: сначала те, что
+// принимают работу (HTTP, Kafka), потом те, на которые они опираются (кэш, БД). Остановка
+// укладывается в общий дедлайн; всё, что не успело, логируется как брошенное.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Hook останавливает один компонент. Stop должен вернуться, как только ctx отменён,
+// бросив недоделанную работу, и вернуть ctx.Err() — так Manager узнаёт, что было брошено.
+type Hook struct {
+	Name string
+	Stop func(ctx context.Context) error
+}
+
+type phase struct {
+	name  string
+	hooks []Hook
+}
+
+type Manager struct {
+	logger   *slog.Logger
+	deadline time.Duration
+	phases   []phase
+}
+
+// New создаёт менеджер с общим дедлайном остановки; нулевой дедлайн — без ограничения.
+func New(logger *slog.Logger, deadline time.Duration) *Manager {
+	return &Manager{logger: logger, deadline: deadline}
+}
+
+// Phase добавляет фазу остановки. Фазы выполняются по очереди в порядке добавления, хуки
+// одной фазы — параллельно; следующая фаза начинается, когда завершились все хуки предыдущей.
+func (m *Manager) Phase(name string, hooks ...Hook) *Manager {
+	m.phases = append(m.phases, phase{name: name, hooks: hooks})
+	return m
+}
+
+// Shutdown выполняет фазы в пределах дедлайна. Хук, не уложившийся в дедлайн, логируется
+// как брошенный, и Shutdown его не ждёт; последующие фазы всё равно выполняются с уже
+// истёкшим контекстом, чтобы закрыть то, что закрывается без ожидания (соединения, файлы).
+func (m *Manager) Shutdown(ctx context.Context) error {
+	if m.deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.deadline)
+		defer cancel()
+	}
+	start := time.Now()
+	var errs []error
+	for _, p := range m.phases {
+		errs = append(errs, m.run(ctx, p)...)
+	}
+	err := errors.Join(errs...)
+	if err != nil {
+		m.logger.Error("shutdown finished with errors", slog.Duration("took", time.Since(start)), slog.Any("err", err))
+		return err
+	}
+	m.logger.Info("shutdown complete", slog.Duration("took", time.Since(start)))
+	return nil
+}
+
+// abandonGrace — сколько ждать хуки после истечения дедлайна, прежде чем бросить их.
+const abandonGrace = 100 * time.Millisecond
+
+type result struct {
+	hook string
+	err  error
+	took time.Duration
+}
+
+func (m *Manager) run(ctx context.Context, p phase) []error {
+	results := make(chan result, len(p.hooks))
+	pending := make(map[string]bool, len(p.hooks))
+	for _, h := range p.hooks {
+		pending[h.Name] = true
+		go func() {
+			start := time.Now()
+			err := h.Stop(ctx)
+			results <- result{hook: h.Name, err: err, took: time.Since(start)}
+		}()
+	}
+
+	var errs []error
+	done, grace := ctx.Done(), (<-chan time.Time)(nil)
+	for len(pending) > 0 {
+		select {
+		case r := <-results:
+			delete(pending, r.hook)
+			attrs := []any{slog.String("phase", p.name), slog.String("component", r.hook), slog.Duration("took", r.took)}
+			switch {
+			case r.err == nil:
+				m.logger.Info("component stopped", attrs...)
+			case errors.Is(r.err, context.DeadlineExceeded) || errors.Is(r.err, context.Canceled):
+				m.logger.Warn("component abandoned: shutdown deadline exceeded", append(attrs, slog.Any("err", r.err))...)
+				errs = append(errs, fmt.Errorf("%s/%s: %w", p.name, r.hook, r.err))
+			default:
+				m.logger.Error("component stop failed", append(attrs, slog.Any("err", r.err))...)
+				errs = append(errs, fmt.Errorf("%s/%s: %w", p.name, r.hook, r.err))
+			}
+		case <-done:
+			// Даём хукам немного времени заметить отмену и отчитаться самим.
+			done, grace = nil, time.After(abandonGrace)
+		case <-grace:
+			for _, h := range p.hooks {
+				if pending[h.Name] {
+					m.logger.Warn("component abandoned: still running at shutdown deadline",
+						slog.String("phase", p.name), slog.String("component", h.Name))
+					errs = append(errs, fmt.Errorf("%s/%s: %w", p.name, h.Name, ctx.Err()))
+				}
+			}
+			return errs
+		}
+	}
+	return errs
+}
@@ -0,0 +1,71 @@
+package loadtest
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+type Percentiles struct {
+	Count int           `json:"count"`
+	P50   time.Duration `json:"p50"`
+	P95   time.Duration `json:"p95"`
+	P99   time.Duration `json:"p99"`
+	Max   time.Duration `json:"max"`
+}
+
+type Histogram struct {
+	mu     sync.Mutex
+	window []time.Duration
+	all    []time.Duration
+}
+
+func NewHistogram() *Histogram {
+	return &Histogram{}
+}
+
+func (h *Histogram) Add(d time.Duration) {
+	h.mu.Lock()
+	h.window = append(h.window, d)
+	h.all = append(h.all, d)
+	h.mu.Unlock()
+}
+
+func (h *Histogram) Window() Percentiles {
+	h.mu.Lock()
+	w := h.window
+	h.window = nil
+	h.mu.Unlock()
+	return percentiles(w)
+}
+
+func (h *Histogram) Total() Percentiles {
+	h.mu.Lock()
+	all := slices.Clone(h.all)
+	h.mu.Unlock()
+	return percentiles(all)
+}
+
+func percentiles(samples []time.Duration) Percentiles {
+	if len(samples) == 0 {
+		return Percentiles{}
+	}
+	slices.Sort(samples)
+	at := func(q float64) time.Duration {
+		i := int(q*float64(len(samples))+0.5) - 1
+		if i < 0 {
+			i = 0
+		}
+		if i >= len(samples) {
+			i = len(samples) - 1
+		}
+		return samples[i]
+	}
+	return Percentiles{
+		Count: len(samples),
+		P50:   at(0.50),
+		P95:   at(0.95),
+		P99:   at(0.99),
+		Max:   samples[len(samples)-1],
+	}
+}
@@ -0,0 +1,298 @@
+package loadtest
+
+import (
+	"awesomeProject/internal/generator"
+	"awesomeProject/internal/model"
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Env interface {
+	Produce(ctx context.Context, order model.Order) error
+	Get(ctx context.Context, id string) (found bool, err error)
+	Stats() EnvStats
+	IngestLatency() *Histogram
+}
+
+type EnvStats struct {
+	Ingested    int64
+	Lag         int64
+	CacheHits   int64
+	CacheMisses int64
+}
+
+type Config struct {
+	Duration       time.Duration
+	ProduceRate    int
+	ReadRate       int
+	Readers        int
+	MissRatio      float64
+	ReportInterval time.Duration
+	Seed           uint64
+}
+
+func (c Config) withDefaults() Config {
+	if c.Duration <= 0 {
+		c.Duration = 10 * time.Second
+	}
+	if c.Readers <= 0 {
+		c.Readers = 8
+	}
+	if c.ReportInterval <= 0 {
+		c.ReportInterval = time.Second
+	}
+	if c.Seed == 0 {
+		c.Seed = 1
+	}
+	return c
+}
+
+type Sample struct {
+	Elapsed       time.Duration `json:"elapsed"`
+	Produced      int64         `json:"produced"`
+	Ingested      int64         `json:"ingested"`
+	Lag           int64         `json:"lag"`
+	IngestPerSec  float64       `json:"ingest_per_sec"`
+	ReadsPerSec   float64       `json:"reads_per_sec"`
+	ReadErrors    int64         `json:"read_errors"`
+	CacheHitRatio float64       `json:"cache_hit_ratio"`
+	Read          Percentiles   `json:"read_latency"`
+	Ingest        Percentiles   `json:"ingest_latency"`
+}
+
+type Report struct {
+	Duration       time.Duration `json:"duration"`
+	Produced       int64         `json:"produced"`
+	ProduceErrors  int64         `json:"produce_errors"`
+	ProduceDropped int64         `json:"produce_dropped"`
+	Ingested       int64         `json:"ingested"`
+	Reads          int64         `json:"reads"`
+	ReadNotFound   int64         `json:"read_not_found"`
+	ReadErrors     int64         `json:"read_errors"`
+	IngestPerSec   float64       `json:"ingest_per_sec"`
+	ReadsPerSec    float64       `json:"reads_per_sec"`
+	CacheHitRatio  float64       `json:"cache_hit_ratio"`
+	FinalLag       int64         `json:"final_lag"`
+	ReadLatency    Percentiles   `json:"read_latency"`
+	IngestLatency  Percentiles   `json:"ingest_latency"`
+	Timeline       []Sample      `json:"timeline"`
+}
+
+type runner struct {
+	cfg    Config
+	env    Env
+	logger *slog.Logger
+
+	produced      atomic.Int64
+	produceErrors atomic.Int64
+	dropped       atomic.Int64
+	reads         atomic.Int64
+	notFound      atomic.Int64
+	readErrors    atomic.Int64
+	readLatency   *Histogram
+
+	mu  sync.RWMutex
+	ids []string
+}
+
+func Run(ctx context.Context, cfg Config, env Env, logger *slog.Logger) (Report, error) {
+	cfg = cfg.withDefaults()
+	if cfg.ProduceRate <= 0 && cfg.ReadRate <= 0 {
+		return Report{}, errors.New("loadtest: both produce and read rates are zero")
+	}
+	r := &runner{cfg: cfg, env: env, logger: logger, readLatency: NewHistogram()}
+	ctx, cancel := context.WithTimeout(ctx, cfg.Duration)
+	defer cancel()
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	if cfg.ProduceRate > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.produce(ctx)
+		}()
+	}
+	if cfg.ReadRate > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.read(ctx)
+		}()
+	}
+	timeline := r.report(ctx, start)
+	wg.Wait()
+
+	elapsed := time.Since(start)
+	st := env.Stats()
+	rep := Report{
+		Duration:       elapsed,
+		Produced:       r.produced.Load(),
+		ProduceErrors:  r.produceErrors.Load(),
+		ProduceDropped: r.dropped.Load(),
+		Ingested:       st.Ingested,
+		Reads:          r.reads.Load(),
+		ReadNotFound:   r.notFound.Load(),
+		ReadErrors:     r.readErrors.Load(),
+		IngestPerSec:   float64(st.Ingested) / elapsed.Seconds(),
+		ReadsPerSec:    float64(r.reads.Load()) / elapsed.Seconds(),
+		CacheHitRatio:  ratio(st.CacheHits, st.CacheMisses),
+		FinalLag:       st.Lag,
+		ReadLatency:    r.readLatency.Total(),
+		IngestLatency:  env.IngestLatency().Total(),
+		Timeline:       timeline,
+	}
+	return rep, nil
+}
+
+func (r *runner) produce(ctx context.Context) {
+	gen := generator.New(r.cfg.Seed)
+	interval := time.Second / time.Duration(r.cfg.ProduceRate)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	inflight := make(chan struct{}, 64)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		order := gen.Order()
+		select {
+		case inflight <- struct{}{}:
+		default:
+			r.dropped.Add(1)
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-inflight }()
+			if err := r.env.Produce(ctx, order); err != nil {
+				if ctx.Err() == nil {
+					r.produceErrors.Add(1)
+					r.logger.Debug("loadtest produce failed", slog.Any("err", err))
+				}
+				return
+			}
+			r.produced.Add(1)
+			r.mu.Lock()
+			r.ids = append(r.ids, order.OrderUID)
+			r.mu.Unlock()
+		}()
+	}
+}
+
+func (r *runner) read(ctx context.Context) {
+	jobs := make(chan string, r.cfg.Readers)
+	var wg sync.WaitGroup
+	for i := 0; i < r.cfg.Readers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range jobs {
+				t0 := time.Now()
+				found, err := r.env.Get(ctx, id)
+				if err != nil {
+					if ctx.Err() == nil {
+						r.readErrors.Add(1)
+					}
+					continue
+				}
+				r.readLatency.Add(time.Since(t0))
+				r.reads.Add(1)
+				if !found {
+					r.notFound.Add(1)
+				}
+			}
+		}()
+	}
+	rnd := rand.New(rand.NewPCG(r.cfg.Seed, r.cfg.Seed+1))
+	gen := generator.New(r.cfg.Seed ^ 0xfeed)
+	ticker := time.NewTicker(time.Second / time.Duration(r.cfg.ReadRate))
+	defer ticker.Stop()
+	defer wg.Wait()
+	defer close(jobs)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		id := r.pickID(rnd, gen)
+		select {
+		case jobs <- id:
+		default:
+		}
+	}
+}
+
+func (r *runner) pickID(rnd *rand.Rand, gen *generator.Generator) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.ids) == 0 || rnd.Float64() < r.cfg.MissRatio {
+		return gen.Order().OrderUID
+	}
+	return r.ids[rnd.IntN(len(r.ids))]
+}
+
+func (r *runner) report(ctx context.Context, start time.Time) []Sample {
+	ticker := time.NewTicker(r.cfg.ReportInterval)
+	defer ticker.Stop()
+	var (
+		timeline             []Sample
+		lastIngested, lastRd int64
+		lastHits, lastMisses int64
+		lastAt               = start
+	)
+	for {
+		select {
+		case <-ctx.Done():
+			return timeline
+		case now := <-ticker.C:
+			st := r.env.Stats()
+			reads := r.reads.Load()
+			secs := now.Sub(lastAt).Seconds()
+			s := Sample{
+				Elapsed:       now.Sub(start).Truncate(time.Millisecond),
+				Produced:      r.produced.Load(),
+				Ingested:      st.Ingested,
+				Lag:           st.Lag,
+				IngestPerSec:  float64(st.Ingested-lastIngested) / secs,
+				ReadsPerSec:   float64(reads-lastRd) / secs,
+				ReadErrors:    r.readErrors.Load(),
+				CacheHitRatio: ratio(st.CacheHits-lastHits, st.CacheMisses-lastMisses),
+				Read:          r.readLatency.Window(),
+				Ingest:        r.env.IngestLatency().Window(),
+			}
+			timeline = append(timeline, s)
+			r.logger.Info("loadtest",
+				slog.Duration("elapsed", s.Elapsed),
+				slog.Int64("produced", s.Produced),
+				slog.Int64("ingested", s.Ingested),
+				slog.Int64("lag", s.Lag),
+				slog.Float64("ingest_per_sec", s.IngestPerSec),
+				slog.Float64("reads_per_sec", s.ReadsPerSec),
+				slog.Float64("cache_hit_ratio", s.CacheHitRatio),
+				slog.Duration("read_p50", s.Read.P50),
+				slog.Duration("read_p99", s.Read.P99),
+				slog.Duration("ingest_p99", s.Ingest.P99),
+			)
+			lastIngested, lastRd, lastHits, lastMisses, lastAt = st.Ingested, reads, st.CacheHits, st.CacheMisses, now
+		}
+	}
+}
+
+func ratio(hits, misses int64) float64 {
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
@@ -0,0 +1,341 @@
+package loadtest
+
+import (
+	"awesomeProject/internal/api"
+	"awesomeProject/internal/model"
+	"awesomeProject/internal/repository"
+	"awesomeProject/internal/service"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type LocalConfig struct {
+	DBLatency time.Duration
+	Consumers int
+	QueueSize int
+	ColdCache bool
+}
+
+type Local struct {
+	cfg     LocalConfig
+	queue   chan queued
+	srv     *httptest.Server
+	client  *http.Client
+	cache   *countingCache
+	latency *Histogram
+
+	produced atomic.Int64
+	ingested atomic.Int64
+	wg       sync.WaitGroup
+	cancel   context.CancelFunc
+}
+
+type queued struct {
+	order model.Order
+	at    time.Time
+}
+
+func NewLocal(cfg LocalConfig, logger *slog.Logger) *Local {
+	if cfg.Consumers <= 0 {
+		cfg.Consumers = 1
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 10_000
+	}
+	quiet := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cache := &countingCache{mem: make(map[string]model.Order), cold: cfg.ColdCache}
+	svc := service.NewService(&memRepo{orders: make(map[string]model.Order), latency: cfg.DBLatency}, cache, quiet)
+	srv := httptest.NewServer(api.NewRouter(svc, api.Config{}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	l := &Local{
+		cfg:     cfg,
+		queue:   make(chan queued, cfg.QueueSize),
+		srv:     srv,
+		client:  srv.Client(),
+		cache:   cache,
+		latency: NewHistogram(),
+		cancel:  cancel,
+	}
+	for i := 0; i < cfg.Consumers; i++ {
+		l.wg.Add(1)
+		go l.consume(ctx, svc, logger)
+	}
+	return l
+}
+
+func (l *Local) consume(ctx context.Context, svc *service.Service, logger *slog.Logger) {
+	defer l.wg.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case m := <-l.queue:
+			if _, err := svc.UpsertOrder(ctx, m.order); err != nil {
+				if ctx.Err() == nil {
+					logger.Error("loadtest consumer upsert failed", slog.Any("err", err))
+				}
+				continue
+			}
+			l.ingested.Add(1)
+			l.latency.Add(time.Since(m.at))
+		}
+	}
+}
+
+func (l *Local) Produce(ctx context.Context, order model.Order) error {
+	select {
+	case l.queue <- queued{order: order, at: time.Now()}:
+		l.produced.Add(1)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *Local) Get(ctx context.Context, id string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.srv.URL+"/api/v1/orders/"+url.PathEscape(id), nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+}
+
+func (l *Local) Stats() EnvStats {
+	ingested := l.ingested.Load()
+	return EnvStats{
+		Ingested:    ingested,
+		Lag:         l.produced.Load() - ingested,
+		CacheHits:   l.cache.hits.Load(),
+		CacheMisses: l.cache.misses.Load(),
+	}
+}
+
+func (l *Local) IngestLatency() *Histogram {
+	return l.latency
+}
+
+func (l *Local) Close() {
+	l.cancel()
+	l.wg.Wait()
+	l.srv.Close()
+}
+
+type memRepo struct {
+	mu      sync.RWMutex
+	orders  map[string]model.Order
+	history map[string][]model.StatusChange
+	latency time.Duration
+}
+
+func (r *memRepo) wait(ctx context.Context) error {
+	if r.latency <= 0 {
+		return nil
+	}
+	t := time.NewTimer(r.latency)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *memRepo) InsertOrder(ctx context.Context, order model.Order) (bool, error) {
+	if err := r.wait(ctx); err != nil {
+		return false, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.orders[order.OrderUID]; exists {
+		return false, nil
+	}
+	r.orders[order.OrderUID] = order
+	return true, nil
+}
+
+func (r *memRepo) ReplaceOrder(ctx context.Context, order model.Order) (bool, error) {
+	if err := r.wait(ctx); err != nil {
+		return false, err
+	}
+	r.mu.Lock()
+	_, exists := r.orders[order.OrderUID]
+	r.orders[order.OrderUID] = order
+	r.mu.Unlock()
+	return !exists, nil
+}
+
+func (r *memRepo) UpdateOrder(ctx context.Context, id string,
+	update func(model.Order) (model.Order, error)) (model.Order, error) {
+	if err := r.wait(ctx); err != nil {
+		return model.Order{}, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	current, ok := r.orders[id]
+	if !ok {
+		return model.Order{}, repository.ErrNotFound
+	}
+	order, err := update(current)
+	if err != nil {
+		return model.Order{}, err
+	}
+	r.orders[id] = order
+	return order, nil
+}
+
+func (r *memRepo) ReplaceOrders(ctx context.Context, orders []model.Order) ([]bool, error) {
+	created := make([]bool, len(orders))
+	for i, o := range orders {
+		var err error
+		if created[i], err = r.ReplaceOrder(ctx, o); err != nil {
+			return nil, err
+		}
+	}
+	return created, nil
+}
+
+func (r *memRepo) DeleteOrder(ctx context.Context, id string) error {
+	if err := r.wait(ctx); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.orders[id]; !ok {
+		return repository.ErrNotFound
+	}
+	delete(r.orders, id)
+	delete(r.history, id)
+	return nil
+}
+
+func (r *memRepo) ChangeStatus(ctx context.Context, change model.StatusChange) (model.StatusChange, error) {
+	if err := r.wait(ctx); err != nil {
+		return change, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.orders[change.OrderUID]; !ok {
+		return change, repository.ErrNotFound
+	}
+	change.From = model.StatusCreated
+	if h := r.history[change.OrderUID]; len(h) > 0 {
+		change.From = h[len(h)-1].To
+	}
+	if !change.From.CanTransitionTo(change.To) {
+		return change, fmt.Errorf("%w: %s -> %s", model.ErrInvalidTransition, change.From, change.To)
+	}
+	if change.ChangedAt.IsZero() {
+		change.ChangedAt = time.Now().UTC()
+	}
+	if r.history == nil {
+		r.history = make(map[string][]model.StatusChange)
+	}
+	r.history[change.OrderUID] = append(r.history[change.OrderUID], change)
+	return change, nil
+}
+
+func (r *memRepo) StatusHistory(ctx context.Context, id string) (model.StatusHistory, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if _, ok := r.orders[id]; !ok {
+		return model.StatusHistory{}, repository.ErrNotFound
+	}
+	h := model.StatusHistory{OrderUID: id, Status: model.StatusCreated, History: slices.Clone(r.history[id])}
+	if len(h.History) > 0 {
+		h.Status = h.History[len(h.History)-1].To
+	}
+	return h, nil
+}
+
+func (r *memRepo) GetOrderById(ctx context.Context, id string) (model.Order, error) {
+	if err := r.wait(ctx); err != nil {
+		return model.Order{}, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	o, ok := r.orders[id]
+	if !ok {
+		return model.Order{}, repository.ErrNotFound
+	}
+	return o, nil
+}
+
+// OrderHistory: журнал изменений нагрузочному стенду не нужен и не ведётся.
+func (r *memRepo) OrderHistory(ctx context.Context, id string) ([]model.AuditRecord, error) {
+	return nil, repository.ErrNotFound
+}
+
+func (r *memRepo) LoadAll(ctx context.Context) ([]model.Order, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]model.Order, 0, len(r.orders))
+	for _, o := range r.orders {
+		out = append(out, o)
+	}
+	return out, nil
+}
+
+type countingCache struct {
+	mu     sync.RWMutex
+	mem    map[string]model.Order
+	cold   bool
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+func (c *countingCache) Get(id string) (model.Order, bool) {
+	c.mu.RLock()
+	o, ok := c.mem[id]
+	c.mu.RUnlock()
+	if ok {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+	return o, ok
+}
+
+func (c *countingCache) Set(o model.Order) {
+	if c.cold {
+		return
+	}
+	c.mu.Lock()
+	c.mem[o.OrderUID] = o
+	c.mu.Unlock()
+}
+
+func (c *countingCache) Delete(id string) {
+	c.mu.Lock()
+	delete(c.mem, id)
+	c.mu.Unlock()
+}
+
+func (c *countingCache) BulkSet(list []model.Order) {
+	for _, o := range list {
+		c.Set(o)
+	}
+}
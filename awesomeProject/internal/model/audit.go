@@ -0,0 +1,116 @@
+package model
+
+import (
+	"awesomeProject/internal/pii"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"time"
+)
+
+// Действия, которые попадают в журнал изменений заказа.
+const (
+	AuditCreated = "created"
+	AuditUpdated = "updated"
+	AuditDeleted = "deleted"
+)
+
+// AuditRecord — запись журнала изменений: заказ до и после изменения, список изменённых
+// полей и источник. Source имеет тот же формат, что у StatusChange; "system" — изменение
+// не из API и не из Kafka (утилиты, миграции данных).
+type AuditRecord struct {
+	ID        int64         `json:"id"`
+	OrderUID  string        `json:"order_uid"`
+	Action    string        `json:"action"`
+	Source    string        `json:"source"`
+	Before    *Order        `json:"before,omitempty"`
+	After     *Order        `json:"after,omitempty"`
+	Diff      []FieldChange `json:"diff,omitempty"`
+	ChangedAt time.Time     `json:"changed_at"`
+}
+
+// FieldChange — изменение одного значения: путь в JSON заказа ("payment.amount",
+// "items[1].price") и значения до и после. Нет Old — значение добавлено, нет New — удалено.
+// Персональные данные доставки в Old и New замаскированы, как в логах.
+type FieldChange struct {
+	Path string `json:"path"`
+	Old  any    `json:"old,omitempty"`
+	New  any    `json:"new,omitempty"`
+}
+
+// Diff сравнивает два заказа поле за полем в их JSON-представлении. Элементы items
+// сравниваются по позиции.
+func Diff(before, after Order) ([]FieldChange, error) {
+	var docs [4]any
+	for i, o := range []Order{before, after, pii.Redact(before), pii.Redact(after)} {
+		data, err := json.Marshal(o)
+		if err != nil {
+			return nil, err
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&docs[i]); err != nil {
+			return nil, err
+		}
+	}
+	var out []FieldChange
+	diffValues(&out, "", docs[0], docs[1], docs[2], docs[3])
+	return out, nil
+}
+
+// diffValues ищет различия в a и b, а значения для отчёта берёт из их замаскированных
+// копий ra и rb: маскировка не меняет структуру документа, только строки с PII.
+func diffValues(out *[]FieldChange, path string, a, b, ra, rb any) {
+	switch x := a.(type) {
+	case map[string]any:
+		y, ok := b.(map[string]any)
+		if !ok {
+			break
+		}
+		rx, ry := ra.(map[string]any), rb.(map[string]any)
+		keys := make([]string, 0, len(x)+len(y))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		for k := range y {
+			if _, ok := x[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			diffValues(out, join(path, k), x[k], y[k], rx[k], ry[k])
+		}
+		return
+	case []any:
+		y, ok := b.([]any)
+		if !ok {
+			break
+		}
+		rx, ry := ra.([]any), rb.([]any)
+		for i := range max(len(x), len(y)) {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(y):
+				*out = append(*out, FieldChange{Path: p, Old: rx[i]})
+			case i >= len(x):
+				*out = append(*out, FieldChange{Path: p, New: ry[i]})
+			default:
+				diffValues(out, p, x[i], y[i], rx[i], ry[i])
+			}
+		}
+		return
+	}
+	if !reflect.DeepEqual(a, b) {
+		*out = append(*out, FieldChange{Path: path, Old: ra, New: rb})
+	}
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
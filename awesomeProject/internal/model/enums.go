@@ -0,0 +1,30 @@
+package model
+
+// Значения перечислений приходят от внешних систем, поэтому неизвестные значения не отклоняются:
+// константы перечисляют те, с которыми сервис работает сейчас.
+
+// Entry — код системы, через которую оформлен заказ; с него же начинается трек-номер.
+type Entry string
+
+const (
+	EntryWBIL Entry = "WBIL"
+	EntryWBRU Entry = "WBRU"
+	EntryWBKZ Entry = "WBKZ"
+)
+
+// Locale — язык покупателя, код ISO 639-1.
+type Locale string
+
+const (
+	LocaleRU Locale = "ru"
+	LocaleEN Locale = "en"
+	LocaleKK Locale = "kk"
+	LocaleBE Locale = "be"
+	LocaleHY Locale = "hy"
+)
+
+// ItemStatus — код статуса позиции, который присылает склад.
+type ItemStatus int
+
+// ItemStatusAccepted — позиция принята в сборку; так помечены позиции новых заказов.
+const ItemStatusAccepted ItemStatus = 202
@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// Типы событий заказа, которые сервис публикует для внешних потребителей.
+const (
+	EventOrderCreated = "OrderCreated"
+	EventOrderUpdated = "OrderUpdated"
+	EventOrderDeleted = "OrderDeleted"
+)
+
+// OrderEvent — тело события в топике событий заказов. Order заполнен для OrderCreated и
+// OrderUpdated; персональные данные доставки в нём зашифрованы так же, как в БД. Status
+// заполнен для OrderStatusChanged.
+type OrderEvent struct {
+	Type       string        `json:"type"`
+	OrderUID   string        `json:"order_uid"`
+	OccurredAt time.Time     `json:"occurred_at"`
+	Order      *Order        `json:"order,omitempty"`
+	Status     *StatusChange `json:"status,omitempty"`
+}
@@ -0,0 +1,29 @@
+package model
+
+import "awesomeProject/internal/money"
+
+// Суммы Payment и Item — целые числа в минимальных единицах валюты оплаты по ISO 4217
+// (копейки для RUB, центы для USD, иены для JPY). Своей валюты у позиций нет: все суммы заказа
+// считаются в Payment.Currency.
+
+// Total возвращает сумму оплаты с её валютой.
+func (p Payment) Total() money.Money {
+	return money.Money{Amount: int64(p.Amount), Currency: p.Currency}
+}
+
+// ItemsTotal складывает total_price позиций в валюте оплаты; переполнение — ошибка.
+func (o Order) ItemsTotal() (money.Money, error) {
+	list := make([]money.Money, len(o.Items))
+	for i, item := range o.Items {
+		list[i] = money.Money{Amount: int64(item.TotalPrice), Currency: o.Payment.Currency}
+	}
+	return money.Sum(o.Payment.Currency, list...)
+}
+
+// CurrencyRevenue — выручка в одной валюте за период: число заказов и сумма оплат в минимальных
+// единицах.
+type CurrencyRevenue struct {
+	Currency string
+	Orders   int
+	Amount   int64
+}
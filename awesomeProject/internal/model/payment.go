@@ -0,0 +1,47 @@
+package model
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// UnixTime — время, которое передаётся в JSON и хранится в БД целыми секундами Unix.
+// Нулевое время — 0, и наоборот. Своё (раз)маршалирование только у самого поля, поэтому
+// структура с ним разбирается обычным encoding/json, в том числе с DisallowUnknownFields.
+type UnixTime struct {
+	time.Time
+}
+
+// FromUnixSeconds — время из секунд Unix в UTC; 0 даёт нулевое время.
+func FromUnixSeconds(sec int64) UnixTime {
+	if sec == 0 {
+		return UnixTime{}
+	}
+	return UnixTime{time.Unix(sec, 0).UTC()}
+}
+
+// Seconds возвращает время в секундах Unix; нулевое время — 0.
+func (t UnixTime) Seconds() int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func (t UnixTime) MarshalJSON() ([]byte, error) {
+	return strconv.AppendInt(nil, t.Seconds(), 10), nil
+}
+
+// UnmarshalJSON принимает целое число секунд; null, как у encoding/json, оставляет t без изменений.
+func (t *UnixTime) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var sec int64
+	if err := json.Unmarshal(data, &sec); err != nil {
+		return err
+	}
+	*t = FromUnixSeconds(sec)
+	return nil
+}
@@ -0,0 +1,72 @@
+package model
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+// OrderStatus — статус заказа в жизненном цикле от создания до доставки или возврата.
+type OrderStatus string
+
+const (
+	StatusCreated   OrderStatus = "created"
+	StatusPaid      OrderStatus = "paid"
+	StatusAssembled OrderStatus = "assembled"
+	StatusShipped   OrderStatus = "shipped"
+	StatusDelivered OrderStatus = "delivered"
+	StatusCancelled OrderStatus = "cancelled"
+	StatusReturned  OrderStatus = "returned"
+)
+
+// EventOrderStatusChanged — тип события о смене статуса: сервис публикует его в outbox и
+// принимает из Kafka от внешних систем (оплата, склад, доставка).
+const EventOrderStatusChanged = "OrderStatusChanged"
+
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+// transitions — разрешённые переходы. Отменить можно только ещё не отправленный заказ,
+// вернуть — отправленный или доставленный; cancelled и returned конечные.
+var transitions = map[OrderStatus][]OrderStatus{
+	StatusCreated:   {StatusPaid, StatusCancelled},
+	StatusPaid:      {StatusAssembled, StatusCancelled},
+	StatusAssembled: {StatusShipped, StatusCancelled},
+	StatusShipped:   {StatusDelivered, StatusReturned},
+	StatusDelivered: {StatusReturned},
+	StatusCancelled: nil,
+	StatusReturned:  nil,
+}
+
+func (s OrderStatus) Valid() bool {
+	_, ok := transitions[s]
+	return ok
+}
+
+// CanTransitionTo сообщает, разрешён ли переход из s в next. Переход в тот же статус
+// запрещён: повторное событие не должно дублировать запись в истории.
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	return slices.Contains(transitions[s], next)
+}
+
+// Final сообщает, что из статуса нет переходов.
+func (s OrderStatus) Final() bool {
+	return s.Valid() && len(transitions[s]) == 0
+}
+
+// StatusChange — запись истории статусов. Source — кто сменил статус: "http:sub:<subject>"
+// или "http:ip:<адрес>" для API, "kafka:<topic>/<partition>@<offset>" для событий из Kafka.
+type StatusChange struct {
+	OrderUID  string      `json:"order_uid"`
+	From      OrderStatus `json:"from,omitempty"`
+	To        OrderStatus `json:"status"`
+	Source    string      `json:"source,omitempty"`
+	Reason    string      `json:"reason,omitempty"`
+	ChangedAt time.Time   `json:"changed_at"`
+}
+
+// StatusHistory — текущий статус заказа и все смены статуса в порядке записи.
+type StatusHistory struct {
+	OrderUID string         `json:"order_uid"`
+	Status   OrderStatus    `json:"status"`
+	History  []StatusChange `json:"history"`
+}
@@ -1,57 +1,77 @@
 package model
 
-import "time"
+import (
+	"awesomeProject/internal/pii"
+	"log/slog"
+	"time"
+)
 
 type Order struct {
-	Order_uid          string    `json:"order_uid"`
-	Track_number       string    `json:"track_number"`
-	Entry              string    `json:"entry"`
-	Delivery           Delivery  `json:"delivery"`
-	Payment            Payment   `json:"payment"`
-	Items              []Items   `json:"items"`
-	Locale             string    `json:"locale"`
-	Internal_signature string    `json:"internal_signature"`
-	Customer_id        string    `json:"customer_id"`
-	Delivery_service   string    `json:"delivery_service"`
-	Shardkey           string    `json:"shardkey"`
-	Sm_id              int       `json:"sm_id"`
-	Date_created       time.Time `json:"date_created"`
-	Oof_shard          string    `json:"oof_shard"`
+	OrderUID          string    `json:"order_uid"`
+	TrackNumber       string    `json:"track_number"`
+	Entry             Entry     `json:"entry"`
+	Delivery          Delivery  `json:"delivery"`
+	Payment           Payment   `json:"payment"`
+	Items             []Item    `json:"items"`
+	Locale            Locale    `json:"locale"`
+	InternalSignature string    `json:"internal_signature"`
+	CustomerID        string    `json:"customer_id"`
+	DeliveryService   string    `json:"delivery_service"`
+	ShardKey          string    `json:"shardkey"`
+	SmID              int       `json:"sm_id"`
+	DateCreated       time.Time `json:"date_created"`
+	OOFShard          string    `json:"oof_shard"`
 }
 
 type Delivery struct {
-	Name    string `json:"name"`
-	Phone   string `json:"phone"`
+	Name    string `json:"name" pii:"name"`
+	Phone   string `json:"phone" pii:"phone"`
 	Zip     string `json:"zip"`
 	City    string `json:"city"`
-	Address string `json:"address"`
+	Address string `json:"address" pii:"full"`
 	Region  string `json:"region"`
-	Email   string `json:"email"`
+	Email   string `json:"email" pii:"email"`
 }
 
+// Payment — оплата заказа. В JSON время оплаты передаётся как payment_dt в секундах Unix
+// (см. UnixTime), нулевое PaymentTime — как 0.
 type Payment struct {
-	Transaction   string `json:"transaction"`
-	Request_id    string `json:"request_id"`
-	Currency      string `json:"currency"`
-	Provider      string `json:"provider"`
-	Amount        int    `json:"amount"`
-	Payment_dt    int    `json:"payment_dt"`
-	Bank          string `json:"bank"`
-	Delivery_cost int    `json:"delivery_cost"`
-	Goods_total   int    `json:"goods_total"`
-	Custom_fee    int    `json:"custom_fee"`
+	Transaction  string   `json:"transaction"`
+	RequestID    string   `json:"request_id"`
+	Currency     string   `json:"currency"`
+	Provider     string   `json:"provider"`
+	Amount       int      `json:"amount"`
+	PaymentTime  UnixTime `json:"payment_dt"`
+	Bank         string   `json:"bank"`
+	DeliveryCost int      `json:"delivery_cost"`
+	GoodsTotal   int      `json:"goods_total"`
+	CustomFee    int      `json:"custom_fee"`
 }
 
-type Items struct {
-	Chrt_id      int    `json:"chrt_id"`
-	Track_number string `json:"track_number"`
-	Price        int    `json:"price"`
-	Rid          string `json:"rid"`
-	Name         string `json:"name"`
-	Sale         int    `json:"sale"`
-	Size         string `json:"size"`
-	Total_price  int    `json:"total_price"`
-	Nm_id        int    `json:"nm_id"`
-	Brand        string `json:"brand"`
-	Status       int    `json:"status"`
+type Item struct {
+	ChrtID      int        `json:"chrt_id"`
+	TrackNumber string     `json:"track_number"`
+	Price       int        `json:"price"`
+	RID         string     `json:"rid"`
+	Name        string     `json:"name"`
+	Sale        int        `json:"sale"`
+	Size        string     `json:"size"`
+	TotalPrice  int        `json:"total_price"`
+	NmID        int        `json:"nm_id"`
+	Brand       string     `json:"brand"`
+	Status      ItemStatus `json:"status"`
+}
+
+type (
+	logOrder    Order
+	logDelivery Delivery
+)
+
+// LogValue маскирует персональные данные доставки, когда заказ попадает в лог.
+func (o Order) LogValue() slog.Value {
+	return slog.AnyValue(logOrder(pii.Redact(o)))
+}
+
+func (d Delivery) LogValue() slog.Value {
+	return slog.AnyValue(logDelivery(pii.Redact(d)))
 }
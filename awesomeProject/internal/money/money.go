@@ -0,0 +1,124 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrUnknownCurrency  = errors.New("unknown ISO 4217 currency code")
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	ErrOverflow         = errors.New("amount overflows int64 minor units")
+	ErrNoRate           = errors.New("no exchange rate")
+)
+
+// exponents — число знаков минимальной единицы (копейки, центы) для действующих кодов ISO 4217.
+// Валюты, которых здесь нет, имеют две минимальные единицы.
+var exponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0, "PYG": 0,
+	"RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"CLF": 4, "UYW": 4,
+}
+
+// currencies — действующие коды ISO 4217 с двумя минимальными единицами.
+var currencies = strings.Fields(`
+	AED AFN ALL AMD ANG AOA ARS AUD AWG AZN BAM BBD BDT BGN BMD BND BOB BOV BRL BSD BTN BWP BYN BZD
+	CAD CDF CHE CHF CHW CNY COP COU CRC CUP CVE CZK DKK DOP DZD EGP ERN ETB EUR FJD FKP GBP GEL GHS
+	GIP GMD GTQ GYD HKD HNL HTG HUF IDR ILS INR IRR JMD KES KGS KHR KPW KYD KZT LAK LBP LKR LRD LSL
+	MAD MDL MGA MKD MMK MNT MOP MRU MUR MVR MWK MXN MXV MYR MZN NAD NGN NIO NOK NPR NZD PAB PEN PGK
+	PHP PKR PLN QAR RON RSD RUB SAR SBD SCR SDG SEK SGD SHP SLE SOS SRD SSP STN SVC SYP SZL THB TJS
+	TMT TOP TRY TTD TWD TZS UAH USD USN UYU UZS VED VES WST XCD XCG YER ZAR ZMW ZWG`)
+
+func init() {
+	for _, c := range currencies {
+		exponents[c] = 2
+	}
+}
+
+// Exponent возвращает число знаков минимальной единицы валюты: 2 для RUB и USD, 0 для JPY,
+// 3 для KWD. Код должен быть в верхнем регистре, как в ISO 4217.
+func Exponent(code string) (int, error) {
+	e, ok := exponents[code]
+	if !ok {
+		return 0, fmt.Errorf("%w %q", ErrUnknownCurrency, code)
+	}
+	return e, nil
+}
+
+func ValidCurrency(code string) bool {
+	_, ok := exponents[code]
+	return ok
+}
+
+// Money — сумма в минимальных единицах валюты (копейках для RUB, центах для USD, иенах для JPY).
+// Складывать можно только суммы в одной валюте; переполнение int64 — ошибка, а не перенос.
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// New проверяет код валюты и возвращает сумму amount минимальных единиц.
+func New(amount int64, currency string) (Money, error) {
+	if !ValidCurrency(currency) {
+		return Money{}, fmt.Errorf("%w %q", ErrUnknownCurrency, currency)
+	}
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, fmt.Errorf("%w: %s + %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+	}
+	sum, ok := add(m.Amount, o.Amount)
+	if !ok {
+		return Money{}, fmt.Errorf("%w: %d + %d %s", ErrOverflow, m.Amount, o.Amount, m.Currency)
+	}
+	return Money{Amount: sum, Currency: m.Currency}, nil
+}
+
+// Mul умножает сумму на целое n, например цену на количество.
+func (m Money) Mul(n int64) (Money, error) {
+	p := new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(n))
+	if !p.IsInt64() {
+		return Money{}, fmt.Errorf("%w: %d * %d %s", ErrOverflow, m.Amount, n, m.Currency)
+	}
+	return Money{Amount: p.Int64(), Currency: m.Currency}, nil
+}
+
+// Sum складывает суммы в валюте currency; пустой список даёт ноль.
+func Sum(currency string, list ...Money) (Money, error) {
+	total := Money{Currency: currency}
+	for _, m := range list {
+		var err error
+		if total, err = total.Add(m); err != nil {
+			return Money{}, err
+		}
+	}
+	return total, nil
+}
+
+// String форматирует сумму в основных единицах: "1234.50 RUB", "980 JPY".
+func (m Money) String() string {
+	e, err := Exponent(m.Currency)
+	if err != nil || e == 0 {
+		return strconv.FormatInt(m.Amount, 10) + " " + m.Currency
+	}
+	sign, abs := "", uint64(m.Amount)
+	if m.Amount < 0 {
+		sign, abs = "-", uint64(-m.Amount)
+	}
+	digits := fmt.Sprintf("%0*d", e+1, abs)
+	return sign + digits[:len(digits)-e] + "." + digits[len(digits)-e:] + " " + m.Currency
+}
+
+func add(a, b int64) (int64, bool) {
+	s := a + b
+	if (b > 0 && s < a) || (b < 0 && s > a) {
+		return 0, false
+	}
+	return s, true
+}
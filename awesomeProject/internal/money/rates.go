@@ -0,0 +1,111 @@
+package money
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// Rates — локальная таблица курсов: сколько единиц каждой валюты стоит одна единица базовой.
+// Курсы хранятся точными дробями, поэтому пересчёт не копит ошибку двоичной плавающей точки.
+type Rates struct {
+	base  string
+	rates map[string]*big.Rat
+}
+
+// NewRates строит таблицу из курсов в десятичной записи ("0.92", "91.5"). Курс базовой валюты
+// равен 1 и может быть опущен.
+func NewRates(base string, rates map[string]string) (*Rates, error) {
+	if !ValidCurrency(base) {
+		return nil, fmt.Errorf("base: %w %q", ErrUnknownCurrency, base)
+	}
+	r := &Rates{base: base, rates: map[string]*big.Rat{base: big.NewRat(1, 1)}}
+	for code, s := range rates {
+		if !ValidCurrency(code) {
+			return nil, fmt.Errorf("%w %q", ErrUnknownCurrency, code)
+		}
+		rate, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+		if !ok || rate.Sign() <= 0 {
+			return nil, fmt.Errorf("rate %s: want a positive decimal, got %q", code, s)
+		}
+		if code == base && rate.Cmp(big.NewRat(1, 1)) != 0 {
+			return nil, fmt.Errorf("rate %s: base currency rate must be 1", code)
+		}
+		r.rates[code] = rate
+	}
+	return r, nil
+}
+
+type ratesFile struct {
+	Base  string                 `json:"base"`
+	Rates map[string]json.Number `json:"rates"`
+}
+
+// LoadRates читает таблицу курсов из JSON-файла {"base": "USD", "rates": {"EUR": "0.92", ...}};
+// курсы можно писать и строками, и числами.
+func LoadRates(path string) (*Rates, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f ratesFile
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.UseNumber()
+	if err := dec.Decode(&f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	rates := make(map[string]string, len(f.Rates))
+	for code, n := range f.Rates {
+		rates[code] = n.String()
+	}
+	r, err := NewRates(f.Base, rates)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return r, nil
+}
+
+func (r *Rates) Base() string { return r.base }
+
+// Convert пересчитывает сумму в валюту to через базовую валюту с учётом разной разрядности
+// валют и округляет до минимальной единицы (половина — от нуля).
+func (r *Rates) Convert(m Money, to string) (Money, error) {
+	if m.Currency == to {
+		return m, nil
+	}
+	fromExp, err := Exponent(m.Currency)
+	if err != nil {
+		return Money{}, err
+	}
+	toExp, err := Exponent(to)
+	if err != nil {
+		return Money{}, err
+	}
+	from, ok := r.rates[m.Currency]
+	if !ok {
+		return Money{}, fmt.Errorf("%w for %s", ErrNoRate, m.Currency)
+	}
+	target, ok := r.rates[to]
+	if !ok {
+		return Money{}, fmt.Errorf("%w for %s", ErrNoRate, to)
+	}
+	// amount / 10^fromExp / from * target * 10^toExp
+	v := new(big.Rat).SetInt64(m.Amount)
+	v.Quo(v, from).Mul(v, target)
+	v.Mul(v, new(big.Rat).SetFrac(pow10(toExp), pow10(fromExp)))
+
+	q, rem := new(big.Int).QuoRem(v.Num(), v.Denom(), new(big.Int))
+	if new(big.Int).Mul(rem.Abs(rem), big.NewInt(2)).Cmp(v.Denom()) >= 0 {
+		q.Add(q, big.NewInt(int64(v.Sign())))
+	}
+	if !q.IsInt64() {
+		return Money{}, fmt.Errorf("%w: %s in %s", ErrOverflow, m, to)
+	}
+	return Money{Amount: q.Int64(), Currency: to}, nil
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
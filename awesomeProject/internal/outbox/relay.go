@@ -0,0 +1,171 @@
+// Package outbox публикует в Kafka события заказов, которые репозиторий записывает в таблицу
+// outbox в одной транзакции с самим изменением. Событие попадает в топик, только если
+// изменение закоммичено, и не теряется, если Kafka недоступна: Relay повторит публикацию.
+package outbox
+
+import (
+	"awesomeProject/internal/repository"
+	"context"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Заголовки сообщения события. По event-id потребители отбрасывают повторы: доставка
+// «хотя бы один раз», и одно событие может прийти дважды.
+const (
+	HeaderEventID   = "event-id"
+	HeaderEventType = "event-type"
+)
+
+type Store interface {
+	RelayOutbox(ctx context.Context, limit int,
+		publish func(ctx context.Context, batch []repository.OutboxRecord) error) (int, error)
+	PurgeOutbox(ctx context.Context, before time.Time) (int64, error)
+}
+
+type Publisher interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+type Config struct {
+	// BatchSize — сколько событий публикуется за один проход.
+	BatchSize int
+	// PollInterval — пауза между проходами, когда публиковать нечего или Kafka недоступна.
+	PollInterval time.Duration
+	// Retention — сколько хранить опубликованные события перед удалением; CleanupInterval —
+	// как часто удалять.
+	Retention       time.Duration
+	CleanupInterval time.Duration
+}
+
+func (c Config) withDefaults() Config {
+	if c.BatchSize <= 0 {
+		c.BatchSize = 100
+	}
+	if c.PollInterval <= 0 {
+		c.PollInterval = time.Second
+	}
+	if c.Retention <= 0 {
+		c.Retention = 24 * time.Hour
+	}
+	if c.CleanupInterval <= 0 {
+		c.CleanupInterval = 10 * time.Minute
+	}
+	return c
+}
+
+type Relay struct {
+	store  Store
+	pub    Publisher
+	cfg    Config
+	logger *slog.Logger
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+func NewRelay(store Store, pub Publisher, cfg Config, logger *slog.Logger) *Relay {
+	return &Relay{
+		store:  store,
+		pub:    pub,
+		cfg:    cfg.withDefaults(),
+		logger: logger,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// Run публикует события, пока не отменён ctx или не вызван Shutdown. Пока в outbox есть
+// неопубликованные события, проходы идут без паузы.
+func (r *Relay) Run(ctx context.Context) error {
+	defer close(r.done)
+	poll := time.NewTimer(0)
+	defer poll.Stop()
+	cleanup := time.NewTicker(r.cfg.CleanupInterval)
+	defer cleanup.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-r.stop:
+			// Последний проход, чтобы не оставлять до следующего запуска то, что успели записать.
+			r.drain(ctx)
+			return nil
+		case <-cleanup.C:
+			r.purge(ctx)
+		case <-poll.C:
+			n, err := r.relay(ctx)
+			wait := time.Duration(0)
+			if err != nil || n < r.cfg.BatchSize {
+				wait = r.cfg.PollInterval
+			}
+			poll.Reset(wait)
+		}
+	}
+}
+
+// Shutdown останавливает Run после последнего прохода и ждёт его завершения.
+func (r *Relay) Shutdown(ctx context.Context) error {
+	r.stopOnce.Do(func() { close(r.stop) })
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Relay) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		n, err := r.relay(ctx)
+		if err != nil || n < r.cfg.BatchSize {
+			return
+		}
+	}
+}
+
+func (r *Relay) relay(ctx context.Context) (int, error) {
+	n, err := r.store.RelayOutbox(ctx, r.cfg.BatchSize, r.publish)
+	if err != nil {
+		if ctx.Err() == nil {
+			r.logger.Error("outbox relay failed", slog.Any("err", err))
+		}
+		return 0, err
+	}
+	if n > 0 {
+		r.logger.Info("outbox events published", slog.Int("count", n))
+	}
+	return n, nil
+}
+
+func (r *Relay) publish(ctx context.Context, batch []repository.OutboxRecord) error {
+	msgs := make([]kafka.Message, len(batch))
+	for i, rec := range batch {
+		msgs[i] = kafka.Message{
+			Key:   []byte(rec.OrderUID),
+			Value: rec.Payload,
+			Time:  rec.CreatedAt,
+			Headers: []kafka.Header{
+				{Key: HeaderEventID, Value: []byte(strconv.FormatInt(rec.ID, 10))},
+				{Key: HeaderEventType, Value: []byte(rec.EventType)},
+			},
+		}
+	}
+	return r.pub.WriteMessages(ctx, msgs...)
+}
+
+func (r *Relay) purge(ctx context.Context) {
+	n, err := r.store.PurgeOutbox(ctx, time.Now().Add(-r.cfg.Retention))
+	if err != nil {
+		r.logger.Error("outbox cleanup failed", slog.Any("err", err))
+		return
+	}
+	if n > 0 {
+		r.logger.Info("outbox cleaned up", slog.Int64("deleted", n))
+	}
+}
@@ -0,0 +1,155 @@
+// Package pii маскирует персональные данные в значениях, поля которых помечены тегом `pii:"kind"`.
+package pii
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	KindName    = "name"
+	KindPhone   = "phone"
+	KindEmail   = "email"
+	KindFull    = "full"
+	KindPartial = "partial"
+)
+
+const tagName = "pii"
+
+// Mask маскирует одну строку по виду kind; пустая строка остаётся пустой.
+func Mask(kind, s string) string {
+	if s == "" {
+		return ""
+	}
+	switch kind {
+	case KindPhone:
+		return keepEdges(s, 3, 3)
+	case KindEmail:
+		local, domain, ok := strings.Cut(s, "@")
+		if !ok {
+			return keepEdges(s, 1, 0)
+		}
+		first, _ := utf8.DecodeRuneInString(local)
+		return string(first) + "***@" + domain
+	case KindName:
+		words := strings.Fields(s)
+		for i, w := range words {
+			words[i] = keepEdges(w, 1, 0)
+		}
+		return strings.Join(words, " ")
+	case KindFull:
+		return "***"
+	default:
+		return keepEdges(s, 1, 1)
+	}
+}
+
+// keepEdges оставляет head первых и tail последних символов, остальное заменяет звёздочками;
+// слишком короткая строка закрывается целиком.
+func keepEdges(s string, head, tail int) string {
+	runes := []rune(s)
+	if len(runes) <= head+tail {
+		return strings.Repeat("*", len(runes))
+	}
+	return string(runes[:head]) + strings.Repeat("*", len(runes)-head-tail) + string(runes[len(runes)-tail:])
+}
+
+// Redact возвращает копию v, в которой все строковые поля с тегом pii замаскированы.
+// Вложенные структуры, указатели и срезы обходятся рекурсивно; исходное значение не меняется.
+func Redact[T any](v T) T {
+	out, _ := Transform(v, func(kind, s string) (string, error) {
+		return Mask(kind, s), nil
+	})
+	return out
+}
+
+// Transform возвращает копию v, в которой каждое строковое поле с тегом pii заменено на
+// fn(kind, значение). Так же, как Redact, не меняет исходное значение; на первой ошибке fn
+// обход прекращается.
+func Transform[T any](v T, fn func(kind, s string) (string, error)) (T, error) {
+	out := v
+	err := transform(reflect.ValueOf(&out).Elem(), fn)
+	return out, err
+}
+
+func transform(v reflect.Value, fn func(kind, s string) (string, error)) error {
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := range t.NumField() {
+			f := v.Field(i)
+			if !t.Field(i).IsExported() {
+				continue
+			}
+			if kind, ok := t.Field(i).Tag.Lookup(tagName); ok && f.Kind() == reflect.String {
+				s, err := fn(kind, f.String())
+				if err != nil {
+					return fmt.Errorf("%s.%s: %w", t.Name(), t.Field(i).Name, err)
+				}
+				f.SetString(s)
+				continue
+			}
+			if err := transform(f, fn); err != nil {
+				return err
+			}
+		}
+	case reflect.Pointer:
+		if v.IsNil() || !hasPII(v.Type().Elem()) {
+			return nil
+		}
+		cp := reflect.New(v.Type().Elem())
+		cp.Elem().Set(v.Elem())
+		if err := transform(cp.Elem(), fn); err != nil {
+			return err
+		}
+		v.Set(cp)
+	case reflect.Slice:
+		if v.IsNil() || !hasPII(v.Type().Elem()) {
+			return nil
+		}
+		cp := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		reflect.Copy(cp, v)
+		for i := range cp.Len() {
+			if err := transform(cp.Index(i), fn); err != nil {
+				return err
+			}
+		}
+		v.Set(cp)
+	case reflect.Array:
+		for i := range v.Len() {
+			if err := transform(v.Index(i), fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// hasPII сообщает, есть ли в типе помеченные поля: срезы без них не копируются.
+func hasPII(t reflect.Type) bool {
+	return hasPIIType(t, map[reflect.Type]bool{})
+}
+
+func hasPIIType(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+	switch t.Kind() {
+	case reflect.Struct:
+		for i := range t.NumField() {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			if _, ok := f.Tag.Lookup(tagName); ok || hasPIIType(f.Type, seen) {
+				return true
+			}
+		}
+	case reflect.Pointer, reflect.Slice, reflect.Array:
+		return hasPIIType(t.Elem(), seen)
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"awesomeProject/internal/model"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+)
+
+const defaultSource = "system"
+
+type sourceKey struct{}
+
+// WithSource привязывает к ctx источник изменения, который попадёт в журнал изменений и
+// историю статусов (формат — см. model.StatusChange). Без него изменение записывается от
+// имени "system".
+func WithSource(ctx context.Context, source string) context.Context {
+	return context.WithValue(ctx, sourceKey{}, source)
+}
+
+// SourceFrom возвращает источник изменения из ctx или "system".
+func SourceFrom(ctx context.Context) string {
+	if s, ok := ctx.Value(sourceKey{}).(string); ok && s != "" {
+		return s
+	}
+	return defaultSource
+}
+
+// insertAudit пишет запись журнала в транзакции изменения. before и after должны быть в том
+// виде, в каком лежат в БД (с зашифрованной доставкой); diff считается до шифрования.
+func insertAudit(ctx context.Context, tx *sql.Tx, rec model.AuditRecord) error {
+	before, err := jsonOrNull(rec.Before)
+	if err != nil {
+		return err
+	}
+	after, err := jsonOrNull(rec.After)
+	if err != nil {
+		return err
+	}
+	var diff []byte
+	if len(rec.Diff) > 0 {
+		if diff, err = json.Marshal(rec.Diff); err != nil {
+			return err
+		}
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO audit_log (order_uid, action, source, before, after, diff) "+
+		"VALUES ($1,$2,$3,$4,$5,$6)",
+		rec.OrderUID, rec.Action, SourceFrom(ctx), before, after, diff)
+	return err
+}
+
+func jsonOrNull(v *model.Order) ([]byte, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+// auditUpdate готовит запись журнала для перезаписи заказа: prev — прежний заказ из БД
+// (nil, если его не было), order — новый в открытом виде. Возвращает запись и order,
+// зашифрованный для записи в БД.
+func (repo *Repository) auditUpdate(prev *model.Order, order model.Order) (model.AuditRecord, model.Order, error) {
+	rec := model.AuditRecord{OrderUID: order.OrderUID, Action: model.AuditCreated}
+	sealed, err := repo.seal(order)
+	if err != nil {
+		return rec, order, err
+	}
+	rec.After = &sealed
+	if prev != nil {
+		plain, err := repo.open(*prev)
+		if err != nil {
+			return rec, sealed, err
+		}
+		if rec.Diff, err = model.Diff(plain, order); err != nil {
+			return rec, sealed, err
+		}
+		rec.Action, rec.Before = model.AuditUpdated, prev
+	}
+	return rec, sealed, nil
+}
+
+// OrderHistory возвращает журнал изменений заказа от старых записей к новым, с
+// расшифрованной доставкой. Журнал хранится и после удаления заказа; ErrNotFound — записей нет.
+func (repo *Repository) OrderHistory(ctx context.Context, id string) ([]model.AuditRecord, error) {
+	rows, err := repo.db.QueryContext(ctx, "SELECT id, action, source, before, after, diff, changed_at "+
+		"FROM audit_log WHERE order_uid=$1 ORDER BY id", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []model.AuditRecord
+	for rows.Next() {
+		rec := model.AuditRecord{OrderUID: id}
+		var before, after, diff []byte
+		if err := rows.Scan(&rec.ID, &rec.Action, &rec.Source, &before, &after, &diff, &rec.ChangedAt); err != nil {
+			return nil, err
+		}
+		if rec.Before, err = repo.openSnapshot(before); err != nil {
+			return nil, err
+		}
+		if rec.After, err = repo.openSnapshot(after); err != nil {
+			return nil, err
+		}
+		if diff != nil {
+			if err := json.Unmarshal(diff, &rec.Diff); err != nil {
+				return nil, err
+			}
+		}
+		out = append(out, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(out) == 0 {
+		return nil, ErrNotFound
+	}
+	return out, nil
+}
+
+func (repo *Repository) openSnapshot(data []byte) (*model.Order, error) {
+	if data == nil {
+		return nil, nil
+	}
+	var order model.Order
+	if err := json.Unmarshal(data, &order); err != nil {
+		return nil, err
+	}
+	order, err := repo.open(order)
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// lockOrder блокирует строку заказа до конца транзакции и возвращает его в том виде, в каком
+// он лежит в БД, или nil, если заказа нет.
+func lockOrder(ctx context.Context, tx *sql.Tx, id string) (*model.Order, error) {
+	var one int
+	err := tx.QueryRowContext(ctx, "SELECT 1 FROM orders WHERE order_uid=$1 FOR UPDATE", id).Scan(&one)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	order, err := getOrder(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
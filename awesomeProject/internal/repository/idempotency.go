@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type IdempotencyRecord struct {
+	Fingerprint string
+	Status      int
+	Body        []byte
+}
+
+// ReserveIdempotencyKey занимает ключ под запрос с данным отпечатком. Если ключ уже занят,
+// возвращает сохранённую запись (Status == 0, пока первый запрос ещё выполняется).
+func (repo *Repository) ReserveIdempotencyKey(ctx context.Context, key, fingerprint string,
+	ttl time.Duration) (*IdempotencyRecord, error) {
+	if _, err := repo.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE idempotency_key=$1 "+
+		"AND created_at < $2", key, time.Now().Add(-ttl)); err != nil {
+		return nil, err
+	}
+	res, err := repo.db.ExecContext(ctx, "INSERT INTO idempotency_keys (idempotency_key, fingerprint) "+
+		"VALUES ($1,$2) ON CONFLICT (idempotency_key) DO NOTHING", key, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 1 {
+		return nil, nil
+	}
+	var (
+		rec    IdempotencyRecord
+		status sql.NullInt64
+	)
+	err = repo.db.QueryRowContext(ctx, "SELECT fingerprint, status, body FROM idempotency_keys "+
+		"WHERE idempotency_key=$1", key).Scan(&rec.Fingerprint, &status, &rec.Body)
+	if errors.Is(err, sql.ErrNoRows) {
+		return repo.ReserveIdempotencyKey(ctx, key, fingerprint, ttl)
+	}
+	if err != nil {
+		return nil, err
+	}
+	rec.Status = int(status.Int64)
+	return &rec, nil
+}
+
+func (repo *Repository) CompleteIdempotencyKey(ctx context.Context, key string, status int, body []byte) error {
+	_, err := repo.db.ExecContext(ctx, "UPDATE idempotency_keys SET status=$2, body=$3 WHERE idempotency_key=$1",
+		key, status, body)
+	return err
+}
+
+func (repo *Repository) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	_, err := repo.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE idempotency_key=$1 AND status IS NULL", key)
+	return err
+}
+
+// PurgeIdempotencyKeys удаляет ключи, занятые раньше olderThan: после TTL они уже не
+// защищают от повтора, а сами по себе удаляются, только если клиент повторит тот же ключ.
+func (repo *Repository) PurgeIdempotencyKeys(ctx context.Context, olderThan time.Time) (int64, error) {
+	res, err := repo.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE created_at < $1", olderThan)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
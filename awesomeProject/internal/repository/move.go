@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type statusRow struct {
+	from, to, source, reason string
+	changedAt                time.Time
+}
+
+type auditRow struct {
+	action, source      string
+	before, after, diff []byte
+	changedAt           time.Time
+}
+
+// moveOrder переносит заказ с шарда src на dst вместе со статусом, историей статусов и журналом
+// изменений; события в outbox не пишутся — для потребителей заказ не менялся. Строка на src
+// заблокирована, пока заказ пишется на dst, и удаляется только после коммита на dst: прерванный
+// перенос оставляет копию на обоих шардах, повторный доводит его до конца. Возвращает false,
+// если на src заказа уже нет.
+func moveOrder(ctx context.Context, src, dst *Repository, id string) (bool, error) {
+	stx, err := src.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = stx.Rollback() }()
+	order, err := lockOrder(ctx, stx, id)
+	if err != nil || order == nil {
+		return false, err
+	}
+	var status string
+	if err := stx.QueryRowContext(ctx, "SELECT status FROM orders WHERE order_uid=$1", id).Scan(&status); err != nil {
+		return false, err
+	}
+	history, err := readStatusRows(ctx, stx, id)
+	if err != nil {
+		return false, err
+	}
+	audit, err := readAuditRows(ctx, stx, id)
+	if err != nil {
+		return false, err
+	}
+
+	dtx, err := dst.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = dtx.Rollback() }()
+	if _, err := upsertOrderRows(ctx, dtx, *order); err != nil {
+		return false, err
+	}
+	if _, err := dtx.ExecContext(ctx, "UPDATE orders SET status=$2 WHERE order_uid=$1", id, status); err != nil {
+		return false, err
+	}
+	if _, err := dtx.ExecContext(ctx, "DELETE FROM status_history WHERE order_uid=$1", id); err != nil {
+		return false, err
+	}
+	for _, h := range history {
+		if _, err := dtx.ExecContext(ctx, "INSERT INTO status_history (order_uid, from_status, to_status, source, "+
+			"reason, changed_at) VALUES ($1,$2,$3,$4,$5,$6)",
+			id, h.from, h.to, h.source, h.reason, h.changedAt); err != nil {
+			return false, err
+		}
+	}
+	// Журнал переживает удаление заказа, и на dst может лежать его собственная история, поэтому
+	// записи только добавляются. id у каждого шарда свои, так что уже скопированные прерванным
+	// переносом записи узнаём по содержимому.
+	for _, a := range audit {
+		if _, err := dtx.ExecContext(ctx, "INSERT INTO audit_log (order_uid, action, source, before, after, diff, "+
+			"changed_at) SELECT $1,$2,$3,$4::jsonb,$5::jsonb,$6::jsonb,$7 WHERE NOT EXISTS (SELECT 1 FROM audit_log "+
+			"WHERE order_uid=$1 AND action=$2 AND source=$3 AND changed_at=$7 AND before IS NOT DISTINCT FROM $4::jsonb "+
+			"AND after IS NOT DISTINCT FROM $5::jsonb AND diff IS NOT DISTINCT FROM $6::jsonb)",
+			id, a.action, a.source, a.before, a.after, a.diff, a.changedAt); err != nil {
+			return false, err
+		}
+	}
+	if err := dtx.Commit(); err != nil {
+		return false, err
+	}
+
+	if _, err := stx.ExecContext(ctx, "DELETE FROM audit_log WHERE order_uid=$1", id); err != nil {
+		return false, err
+	}
+	if _, err := stx.ExecContext(ctx, "DELETE FROM orders WHERE order_uid=$1", id); err != nil {
+		return false, err
+	}
+	return true, stx.Commit()
+}
+
+func readStatusRows(ctx context.Context, tx *sql.Tx, id string) ([]statusRow, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT from_status, to_status, source, reason, changed_at "+
+		"FROM status_history WHERE order_uid=$1 ORDER BY id", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []statusRow
+	for rows.Next() {
+		var r statusRow
+		if err := rows.Scan(&r.from, &r.to, &r.source, &r.reason, &r.changedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+func readAuditRows(ctx context.Context, tx *sql.Tx, id string) ([]auditRow, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT action, source, before, after, diff, changed_at "+
+		"FROM audit_log WHERE order_uid=$1 ORDER BY id", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []auditRow
+	for rows.Next() {
+		var r auditRow
+		if err := rows.Scan(&r.action, &r.source, &r.before, &r.after, &r.diff, &r.changedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
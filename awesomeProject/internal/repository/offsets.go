@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// ErrAlreadyApplied возвращается записью из Kafka, offset которой уже сохранён в БД: сообщение
+// было применено раньше (до сбоя перед коммитом в Kafka или другим участником группы).
+var ErrAlreadyApplied = errors.New("kafka message already applied")
+
+// ConsumerOffset — позиция сообщения Kafka, из которого пришёл заказ.
+type ConsumerOffset struct {
+	Group     string
+	Topic     string
+	Partition int
+	Offset    int64
+}
+
+type consumerOffsetKey struct{}
+
+// WithConsumerOffset привязывает к ctx позицию сообщения. InsertOrder с таким контекстом
+// отмечает сообщение применённым в той же транзакции, что и заказ, а уже применённое
+// сообщение отклоняет с ErrAlreadyApplied.
+func WithConsumerOffset(ctx context.Context, off ConsumerOffset) context.Context {
+	return context.WithValue(ctx, consumerOffsetKey{}, off)
+}
+
+func consumerOffsetFrom(ctx context.Context) (ConsumerOffset, bool) {
+	off, ok := ctx.Value(consumerOffsetKey{}).(ConsumerOffset)
+	return off, ok
+}
+
+// claimOffset отмечает сообщение применённым в той же транзакции, что и его запись. Сообщение
+// ниже next_offset партиции или уже отмеченное отклоняется с ErrAlreadyApplied; конкурирующая
+// транзакция с тем же сообщением дождётся коммита первой на первичном ключе. next_offset здесь
+// не двигается: воркеры применяют сообщения партиции не по порядку, и после упавшей записи N
+// успешная N+1 не должна сдвигать позицию за N (см. AdvanceConsumerOffset).
+func claimOffset(ctx context.Context, tx *sql.Tx, off ConsumerOffset) error {
+	if _, err := tx.ExecContext(ctx, "INSERT INTO consumer_offsets (group_id, topic, \"partition\", next_offset) "+
+		"VALUES ($1,$2,$3,0) ON CONFLICT (group_id, topic, \"partition\") DO NOTHING",
+		off.Group, off.Topic, off.Partition); err != nil {
+		return err
+	}
+	// FOR SHARE не мешает параллельным записям партиции, но не даёт AdvanceConsumerOffset
+	// сдвинуть позицию и удалить отметки, пока эта транзакция их читает.
+	var next int64
+	if err := tx.QueryRowContext(ctx, "SELECT next_offset FROM consumer_offsets "+
+		"WHERE group_id=$1 AND topic=$2 AND \"partition\"=$3 FOR SHARE",
+		off.Group, off.Topic, off.Partition).Scan(&next); err != nil {
+		return err
+	}
+	if off.Offset < next {
+		return ErrAlreadyApplied
+	}
+	res, err := tx.ExecContext(ctx, "INSERT INTO consumer_applied_offsets (group_id, topic, \"partition\", \"offset\") "+
+		"VALUES ($1,$2,$3,$4) ON CONFLICT DO NOTHING",
+		off.Group, off.Topic, off.Partition, off.Offset)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrAlreadyApplied
+	}
+	return nil
+}
+
+// AdvanceConsumerOffset сдвигает next_offset партиции до next и удаляет отметки применённых
+// сообщений ниже него. Потребитель вызывает его для непрерывного префикса обработанных
+// сообщений — того же, что коммитит в Kafka, — так что всё ниже next уже применено или пропущено.
+func (repo *Repository) AdvanceConsumerOffset(ctx context.Context, group, topic string, partition int, next int64) error {
+	tx, err := repo.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.ExecContext(ctx, "INSERT INTO consumer_offsets (group_id, topic, \"partition\", next_offset) "+
+		"VALUES ($1,$2,$3,$4) ON CONFLICT (group_id, topic, \"partition\") DO UPDATE "+
+		"SET next_offset=GREATEST(consumer_offsets.next_offset, EXCLUDED.next_offset), updated_at=now()",
+		group, topic, partition, next); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM consumer_applied_offsets "+
+		"WHERE group_id=$1 AND topic=$2 AND \"partition\"=$3 AND \"offset\" < $4",
+		group, topic, partition, next); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ConsumerOffsets возвращает сохранённые в БД следующие offset'ы партиций топика для группы.
+func (repo *Repository) ConsumerOffsets(ctx context.Context, group, topic string) (map[int]int64, error) {
+	rows, err := repo.db.QueryContext(ctx, "SELECT \"partition\", next_offset FROM consumer_offsets "+
+		"WHERE group_id=$1 AND topic=$2", group, topic)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make(map[int]int64)
+	for rows.Next() {
+		var (
+			partition int
+			next      int64
+		)
+		if err := rows.Scan(&partition, &next); err != nil {
+			return nil, err
+		}
+		out[partition] = next
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"awesomeProject/internal/model"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// OutboxRecord — событие, записанное в outbox той же транзакцией, что и изменение заказа.
+type OutboxRecord struct {
+	ID        int64
+	OrderUID  string
+	EventType string
+	Payload   []byte
+	CreatedAt time.Time
+}
+
+// insertOutbox записывает событие заказа; order — в открытом виде, до seal: события читают
+// другие сервисы, у которых нет ключей шифрования хранилища.
+func insertOutbox(ctx context.Context, tx *sql.Tx, eventType string, order model.Order) error {
+	ev := model.OrderEvent{Type: eventType, OrderUID: order.OrderUID, OccurredAt: time.Now().UTC()}
+	if eventType != model.EventOrderDeleted {
+		ev.Order = &order
+	}
+	return writeOutbox(ctx, tx, ev)
+}
+
+func writeOutbox(ctx context.Context, tx *sql.Tx, ev model.OrderEvent) error {
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO outbox (order_uid, event_type, payload) VALUES ($1,$2,$3)",
+		ev.OrderUID, ev.Type, payload)
+	return err
+}
+
+// RelayOutbox берёт до limit неопубликованных событий по порядку записи, передаёт их publish
+// и, если тот вернул nil, помечает опубликованными. Строки заблокированы до конца транзакции
+// (SKIP LOCKED), поэтому несколько экземпляров сервиса не публикуют одно событие одновременно.
+// Если пометить не удалось после успешной публикации, события будут опубликованы повторно:
+// доставка «хотя бы один раз». Возвращает число опубликованных событий.
+func (repo *Repository) RelayOutbox(ctx context.Context, limit int,
+	publish func(ctx context.Context, batch []OutboxRecord) error) (int, error) {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	rows, err := tx.QueryContext(ctx, "SELECT id, order_uid, event_type, payload, created_at FROM outbox "+
+		"WHERE published_at IS NULL ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED", limit)
+	if err != nil {
+		return 0, err
+	}
+	var batch []OutboxRecord
+	for rows.Next() {
+		var r OutboxRecord
+		if err := rows.Scan(&r.ID, &r.OrderUID, &r.EventType, &r.Payload, &r.CreatedAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		batch = append(batch, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(batch) == 0 {
+		return 0, nil
+	}
+	if err := publish(ctx, batch); err != nil {
+		return 0, err
+	}
+	ids := make([]int64, len(batch))
+	for i, r := range batch {
+		ids[i] = r.ID
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE outbox SET published_at=now() WHERE id = ANY($1)",
+		pq.Array(ids)); err != nil {
+		return 0, err
+	}
+	return len(batch), tx.Commit()
+}
+
+// PurgeOutbox удаляет события, опубликованные раньше before.
+func (repo *Repository) PurgeOutbox(ctx context.Context, before time.Time) (int64, error) {
+	res, err := repo.db.ExecContext(ctx, "DELETE FROM outbox WHERE published_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+)
+
+// RebalanceResult — итог прохода Rebalance: сколько заказов проверено и сколько перенесено
+// по каждому направлению ("s0->s1").
+type RebalanceResult struct {
+	Checked int            `json:"checked"`
+	Moved   map[string]int `json:"moved"`
+}
+
+// Rebalance переносит заказы, которые лежат не на шарде из карты (карту поменяли или у заказа
+// сменился shardkey). Шарды просматриваются пачками по order_uid; каждый заказ переносится
+// отдельно (см. moveOrder), поэтому проход можно прервать и запустить заново. С dryRun
+// заказы только подсчитываются.
+func (s *Sharded) Rebalance(ctx context.Context, batchSize int, dryRun bool) (RebalanceResult, error) {
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+	res := RebalanceResult{Moved: make(map[string]int)}
+	for _, name := range s.names {
+		src := s.shards[name]
+		after := ""
+		for {
+			batch, err := src.listShardKeys(ctx, after, batchSize)
+			if err != nil {
+				return res, fmt.Errorf("shard %s: %w", name, err)
+			}
+			if len(batch) == 0 {
+				break
+			}
+			for _, o := range batch {
+				res.Checked++
+				target := s.shardMap.ShardFor(o.key)
+				if target == name {
+					continue
+				}
+				route := name + "->" + target
+				if dryRun {
+					res.Moved[route]++
+					continue
+				}
+				moved, err := moveOrder(ctx, src, s.shards[target], o.id)
+				if err != nil {
+					return res, fmt.Errorf("move %s %s: %w", o.id, route, err)
+				}
+				if moved {
+					res.Moved[route]++
+				}
+			}
+			after = batch[len(batch)-1].id
+		}
+	}
+	return res, nil
+}
+
+type orderShardKey struct{ id, key string }
+
+func (repo *Repository) listShardKeys(ctx context.Context, after string, limit int) ([]orderShardKey, error) {
+	rows, err := repo.db.QueryContext(ctx, "SELECT order_uid, COALESCE(shardkey, '') FROM orders "+
+		"WHERE order_uid > $1 ORDER BY order_uid LIMIT $2", after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []orderShardKey
+	for rows.Next() {
+		var o orderShardKey
+		if err := rows.Scan(&o.id, &o.key); err != nil {
+			return nil, err
+		}
+		out = append(out, o)
+	}
+	return out, rows.Err()
+}
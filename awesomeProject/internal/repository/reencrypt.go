@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"awesomeProject/internal/envelope"
+	"awesomeProject/internal/model"
+	"awesomeProject/internal/pii"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ReencryptDeliveries перешифровывает основным ключом персональные данные доставки, записанные
+// открытым текстом или старым ключом. Идёт пачками по order_uid, каждая пачка — отдельная
+// транзакция, поэтому прерванный проход можно просто запустить заново. Возвращает число
+// обновлённых строк.
+func (repo *Repository) ReencryptDeliveries(ctx context.Context, batchSize int) (int, error) {
+	if repo.keyring == nil {
+		return 0, errors.New("reencrypt: no encryption keys configured")
+	}
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+	updated, after := 0, ""
+	for {
+		n, last, err := repo.reencryptBatch(ctx, after, batchSize)
+		updated += n
+		if err != nil {
+			return updated, err
+		}
+		if last == "" {
+			return updated, nil
+		}
+		after = last
+	}
+}
+
+func (repo *Repository) reencryptBatch(ctx context.Context, after string, limit int) (int, string, error) {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Читаем и пишем строку доставки целиком, а какие поля шифровать, решают теги pii
+	// model.Delivery, как и в seal: новое персональное поле не придётся добавлять сюда отдельно.
+	rows, err := tx.QueryContext(ctx, "SELECT order_uid, \"name\", phone, zip, city, address, region, email "+
+		"FROM delivery WHERE order_uid > $1 ORDER BY order_uid LIMIT $2 FOR UPDATE", after, limit)
+	if err != nil {
+		return 0, "", err
+	}
+	type row struct {
+		id string
+		d  model.Delivery
+	}
+	var batch []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.id, &r.d.Name, &r.d.Phone, &r.d.Zip, &r.d.City, &r.d.Address, &r.d.Region,
+			&r.d.Email); err != nil {
+			rows.Close()
+			return 0, "", err
+		}
+		batch = append(batch, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, "", err
+	}
+	if len(batch) == 0 {
+		return 0, "", nil
+	}
+
+	updated := 0
+	for _, r := range batch {
+		d, changed, err := reseal(repo.keyring, r.d)
+		if err != nil {
+			return 0, "", fmt.Errorf("delivery of %s: %w", r.id, err)
+		}
+		if !changed {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE delivery SET \"name\"=$2, phone=$3, zip=$4, city=$5, "+
+			"address=$6, region=$7, email=$8 WHERE order_uid=$1",
+			r.id, d.Name, d.Phone, d.Zip, d.City, d.Address, d.Region, d.Email); err != nil {
+			return 0, "", err
+		}
+		updated++
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, "", err
+	}
+	return updated, batch[len(batch)-1].id, nil
+}
+
+// ReencryptAudit перешифровывает основным ключом доставку в снимках заказа журнала изменений.
+// Как и ReencryptDeliveries, идёт пачками (по id записи) в отдельных транзакциях.
+func (repo *Repository) ReencryptAudit(ctx context.Context, batchSize int) (int, error) {
+	if repo.keyring == nil {
+		return 0, errors.New("reencrypt: no encryption keys configured")
+	}
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+	updated, after := 0, int64(0)
+	for {
+		n, last, err := repo.reencryptAuditBatch(ctx, after, batchSize)
+		updated += n
+		if err != nil {
+			return updated, err
+		}
+		if last == 0 {
+			return updated, nil
+		}
+		after = last
+	}
+}
+
+func (repo *Repository) reencryptAuditBatch(ctx context.Context, after int64, limit int) (int, int64, error) {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	rows, err := tx.QueryContext(ctx, "SELECT id, before, after FROM audit_log "+
+		"WHERE id > $1 ORDER BY id LIMIT $2 FOR UPDATE", after, limit)
+	if err != nil {
+		return 0, 0, err
+	}
+	type row struct {
+		id            int64
+		before, after []byte
+	}
+	var batch []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.id, &r.before, &r.after); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		batch = append(batch, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+	if len(batch) == 0 {
+		return 0, 0, nil
+	}
+
+	updated := 0
+	for _, r := range batch {
+		changed := false
+		for _, f := range []*[]byte{&r.before, &r.after} {
+			out, resealed, err := repo.resealSnapshot(*f)
+			if err != nil {
+				return 0, 0, fmt.Errorf("audit record %d: %w", r.id, err)
+			}
+			*f, changed = out, changed || resealed
+		}
+		if !changed {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE audit_log SET before=$2, after=$3 WHERE id=$1",
+			r.id, r.before, r.after); err != nil {
+			return 0, 0, err
+		}
+		updated++
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+	return updated, batch[len(batch)-1].id, nil
+}
+
+func (repo *Repository) resealSnapshot(data []byte) ([]byte, bool, error) {
+	if data == nil {
+		return nil, false, nil
+	}
+	var order model.Order
+	if err := json.Unmarshal(data, &order); err != nil {
+		return nil, false, err
+	}
+	order, changed, err := reseal(repo.keyring, order)
+	if err != nil || !changed {
+		return data, false, err
+	}
+	out, err := json.Marshal(order)
+	return out, err == nil, err
+}
+
+// reseal перешифровывает основным ключом все поля v с тегом pii, записанные открытым текстом
+// или старым ключом, и сообщает, изменилось ли что-нибудь.
+func reseal[T any](k *envelope.Keyring, v T) (T, bool, error) {
+	if !envelope.HasStale(k, v) {
+		return v, false, nil
+	}
+	out, err := pii.Transform(v, func(_, s string) (string, error) {
+		out, _, err := k.Reseal(s)
+		return out, err
+	})
+	return out, err == nil, err
+}
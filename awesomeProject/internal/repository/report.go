@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"awesomeProject/internal/model"
+	"context"
+	"time"
+)
+
+// RevenueByCurrency суммирует оплаты заказов, созданных в [from, to), по валютам. Отменённые и
+// возвращённые заказы в выручку не входят.
+func (repo *Repository) RevenueByCurrency(ctx context.Context, from, to time.Time) ([]model.CurrencyRevenue, error) {
+	rows, err := repo.db.QueryContext(ctx, "SELECT COALESCE(p.currency, ''), COUNT(*), COALESCE(SUM(p.amount), 0) "+
+		"FROM orders o JOIN payment p ON p.order_uid = o.order_uid "+
+		"WHERE o.date_created >= $1 AND o.date_created < $2 AND o.status NOT IN ($3, $4) "+
+		"GROUP BY 1 ORDER BY 1",
+		from.UTC(), to.UTC(), model.StatusCancelled, model.StatusReturned)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []model.CurrencyRevenue
+	for rows.Next() {
+		var r model.CurrencyRevenue
+		if err := rows.Scan(&r.Currency, &r.Orders, &r.Amount); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
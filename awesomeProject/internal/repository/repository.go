@@ -1,76 +1,326 @@
 package repository
 
 import (
+	"awesomeProject/internal/envelope"
 	"awesomeProject/internal/model"
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"time"
 )
 
 var ErrNotFound = errors.New("order not found")
 
 type Repository struct {
-	db *sql.DB
+	db      *sql.DB
+	keyring *envelope.Keyring
 }
 
 func NewRepository(db *sql.DB) *Repository {
 	return &Repository{db: db}
 }
 
-func (repo *Repository) InsertOrder(ctx context.Context, order model.Order) error {
+// WithKeyring включает шифрование персональных данных доставки: поля с тегом pii пишутся
+// в БД зашифрованными и прозрачно расшифровываются при чтении.
+func (repo *Repository) WithKeyring(k *envelope.Keyring) *Repository {
+	repo.keyring = k
+	return repo
+}
+
+func (repo *Repository) seal(order model.Order) (model.Order, error) {
+	d, err := envelope.Seal(repo.keyring, order.Delivery)
+	if err != nil {
+		return order, fmt.Errorf("encrypt delivery: %w", err)
+	}
+	order.Delivery = d
+	return order, nil
+}
+
+func (repo *Repository) open(order model.Order) (model.Order, error) {
+	d, err := envelope.Open(repo.keyring, order.Delivery)
+	if err != nil {
+		return order, fmt.Errorf("decrypt delivery of %s: %w", order.OrderUID, err)
+	}
+	order.Delivery = d
+	return order, nil
+}
+
+// InsertOrder сохраняет заказ, если его ещё нет, и сообщает, был ли он создан: существующий
+// заказ с тем же order_uid не перезаписывается.
+func (repo *Repository) InsertOrder(ctx context.Context, order model.Order) (bool, error) {
+	plain := order
+	order, err := repo.seal(order)
+	if err != nil {
+		return false, err
+	}
 	tx, err := repo.db.BeginTx(ctx, &sql.TxOptions{})
 	if err != nil {
-		return err
+		return false, err
 	}
 	defer func() { _ = tx.Rollback() }()
-	_, err = tx.ExecContext(ctx, "INSERT INTO orders (order_uid, track_number, entry, locale, "+
+	if off, ok := consumerOffsetFrom(ctx); ok {
+		if err := claimOffset(ctx, tx, off); err != nil {
+			return false, err
+		}
+	}
+	res, err := tx.ExecContext(ctx, "INSERT INTO orders (order_uid, track_number, entry, locale, "+
 		"internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard)"+
 		"VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11) ON CONFLICT (order_uid) DO NOTHING;",
-		order.Order_uid, order.Track_number, order.Entry, order.Locale, order.Internal_signature,
-		order.Customer_id, order.Delivery_service, order.Shardkey, order.Sm_id, order.Date_created, order.Oof_shard)
+		order.OrderUID, order.TrackNumber, order.Entry, order.Locale, order.InternalSignature,
+		order.CustomerID, order.DeliveryService, order.ShardKey, order.SmID, order.DateCreated, order.OOFShard)
 	if err != nil {
-		return err
+		return false, err
+	}
+	created, err := res.RowsAffected()
+	if err != nil {
+		return false, err
 	}
 
 	_, err = tx.ExecContext(ctx, "INSERT INTO delivery (order_uid, \"name\", phone, zip, city, address, region, email) "+
 		"VALUES ($1,$2,$3,$4,$5,$6,$7,$8) ON CONFLICT (order_uid) DO NOTHING;",
-		order.Order_uid, order.Delivery.Name, order.Delivery.Phone, order.Delivery.Zip, order.Delivery.City,
+		order.OrderUID, order.Delivery.Name, order.Delivery.Phone, order.Delivery.Zip, order.Delivery.City,
 		order.Delivery.Address, order.Delivery.Region, order.Delivery.Email)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	_, err = tx.ExecContext(ctx, "INSERT INTO payment (order_uid, \"transaction\", request_id, currency, provider, amount,"+
 		" payment_dt, bank, delivery_cost, goods_total, custom_fee) "+
 		"VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11) ON CONFLICT (order_uid) DO NOTHING;",
-		order.Order_uid, order.Payment.Transaction, order.Payment.Request_id, order.Payment.Currency,
-		order.Payment.Provider, order.Payment.Amount, order.Payment.Payment_dt,
-		order.Payment.Bank, order.Payment.Delivery_cost, order.Payment.Goods_total, order.Payment.Custom_fee)
+		order.OrderUID, order.Payment.Transaction, order.Payment.RequestID, order.Payment.Currency,
+		order.Payment.Provider, order.Payment.Amount, order.Payment.PaymentTime.Seconds(),
+		order.Payment.Bank, order.Payment.DeliveryCost, order.Payment.GoodsTotal, order.Payment.CustomFee)
 	if err != nil {
-		return err
+		return false, err
 	}
 
+	if err := insertItems(ctx, tx, order); err != nil {
+		return false, err
+	}
+	// Повторная доставка уже сохранённого заказа ничего не меняет: ни события, ни записи в журнале.
+	if created == 1 {
+		if err := insertOutbox(ctx, tx, model.EventOrderCreated, plain); err != nil {
+			return false, err
+		}
+		if err := insertAudit(ctx, tx, model.AuditRecord{
+			OrderUID: order.OrderUID, Action: model.AuditCreated, After: &order,
+		}); err != nil {
+			return false, err
+		}
+	}
+	return created == 1, tx.Commit()
+}
+
+func insertItems(ctx context.Context, tx *sql.Tx, order model.Order) error {
 	for _, item := range order.Items {
-		_, err = tx.ExecContext(ctx, "INSERT INTO items (order_uid, chrt_id, track_number, price, rid, \"name\", sale, "+
+		_, err := tx.ExecContext(ctx, "INSERT INTO items (order_uid, chrt_id, track_number, price, rid, \"name\", sale, "+
 			"\"size\", total_price, nm_id, brand, status) "+
 			"VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12) ON CONFLICT (order_uid, chrt_id) DO NOTHING",
-			order.Order_uid, item.Chrt_id, item.Track_number, item.Price,
-			item.Rid, item.Name, item.Sale, item.Size, item.Total_price,
-			item.Nm_id, item.Brand, item.Status)
+			order.OrderUID, item.ChrtID, item.TrackNumber, item.Price,
+			item.RID, item.Name, item.Sale, item.Size, item.TotalPrice,
+			item.NmID, item.Brand, item.Status)
 		if err != nil {
 			return err
 		}
 	}
+	return nil
+}
+
+// ReplaceOrder полностью перезаписывает заказ (или создаёт его) и сообщает, был ли он создан.
+func (repo *Repository) ReplaceOrder(ctx context.Context, order model.Order) (bool, error) {
+	tx, err := repo.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	created, err := repo.replaceOrderTx(ctx, tx, order)
+	if err != nil {
+		return false, err
+	}
+	return created, tx.Commit()
+}
+
+// ReplaceOrders перезаписывает пачку заказов одной транзакцией; при ошибке не пишется ни один.
+func (repo *Repository) ReplaceOrders(ctx context.Context, orders []model.Order) ([]bool, error) {
+	tx, err := repo.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	created := make([]bool, len(orders))
+	for i, order := range orders {
+		if created[i], err = repo.replaceOrderTx(ctx, tx, order); err != nil {
+			return nil, err
+		}
+	}
+	return created, tx.Commit()
+}
+
+// UpdateOrder меняет существующий заказ функцией update под блокировкой строки: update получает
+// текущий заказ в открытом виде, и параллельные изменения одного заказа не теряют друг друга.
+// Ошибка update отменяет изменение и возвращается как есть.
+func (repo *Repository) UpdateOrder(ctx context.Context, id string,
+	update func(model.Order) (model.Order, error)) (model.Order, error) {
+	tx, err := repo.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return model.Order{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	prev, err := lockOrder(ctx, tx, id)
+	if err != nil {
+		return model.Order{}, err
+	}
+	if prev == nil {
+		return model.Order{}, ErrNotFound
+	}
+	current, err := repo.open(*prev)
+	if err != nil {
+		return model.Order{}, err
+	}
+	order, err := update(current)
+	if err != nil {
+		return model.Order{}, err
+	}
+	if order.OrderUID != id {
+		return model.Order{}, fmt.Errorf("update changed order_uid %s to %s", id, order.OrderUID)
+	}
+	if _, err := repo.writeOrderTx(ctx, tx, prev, order); err != nil {
+		return model.Order{}, err
+	}
+	return order, tx.Commit()
+}
+
+// replaceOrderTx перезаписывает заказ в транзакции tx; order — в открытом виде, шифруется здесь,
+// чтобы журнал изменений мог сравнить его с прежней версией до шифрования.
+func (repo *Repository) replaceOrderTx(ctx context.Context, tx *sql.Tx, order model.Order) (bool, error) {
+	prev, err := lockOrder(ctx, tx, order.OrderUID)
+	if err != nil {
+		return false, err
+	}
+	return repo.writeOrderTx(ctx, tx, prev, order)
+}
+
+// writeOrderTx записывает заказ поверх prev (строка уже заблокирована, nil — заказа нет) вместе
+// с событием outbox и записью журнала.
+func (repo *Repository) writeOrderTx(ctx context.Context, tx *sql.Tx, prev *model.Order, order model.Order) (bool, error) {
+	audit, sealed, err := repo.auditUpdate(prev, order)
+	if err != nil {
+		return false, err
+	}
+	created, err := upsertOrderRows(ctx, tx, sealed)
+	if err != nil {
+		return false, err
+	}
+	event := model.EventOrderUpdated
+	if created {
+		event = model.EventOrderCreated
+	}
+	if err := insertOutbox(ctx, tx, event, order); err != nil {
+		return false, err
+	}
+	if err := insertAudit(ctx, tx, audit); err != nil {
+		return false, err
+	}
+	return created, nil
+}
+
+// upsertOrderRows записывает строки заказа в orders, delivery, payment и items как есть, без
+// шифрования, журнала и outbox, и сообщает, была ли строка orders создана.
+func upsertOrderRows(ctx context.Context, tx *sql.Tx, order model.Order) (bool, error) {
+	var created bool
+	err := tx.QueryRowContext(ctx, "INSERT INTO orders (order_uid, track_number, entry, locale, "+
+		"internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard) "+
+		"VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11) ON CONFLICT (order_uid) DO UPDATE SET "+
+		"track_number=EXCLUDED.track_number, entry=EXCLUDED.entry, locale=EXCLUDED.locale, "+
+		"internal_signature=EXCLUDED.internal_signature, customer_id=EXCLUDED.customer_id, "+
+		"delivery_service=EXCLUDED.delivery_service, shardkey=EXCLUDED.shardkey, sm_id=EXCLUDED.sm_id, "+
+		"date_created=EXCLUDED.date_created, oof_shard=EXCLUDED.oof_shard RETURNING (xmax = 0);",
+		order.OrderUID, order.TrackNumber, order.Entry, order.Locale, order.InternalSignature,
+		order.CustomerID, order.DeliveryService, order.ShardKey, order.SmID, order.DateCreated,
+		order.OOFShard).Scan(&created)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO delivery (order_uid, \"name\", phone, zip, city, address, region, email) "+
+		"VALUES ($1,$2,$3,$4,$5,$6,$7,$8) ON CONFLICT (order_uid) DO UPDATE SET "+
+		"\"name\"=EXCLUDED.\"name\", phone=EXCLUDED.phone, zip=EXCLUDED.zip, city=EXCLUDED.city, "+
+		"address=EXCLUDED.address, region=EXCLUDED.region, email=EXCLUDED.email;",
+		order.OrderUID, order.Delivery.Name, order.Delivery.Phone, order.Delivery.Zip, order.Delivery.City,
+		order.Delivery.Address, order.Delivery.Region, order.Delivery.Email)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO payment (order_uid, \"transaction\", request_id, currency, provider, amount,"+
+		" payment_dt, bank, delivery_cost, goods_total, custom_fee) "+
+		"VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11) ON CONFLICT (order_uid) DO UPDATE SET "+
+		"\"transaction\"=EXCLUDED.\"transaction\", request_id=EXCLUDED.request_id, currency=EXCLUDED.currency, "+
+		"provider=EXCLUDED.provider, amount=EXCLUDED.amount, payment_dt=EXCLUDED.payment_dt, bank=EXCLUDED.bank, "+
+		"delivery_cost=EXCLUDED.delivery_cost, goods_total=EXCLUDED.goods_total, custom_fee=EXCLUDED.custom_fee;",
+		order.OrderUID, order.Payment.Transaction, order.Payment.RequestID, order.Payment.Currency,
+		order.Payment.Provider, order.Payment.Amount, order.Payment.PaymentTime.Seconds(),
+		order.Payment.Bank, order.Payment.DeliveryCost, order.Payment.GoodsTotal, order.Payment.CustomFee)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM items WHERE order_uid=$1", order.OrderUID); err != nil {
+		return false, err
+	}
+	if err := insertItems(ctx, tx, order); err != nil {
+		return false, err
+	}
+	return created, nil
+}
+
+func (repo *Repository) DeleteOrder(ctx context.Context, id string) error {
+	tx, err := repo.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	prev, err := lockOrder(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if prev == nil {
+		return ErrNotFound
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM orders WHERE order_uid=$1", id); err != nil {
+		return err
+	}
+	if err := insertOutbox(ctx, tx, model.EventOrderDeleted, model.Order{OrderUID: id}); err != nil {
+		return err
+	}
+	if err := insertAudit(ctx, tx, model.AuditRecord{OrderUID: id, Action: model.AuditDeleted, Before: prev}); err != nil {
+		return err
+	}
 	return tx.Commit()
 }
 
 func (repo *Repository) GetOrderById(ctx context.Context, id string) (model.Order, error) {
+	order, err := getOrder(ctx, repo.db, id)
+	if err != nil {
+		return order, err
+	}
+	return repo.open(order)
+}
+
+// querier — общее у *sql.DB и *sql.Tx: заказ читается и вне транзакции, и внутри неё.
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// getOrder читает заказ в том виде, в каком он лежит в БД, без расшифровки доставки.
+func getOrder(ctx context.Context, q querier, id string) (model.Order, error) {
 	var order model.Order
-	row := repo.db.QueryRowContext(ctx, "SELECT order_uid, track_number, entry, locale, internal_signature,"+
+	row := q.QueryRowContext(ctx, "SELECT order_uid, track_number, entry, locale, internal_signature,"+
 		"customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard FROM orders WHERE order_uid=$1", id)
-	err := row.Scan(&order.Order_uid, &order.Track_number, &order.Entry, &order.Locale, &order.Internal_signature,
-		&order.Customer_id, &order.Delivery_service, &order.Shardkey, &order.Sm_id, &order.Date_created, &order.Oof_shard)
+	err := row.Scan(&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Locale, &order.InternalSignature,
+		&order.CustomerID, &order.DeliveryService, &order.ShardKey, &order.SmID, &order.DateCreated, &order.OOFShard)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return model.Order{}, ErrNotFound
@@ -78,25 +328,27 @@ func (repo *Repository) GetOrderById(ctx context.Context, id string) (model.Orde
 		return order, err
 	}
 
-	row = repo.db.QueryRowContext(ctx, "SELECT \"name\", phone, zip, city, address, region, email"+
+	row = q.QueryRowContext(ctx, "SELECT \"name\", phone, zip, city, address, region, email"+
 		" FROM delivery WHERE order_uid=$1", id)
 	_ = row.Scan(&order.Delivery.Name, &order.Delivery.Phone, &order.Delivery.Zip,
 		&order.Delivery.City, &order.Delivery.Address, &order.Delivery.Region, &order.Delivery.Email)
 
-	row = repo.db.QueryRowContext(ctx, "SELECT \"transaction\", request_id, currency, provider, amount, "+
+	row = q.QueryRowContext(ctx, "SELECT \"transaction\", request_id, currency, provider, amount, "+
 		"payment_dt, bank, delivery_cost, goods_total, custom_fee FROM payment WHERE order_uid=$1", id)
-	_ = row.Scan(&order.Payment.Transaction, &order.Payment.Request_id, &order.Payment.Currency,
-		&order.Payment.Provider, &order.Payment.Amount, &order.Payment.Payment_dt, &order.Payment.Bank,
-		&order.Payment.Delivery_cost, &order.Payment.Goods_total, &order.Payment.Custom_fee)
+	var paymentDT int64
+	_ = row.Scan(&order.Payment.Transaction, &order.Payment.RequestID, &order.Payment.Currency,
+		&order.Payment.Provider, &order.Payment.Amount, &paymentDT, &order.Payment.Bank,
+		&order.Payment.DeliveryCost, &order.Payment.GoodsTotal, &order.Payment.CustomFee)
+	order.Payment.PaymentTime = model.FromUnixSeconds(paymentDT)
 
-	rows, err := repo.db.QueryContext(ctx, "SELECT chrt_id, track_number, price, rid, \"name\", sale, "+
+	rows, err := q.QueryContext(ctx, "SELECT chrt_id, track_number, price, rid, \"name\", sale, "+
 		"\"size\", total_price, nm_id, brand, status FROM items WHERE order_uid=$1", id)
 	if err == nil {
 		defer rows.Close()
 		for rows.Next() {
-			var item model.Items
-			if err := rows.Scan(&item.Chrt_id, &item.Track_number, &item.Price, &item.Rid, &item.Name,
-				&item.Sale, &item.Size, &item.Total_price, &item.Nm_id, &item.Brand, &item.Status); err == nil {
+			var item model.Item
+			if err := rows.Scan(&item.ChrtID, &item.TrackNumber, &item.Price, &item.RID, &item.Name,
+				&item.Sale, &item.Size, &item.TotalPrice, &item.NmID, &item.Brand, &item.Status); err == nil {
 				order.Items = append(order.Items, item)
 			}
 		}
@@ -153,17 +405,17 @@ func (repo *Repository) LoadAll(ctx context.Context) ([]model.Order, error) {
 		order, ok := acc[rrow.orderUID]
 		if !ok {
 			order = &model.Order{
-				Order_uid:          rrow.orderUID,
-				Track_number:       rrow.trackNumber,
-				Entry:              rrow.entry,
-				Locale:             rrow.locale,
-				Internal_signature: rrow.internalSignature,
-				Customer_id:        rrow.customerID,
-				Delivery_service:   rrow.deliveryService,
-				Shardkey:           rrow.shardkey,
-				Sm_id:              int(rrow.smID.Int64),
-				Date_created:       rrow.dateCreated,
-				Oof_shard:          rrow.oofShard,
+				OrderUID:          rrow.orderUID,
+				TrackNumber:       rrow.trackNumber,
+				Entry:             model.Entry(rrow.entry),
+				Locale:            model.Locale(rrow.locale),
+				InternalSignature: rrow.internalSignature,
+				CustomerID:        rrow.customerID,
+				DeliveryService:   rrow.deliveryService,
+				ShardKey:          rrow.shardkey,
+				SmID:              int(rrow.smID.Int64),
+				DateCreated:       rrow.dateCreated,
+				OOFShard:          rrow.oofShard,
 				Delivery: model.Delivery{
 					Name:    rrow.dName,
 					Phone:   rrow.dPhone,
@@ -174,34 +426,34 @@ func (repo *Repository) LoadAll(ctx context.Context) ([]model.Order, error) {
 					Email:   rrow.dEmail,
 				},
 				Payment: model.Payment{
-					Transaction:   rrow.pTransaction,
-					Request_id:    rrow.pRequestID,
-					Currency:      rrow.pCurrency,
-					Provider:      rrow.pProvider,
-					Amount:        int(rrow.pAmount.Int64),
-					Payment_dt:    int(rrow.pPaymentDT.Int64),
-					Bank:          rrow.pBank,
-					Delivery_cost: int(rrow.pDeliveryCost.Int64),
-					Goods_total:   int(rrow.pGoodsTotal.Int64),
-					Custom_fee:    int(rrow.pCustomFee.Int64),
+					Transaction:  rrow.pTransaction,
+					RequestID:    rrow.pRequestID,
+					Currency:     rrow.pCurrency,
+					Provider:     rrow.pProvider,
+					Amount:       int(rrow.pAmount.Int64),
+					PaymentTime:  model.FromUnixSeconds(rrow.pPaymentDT.Int64),
+					Bank:         rrow.pBank,
+					DeliveryCost: int(rrow.pDeliveryCost.Int64),
+					GoodsTotal:   int(rrow.pGoodsTotal.Int64),
+					CustomFee:    int(rrow.pCustomFee.Int64),
 				},
-				Items: make([]model.Items, 0, 4),
+				Items: make([]model.Item, 0, 4),
 			}
 			acc[rrow.orderUID] = order
 		}
 		if rrow.iChrtID.Valid {
-			order.Items = append(order.Items, model.Items{
-				Chrt_id:      int(rrow.iChrtID.Int64),
-				Track_number: rrow.iTrackNumber.String,
-				Price:        int(rrow.iPrice.Int64),
-				Rid:          rrow.iRID.String,
-				Name:         rrow.iName.String,
-				Sale:         int(rrow.iSale.Int64),
-				Size:         rrow.iSize.String,
-				Total_price:  int(rrow.iTotalPrice.Int64),
-				Nm_id:        int(rrow.iNmID.Int64),
-				Brand:        rrow.iBrand.String,
-				Status:       int(rrow.iStatus.Int64),
+			order.Items = append(order.Items, model.Item{
+				ChrtID:      int(rrow.iChrtID.Int64),
+				TrackNumber: rrow.iTrackNumber.String,
+				Price:       int(rrow.iPrice.Int64),
+				RID:         rrow.iRID.String,
+				Name:        rrow.iName.String,
+				Sale:        int(rrow.iSale.Int64),
+				Size:        rrow.iSize.String,
+				TotalPrice:  int(rrow.iTotalPrice.Int64),
+				NmID:        int(rrow.iNmID.Int64),
+				Brand:       rrow.iBrand.String,
+				Status:      model.ItemStatus(rrow.iStatus.Int64),
 			})
 		}
 	}
@@ -210,7 +462,11 @@ func (repo *Repository) LoadAll(ctx context.Context) ([]model.Order, error) {
 	}
 	out := make([]model.Order, 0, len(acc))
 	for _, o := range acc {
-		out = append(out, *o)
+		order, err := repo.open(*o)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, order)
 	}
 	return out, nil
 }
@@ -0,0 +1,294 @@
+package repository
+
+import (
+	"awesomeProject/internal/model"
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Sharded распределяет заказы по нескольким БД по shardkey согласно ShardMap. Новый заказ
+// пишется на шард из карты; существующий читается и меняется там, где лежит, даже если карта
+// или его shardkey с тех пор изменились: такие заказы переносит Rebalance. Поиск по order_uid
+// без shardkey опрашивает все шарды параллельно.
+//
+// Каждый шард — полноценная БД со всеми миграциями: outbox, offset'ы Kafka, история статусов
+// и журнал изменений заказа хранятся на том же шарде, что и заказ.
+type Sharded struct {
+	shardMap ShardMap
+	names    []string
+	shards   map[string]*Repository
+}
+
+// NewSharded собирает маршрутизатор из репозиториев шардов; для каждого шарда карты нужен
+// репозиторий с тем же именем.
+func NewSharded(m ShardMap, shards map[string]*Repository) (*Sharded, error) {
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	for _, name := range m.Names() {
+		if shards[name] == nil {
+			return nil, fmt.Errorf("shard %q: no repository", name)
+		}
+	}
+	return &Sharded{shardMap: m, names: m.Names(), shards: shards}, nil
+}
+
+func (s *Sharded) Names() []string { return s.names }
+
+func (s *Sharded) Shard(name string) *Repository { return s.shards[name] }
+
+// Primary — шард по умолчанию; на нём хранятся ключи идемпотентности.
+func (s *Sharded) Primary() *Repository { return s.shards[s.shardMap.Default] }
+
+// eachShard вызывает fn на всех шардах параллельно; результаты и ошибки — в порядке s.names.
+func eachShard[T any](ctx context.Context, s *Sharded, fn func(ctx context.Context, repo *Repository) (T, error)) ([]T, []error) {
+	out := make([]T, len(s.names))
+	errs := make([]error, len(s.names))
+	var wg sync.WaitGroup
+	for i, name := range s.names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out[i], errs[i] = fn(ctx, s.shards[name])
+		}()
+	}
+	wg.Wait()
+	return out, errs
+}
+
+type shardKeyResult struct {
+	key   string
+	found bool
+}
+
+// locate находит шард, на котором лежит заказ. Если заказ есть на нескольких шардах (перенос
+// прерван), выбирается шард из карты.
+func (s *Sharded) locate(ctx context.Context, id string) (*Repository, error) {
+	res, errs := eachShard(ctx, s, func(ctx context.Context, repo *Repository) (shardKeyResult, error) {
+		key, found, err := repo.shardKeyOf(ctx, id)
+		return shardKeyResult{key, found}, err
+	})
+	found := -1
+	for i, r := range res {
+		if !r.found {
+			continue
+		}
+		if found < 0 || s.names[i] == s.shardMap.ShardFor(r.key) {
+			found = i
+		}
+	}
+	if found >= 0 {
+		return s.shards[s.names[found]], nil
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return nil, ErrNotFound
+}
+
+// forWrite выбирает шард для записи заказа: тот, где он уже лежит, иначе шард из карты.
+// Сначала проверяется шард из карты, поэтому недоступность остальных шардов не мешает
+// менять заказы, которые лежат на своём месте.
+func (s *Sharded) forWrite(ctx context.Context, order model.Order) (*Repository, error) {
+	home := s.shards[s.shardMap.ShardFor(order.ShardKey)]
+	if _, found, err := home.shardKeyOf(ctx, order.OrderUID); err != nil || found {
+		return home, err
+	}
+	repo, err := s.locate(ctx, order.OrderUID)
+	if errors.Is(err, ErrNotFound) {
+		return home, nil
+	}
+	return repo, err
+}
+
+// shardKeyOf возвращает shardkey заказа и есть ли он на этом шарде.
+func (repo *Repository) shardKeyOf(ctx context.Context, id string) (string, bool, error) {
+	var key string
+	err := repo.db.QueryRowContext(ctx, "SELECT COALESCE(shardkey, '') FROM orders WHERE order_uid=$1", id).Scan(&key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	return key, err == nil, err
+}
+
+func (s *Sharded) InsertOrder(ctx context.Context, order model.Order) (bool, error) {
+	repo, err := s.forWrite(ctx, order)
+	if err != nil {
+		return false, err
+	}
+	return repo.InsertOrder(ctx, order)
+}
+
+func (s *Sharded) ReplaceOrder(ctx context.Context, order model.Order) (bool, error) {
+	repo, err := s.forWrite(ctx, order)
+	if err != nil {
+		return false, err
+	}
+	return repo.ReplaceOrder(ctx, order)
+}
+
+// ReplaceOrders пишет пачку отдельной транзакцией на каждый шард: пачка атомарна в пределах
+// шарда, но при ошибке на одном шарде заказы на других уже могут быть записаны. Перезапись
+// идемпотентна, поэтому пачку можно просто повторить.
+func (s *Sharded) ReplaceOrders(ctx context.Context, orders []model.Order) ([]bool, error) {
+	groups := make(map[*Repository][]int)
+	var repos []*Repository
+	for i, o := range orders {
+		repo, err := s.forWrite(ctx, o)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := groups[repo]; !ok {
+			repos = append(repos, repo)
+		}
+		groups[repo] = append(groups[repo], i)
+	}
+	created := make([]bool, len(orders))
+	for _, repo := range repos {
+		idx := groups[repo]
+		batch := make([]model.Order, len(idx))
+		for j, i := range idx {
+			batch[j] = orders[i]
+		}
+		res, err := repo.ReplaceOrders(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+		for j, i := range idx {
+			created[i] = res[j]
+		}
+	}
+	return created, nil
+}
+
+func (s *Sharded) GetOrderById(ctx context.Context, id string) (model.Order, error) {
+	repo, err := s.locate(ctx, id)
+	if err != nil {
+		return model.Order{}, err
+	}
+	return repo.GetOrderById(ctx, id)
+}
+
+func (s *Sharded) UpdateOrder(ctx context.Context, id string,
+	update func(model.Order) (model.Order, error)) (model.Order, error) {
+	repo, err := s.locate(ctx, id)
+	if err != nil {
+		return model.Order{}, err
+	}
+	return repo.UpdateOrder(ctx, id, update)
+}
+
+func (s *Sharded) DeleteOrder(ctx context.Context, id string) error {
+	repo, err := s.locate(ctx, id)
+	if err != nil {
+		return err
+	}
+	return repo.DeleteOrder(ctx, id)
+}
+
+func (s *Sharded) ChangeStatus(ctx context.Context, change model.StatusChange) (model.StatusChange, error) {
+	repo, err := s.locate(ctx, change.OrderUID)
+	if err != nil {
+		return change, err
+	}
+	return repo.ChangeStatus(ctx, change)
+}
+
+func (s *Sharded) StatusHistory(ctx context.Context, id string) (model.StatusHistory, error) {
+	repo, err := s.locate(ctx, id)
+	if err != nil {
+		return model.StatusHistory{OrderUID: id}, err
+	}
+	return repo.StatusHistory(ctx, id)
+}
+
+// OrderHistory собирает журнал со всех шардов: после удаления заказа его журнал остаётся на
+// шарде, где заказ лежал.
+func (s *Sharded) OrderHistory(ctx context.Context, id string) ([]model.AuditRecord, error) {
+	res, errs := eachShard(ctx, s, func(ctx context.Context, repo *Repository) ([]model.AuditRecord, error) {
+		list, err := repo.OrderHistory(ctx, id)
+		if errors.Is(err, ErrNotFound) {
+			return nil, nil
+		}
+		return list, err
+	})
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	out := slices.Concat(res...)
+	if len(out) == 0 {
+		return nil, ErrNotFound
+	}
+	slices.SortStableFunc(out, func(a, b model.AuditRecord) int { return a.ChangedAt.Compare(b.ChangedAt) })
+	return out, nil
+}
+
+func (s *Sharded) LoadAll(ctx context.Context) ([]model.Order, error) {
+	res, errs := eachShard(ctx, s, func(ctx context.Context, repo *Repository) ([]model.Order, error) {
+		return repo.LoadAll(ctx)
+	})
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return slices.Concat(res...), nil
+}
+
+func (s *Sharded) RevenueByCurrency(ctx context.Context, from, to time.Time) ([]model.CurrencyRevenue, error) {
+	res, errs := eachShard(ctx, s, func(ctx context.Context, repo *Repository) ([]model.CurrencyRevenue, error) {
+		return repo.RevenueByCurrency(ctx, from, to)
+	})
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	index := make(map[string]int)
+	var out []model.CurrencyRevenue
+	for _, r := range slices.Concat(res...) {
+		if i, ok := index[r.Currency]; ok {
+			out[i].Orders += r.Orders
+			out[i].Amount += r.Amount
+			continue
+		}
+		index[r.Currency] = len(out)
+		out = append(out, r)
+	}
+	slices.SortFunc(out, func(a, b model.CurrencyRevenue) int { return cmp.Compare(a.Currency, b.Currency) })
+	return out, nil
+}
+
+// ConsumerOffsets возвращает для каждой партиции наименьший offset среди шардов. Сообщения
+// партиции применяются не по порядку, и позиция сдвигается на всех шардах отдельно
+// (AdvanceConsumerOffset), так что часть шардов может отставать. Чтение с наименьшего
+// ничего не теряет: сообщения, которые шард уже применил, он отклонит сам. Шард без строки
+// партиции не получал её сообщений и в минимуме не участвует.
+func (s *Sharded) ConsumerOffsets(ctx context.Context, group, topic string) (map[int]int64, error) {
+	res, errs := eachShard(ctx, s, func(ctx context.Context, repo *Repository) (map[int]int64, error) {
+		return repo.ConsumerOffsets(ctx, group, topic)
+	})
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	out := make(map[int]int64)
+	for _, m := range res {
+		for p, next := range m {
+			if cur, ok := out[p]; !ok || next < cur {
+				out[p] = next
+			}
+		}
+	}
+	return out, nil
+}
+
+// AdvanceConsumerOffset сдвигает позицию партиции на всех шардах: сообщения партиции
+// пишутся в разные шарды, а префикс обработанных у них общий.
+func (s *Sharded) AdvanceConsumerOffset(ctx context.Context, group, topic string, partition int, next int64) error {
+	_, errs := eachShard(ctx, s, func(ctx context.Context, repo *Repository) (struct{}, error) {
+		return struct{}{}, repo.AdvanceConsumerOffset(ctx, group, topic, partition, next)
+	})
+	return errors.Join(errs...)
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+)
+
+// ShardMap — конфигурация шардирования: строки подключения шардов и шард для каждого значения
+// shardkey. Заказы с shardkey, которого нет в Keys, хранятся на шарде Default; там же лежат
+// данные, не привязанные к заказу (ключи идемпотентности).
+//
+//	{
+//	  "shards":  {"s0": "host=pg0 dbname=orders user=app password=${DB_PASSWORD} sslmode=disable",
+//	              "s1": "host=pg1 dbname=orders user=app password=${DB_PASSWORD} sslmode=disable"},
+//	  "keys":    {"0": "s0", "1": "s0", "2": "s0", "3": "s0", "4": "s0",
+//	              "5": "s1", "6": "s1", "7": "s1", "8": "s1", "9": "s1"},
+//	  "default": "s0"
+//	}
+type ShardMap struct {
+	Shards  map[string]string `json:"shards"`
+	Keys    map[string]string `json:"keys"`
+	Default string            `json:"default"`
+}
+
+// LoadShardMap читает карту шардов из JSON-файла. Переменные окружения в строках подключения
+// (${DB_PASSWORD}) подставляются, чтобы пароли не хранились в файле.
+func LoadShardMap(path string) (ShardMap, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ShardMap{}, err
+	}
+	var m ShardMap
+	if err := json.Unmarshal(data, &m); err != nil {
+		return ShardMap{}, fmt.Errorf("%s: %w", path, err)
+	}
+	for name, dsn := range m.Shards {
+		m.Shards[name] = os.ExpandEnv(dsn)
+	}
+	if err := m.Validate(); err != nil {
+		return ShardMap{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+// Validate проверяет, что шарды заданы, а Keys и Default ссылаются только на них.
+func (m ShardMap) Validate() error {
+	if len(m.Shards) == 0 {
+		return errors.New("shard map: no shards")
+	}
+	if _, ok := m.Shards[m.Default]; !ok {
+		return fmt.Errorf("shard map: default shard %q is not in shards", m.Default)
+	}
+	for key, name := range m.Keys {
+		if _, ok := m.Shards[name]; !ok {
+			return fmt.Errorf("shard map: shardkey %q points to unknown shard %q", key, name)
+		}
+	}
+	return nil
+}
+
+// ShardFor возвращает шард для значения shardkey.
+func (m ShardMap) ShardFor(shardKey string) string {
+	if name, ok := m.Keys[shardKey]; ok {
+		return name
+	}
+	return m.Default
+}
+
+// Names возвращает имена шардов по алфавиту.
+func (m ShardMap) Names() []string {
+	names := make([]string, 0, len(m.Shards))
+	for name := range m.Shards {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"awesomeProject/internal/model"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ChangeStatus переводит заказ в статус change.To, если переход разрешён из текущего, и
+// записывает смену в историю и outbox одной транзакцией. Строка заказа блокируется, поэтому
+// конкурирующие смены статуса проверяются последовательно. Возвращает записанную смену с
+// заполненными From и ChangedAt.
+func (repo *Repository) ChangeStatus(ctx context.Context, change model.StatusChange) (model.StatusChange, error) {
+	tx, err := repo.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return change, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if off, ok := consumerOffsetFrom(ctx); ok {
+		if err := claimOffset(ctx, tx, off); err != nil {
+			return change, err
+		}
+	}
+	var current model.OrderStatus
+	err = tx.QueryRowContext(ctx, "SELECT status FROM orders WHERE order_uid=$1 FOR UPDATE",
+		change.OrderUID).Scan(&current)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return change, ErrNotFound
+		}
+		return change, err
+	}
+	if !current.CanTransitionTo(change.To) {
+		return change, fmt.Errorf("%w: %s -> %s", model.ErrInvalidTransition, current, change.To)
+	}
+	change.From = current
+	if change.Source == "" {
+		change.Source = SourceFrom(ctx)
+	}
+	if change.ChangedAt.IsZero() {
+		change.ChangedAt = time.Now().UTC()
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE orders SET status=$2 WHERE order_uid=$1",
+		change.OrderUID, change.To); err != nil {
+		return change, err
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO status_history (order_uid, from_status, to_status, source, "+
+		"reason, changed_at) VALUES ($1,$2,$3,$4,$5,$6)",
+		change.OrderUID, change.From, change.To, change.Source, change.Reason, change.ChangedAt); err != nil {
+		return change, err
+	}
+	if err := writeOutbox(ctx, tx, model.OrderEvent{
+		Type:       model.EventOrderStatusChanged,
+		OrderUID:   change.OrderUID,
+		OccurredAt: change.ChangedAt,
+		Status:     &change,
+	}); err != nil {
+		return change, err
+	}
+	return change, tx.Commit()
+}
+
+// StatusHistory возвращает текущий статус заказа и историю его смен в порядке записи.
+func (repo *Repository) StatusHistory(ctx context.Context, id string) (model.StatusHistory, error) {
+	h := model.StatusHistory{OrderUID: id, History: make([]model.StatusChange, 0, 4)}
+	err := repo.db.QueryRowContext(ctx, "SELECT status FROM orders WHERE order_uid=$1", id).Scan(&h.Status)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return h, ErrNotFound
+		}
+		return h, err
+	}
+	rows, err := repo.db.QueryContext(ctx, "SELECT from_status, to_status, source, reason, changed_at "+
+		"FROM status_history WHERE order_uid=$1 ORDER BY id", id)
+	if err != nil {
+		return h, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		c := model.StatusChange{OrderUID: id}
+		if err := rows.Scan(&c.From, &c.To, &c.Source, &c.Reason, &c.ChangedAt); err != nil {
+			return h, err
+		}
+		h.History = append(h.History, c)
+	}
+	return h, rows.Err()
+}
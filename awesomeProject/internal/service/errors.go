@@ -0,0 +1,28 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrInvalidOrder = errors.New("invalid order")
+	ErrDependency   = errors.New("dependency unavailable")
+)
+
+type ValidationError struct {
+	Field  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Reason)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrInvalidOrder
+}
+
+func invalid(field, reason string) error {
+	return &ValidationError{Field: field, Reason: reason}
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"awesomeProject/internal/model"
+	"encoding/json"
+)
+
+var patchableFields = map[string]bool{"delivery": true, "payment": true, "items": true}
+
+// applyMergePatch применяет JSON Merge Patch (RFC 7396) к заказу. Менять можно только
+// delivery, payment и items; order_uid и остальные поля заказа правятся через PUT.
+func applyMergePatch(order model.Order, patch []byte) (model.Order, error) {
+	var p map[string]any
+	if err := json.Unmarshal(patch, &p); err != nil || p == nil {
+		return model.Order{}, invalid("body", "merge patch must be a JSON object")
+	}
+	for key := range p {
+		if !patchableFields[key] {
+			return model.Order{}, invalid(key, "field cannot be patched")
+		}
+	}
+	data, err := json.Marshal(order)
+	if err != nil {
+		return model.Order{}, err
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return model.Order{}, err
+	}
+	merged, err := json.Marshal(mergePatch(doc, p))
+	if err != nil {
+		return model.Order{}, err
+	}
+	var out model.Order
+	if err := json.Unmarshal(merged, &out); err != nil {
+		return model.Order{}, invalid("body", err.Error())
+	}
+	return out, nil
+}
+
+func mergePatch(target any, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = map[string]any{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergePatch(t[k], v)
+	}
+	return t
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"awesomeProject/internal/model"
+	"awesomeProject/internal/money"
+	"context"
+	"fmt"
+	"time"
+)
+
+type ReportStore interface {
+	RevenueByCurrency(ctx context.Context, from, to time.Time) ([]model.CurrencyRevenue, error)
+}
+
+// Reports строит отчёты по заказам в нескольких валютах. Суммы в разных валютах между собой не
+// складываются: итог считается в валюте отчёта по локальной таблице курсов.
+type Reports struct {
+	store ReportStore
+	rates *money.Rates
+}
+
+// NewReports создаёт отчёты; без таблицы курсов (rates == nil) в итог попадает только выручка
+// в валюте отчёта.
+func NewReports(store ReportStore, rates *money.Rates) *Reports {
+	return &Reports{store: store, rates: rates}
+}
+
+// RevenueLine — выручка в одной валюте и она же в валюте отчёта. Converted пуст, если курса нет.
+type RevenueLine struct {
+	Currency  string       `json:"currency"`
+	Orders    int          `json:"orders"`
+	Amount    money.Money  `json:"amount"`
+	Converted *money.Money `json:"converted,omitempty"`
+}
+
+// RevenueReport — выручка за [From, To) по валютам и итог в валюте Currency. Unconverted —
+// валюты, которые не вошли в итог: для них нет курса или код не из ISO 4217.
+type RevenueReport struct {
+	From        time.Time     `json:"from"`
+	To          time.Time     `json:"to"`
+	Currency    string        `json:"currency"`
+	Total       money.Money   `json:"total"`
+	Orders      int           `json:"orders"`
+	ByCurrency  []RevenueLine `json:"by_currency"`
+	Unconverted []string      `json:"unconverted,omitempty"`
+}
+
+// Revenue считает выручку за [from, to) в валюте currency; пустая валюта — базовая валюта
+// таблицы курсов.
+func (rep *Reports) Revenue(ctx context.Context, from, to time.Time, currency string) (RevenueReport, error) {
+	if currency == "" && rep.rates != nil {
+		currency = rep.rates.Base()
+	}
+	if !money.ValidCurrency(currency) {
+		return RevenueReport{}, invalid("currency", fmt.Sprintf("%q is not an ISO 4217 currency code", currency))
+	}
+	if !from.Before(to) {
+		return RevenueReport{}, invalid("from", "must be before to")
+	}
+	rows, err := rep.store.RevenueByCurrency(ctx, from, to)
+	if err != nil {
+		return RevenueReport{}, classify(err)
+	}
+	report := RevenueReport{
+		From:       from.UTC(),
+		To:         to.UTC(),
+		Currency:   currency,
+		Total:      money.Money{Currency: currency},
+		ByCurrency: make([]RevenueLine, 0, len(rows)),
+	}
+	for _, row := range rows {
+		line := RevenueLine{
+			Currency: row.Currency,
+			Orders:   row.Orders,
+			Amount:   money.Money{Amount: row.Amount, Currency: row.Currency},
+		}
+		report.ByCurrency = append(report.ByCurrency, line)
+		converted, ok := rep.convert(line.Amount, currency)
+		if !ok {
+			report.Unconverted = append(report.Unconverted, row.Currency)
+			continue
+		}
+		if report.Total, err = report.Total.Add(converted); err != nil {
+			return RevenueReport{}, err
+		}
+		report.Orders += row.Orders
+		report.ByCurrency[len(report.ByCurrency)-1].Converted = &converted
+	}
+	return report, nil
+}
+
+func (rep *Reports) convert(m money.Money, to string) (money.Money, bool) {
+	if m.Currency == to {
+		return m, true
+	}
+	if rep.rates == nil {
+		return money.Money{}, false
+	}
+	out, err := rep.rates.Convert(m, to)
+	return out, err == nil
+}
@@ -2,21 +2,31 @@ package service
 
 import (
 	"awesomeProject/internal/model"
+	"awesomeProject/internal/repository"
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 )
 
 type Repository interface {
-	InsertOrder(ctx context.Context, order model.Order) error
+	InsertOrder(ctx context.Context, order model.Order) (bool, error)
 	GetOrderById(ctx context.Context, id string) (model.Order, error)
 	LoadAll(ctx context.Context) ([]model.Order, error)
+	ReplaceOrder(ctx context.Context, order model.Order) (bool, error)
+	UpdateOrder(ctx context.Context, id string, update func(model.Order) (model.Order, error)) (model.Order, error)
+	ReplaceOrders(ctx context.Context, orders []model.Order) ([]bool, error)
+	DeleteOrder(ctx context.Context, id string) error
+	ChangeStatus(ctx context.Context, change model.StatusChange) (model.StatusChange, error)
+	StatusHistory(ctx context.Context, id string) (model.StatusHistory, error)
+	OrderHistory(ctx context.Context, id string) ([]model.AuditRecord, error)
 }
 
 type Cache interface {
 	Get(id string) (model.Order, bool)
 	Set(o model.Order)
 	BulkSet(list []model.Order)
+	Delete(id string)
 }
 
 type Service struct {
@@ -32,49 +42,210 @@ func NewService(repo Repository, cache Cache, logger *slog.Logger) *Service {
 func (s *Service) Warmup(ctx context.Context) error {
 	orders, err := s.repo.LoadAll(ctx)
 	if err != nil {
-		s.logger.Error("warmup: load all failed", slog.Any("err", err))
+		s.logger.ErrorContext(ctx, "warmup: load all failed", slog.Any("err", err))
 		return err
 	}
 	if len(orders) > 0 {
 		s.cache.BulkSet(orders)
 	}
-	s.logger.Info("cache warmup completed", slog.Int("orders", len(orders)))
+	s.logger.InfoContext(ctx, "cache warmup completed", slog.Int("orders", len(orders)))
 	return nil
 }
 
 func (s *Service) GetOrderByID(ctx context.Context, id string) (model.Order, error) {
 	if id == "" {
-		return model.Order{}, errors.New("empty id")
+		return model.Order{}, invalid("order_uid", "is required")
 	}
 	if order, ok := s.cache.Get(id); ok {
-		s.logger.Info("get order", slog.String("id", id), slog.Bool("cache_hit", true))
+		s.logger.InfoContext(ctx, "get order", slog.String("id", id), slog.Bool("cache_hit", true))
 		return order, nil
 	}
 	order, err := s.repo.GetOrderById(ctx, id)
 	if err != nil {
-		s.logger.Error("get order by id failed", slog.Any("err", err))
-		return model.Order{}, err
+		s.logger.ErrorContext(ctx, "get order by id failed", slog.Any("err", err))
+		return model.Order{}, classify(err)
 	}
 	s.cache.Set(order)
-	s.logger.Info("get order", slog.String("id", id), slog.Bool("cache_hit", false))
+	s.logger.InfoContext(ctx, "get order", slog.String("id", id), slog.Bool("cache_hit", false))
 	return order, nil
 }
-func (s *Service) UpsertOrder(ctx context.Context, order model.Order) error {
-	if order.Order_uid == "" {
-		return errors.New("order_uid is empty")
+
+// UpsertOrder сохраняет новый заказ и сообщает, был ли он создан. Заказ с уже существующим
+// order_uid не меняется — ни в БД, ни в кэше.
+func (s *Service) UpsertOrder(ctx context.Context, order model.Order) (bool, error) {
+	if err := Validate(order); err != nil {
+		return false, err
 	}
-	if err := s.repo.InsertOrder(ctx, order); err != nil {
-		return err
+	created, err := s.repo.InsertOrder(ctx, order)
+	if err != nil {
+		return false, classify(err)
+	}
+	if created {
+		s.cache.Set(order)
+	}
+	return created, nil
+}
+
+const (
+	BulkCreated = "created"
+	BulkUpdated = "updated"
+	BulkInvalid = "invalid"
+	BulkFailed  = "failed"
+)
+
+type BulkResult struct {
+	Index    int    `json:"index"`
+	OrderUID string `json:"order_uid,omitempty"`
+	Status   string `json:"status"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// UpsertMany валидирует заказы и пишет корректные пачками по batchSize. Результат возвращается
+// по каждому заказу в исходном порядке; ошибка пачки помечает как failed только её заказы.
+func (s *Service) UpsertMany(ctx context.Context, list []model.Order, batchSize int) []BulkResult {
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	results := make([]BulkResult, len(list))
+	batch := make([]int, 0, batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		orders := make([]model.Order, len(batch))
+		for i, idx := range batch {
+			orders[i] = list[idx]
+		}
+		created, err := s.repo.ReplaceOrders(ctx, orders)
+		for i, idx := range batch {
+			switch {
+			case err != nil:
+				results[idx].Status, results[idx].Reason = BulkFailed, classify(err).Error()
+			case created[i]:
+				results[idx].Status = BulkCreated
+			default:
+				results[idx].Status = BulkUpdated
+			}
+		}
+		if err != nil {
+			s.logger.ErrorContext(ctx, "bulk upsert batch failed", slog.Int("orders", len(batch)), slog.Any("err", err))
+		} else {
+			s.cache.BulkSet(orders)
+		}
+		batch = batch[:0]
+	}
+	for i, order := range list {
+		results[i] = BulkResult{Index: i, OrderUID: order.OrderUID}
+		if err := Validate(order); err != nil {
+			results[i].Status, results[i].Reason = BulkInvalid, err.Error()
+			continue
+		}
+		batch = append(batch, i)
+		if len(batch) == batchSize {
+			flush()
+		}
+	}
+	flush()
+	return results
+}
+
+func (s *Service) ReplaceOrder(ctx context.Context, order model.Order) (bool, error) {
+	if err := Validate(order); err != nil {
+		return false, err
+	}
+	created, err := s.repo.ReplaceOrder(ctx, order)
+	if err != nil {
+		return false, classify(err)
 	}
 	s.cache.Set(order)
-	return nil
+	return created, nil
 }
 
-func (s *Service) UpsertMany(ctx context.Context, list []model.Order) error {
-	for _, order := range list {
-		if err := s.UpsertOrder(ctx, order); err != nil {
-			return err
+// PatchOrder применяет патч к заказу из БД под блокировкой строки, а не к копии из кэша:
+// два параллельных патча разных полей не затирают друг друга.
+func (s *Service) PatchOrder(ctx context.Context, id string, patch []byte) (model.Order, error) {
+	if id == "" {
+		return model.Order{}, invalid("order_uid", "is required")
+	}
+	order, err := s.repo.UpdateOrder(ctx, id, func(current model.Order) (model.Order, error) {
+		order, err := applyMergePatch(current, patch)
+		if err != nil {
+			return model.Order{}, err
 		}
+		return order, Validate(order)
+	})
+	if err != nil {
+		return model.Order{}, classify(err)
 	}
+	s.cache.Set(order)
+	return order, nil
+}
+
+func (s *Service) DeleteOrder(ctx context.Context, id string) error {
+	if id == "" {
+		return invalid("order_uid", "is required")
+	}
+	if err := s.repo.DeleteOrder(ctx, id); err != nil {
+		return classify(err)
+	}
+	s.cache.Delete(id)
+	s.logger.InfoContext(ctx, "order deleted", slog.String("id", id))
 	return nil
 }
+
+// ChangeStatus переводит заказ в новый статус. Недопустимый переход возвращается как
+// model.ErrInvalidTransition. Заказ в кэше статуса не содержит, поэтому кэш не трогаем.
+func (s *Service) ChangeStatus(ctx context.Context, change model.StatusChange) (model.StatusChange, error) {
+	if change.OrderUID == "" {
+		return change, invalid("order_uid", "is required")
+	}
+	if !change.To.Valid() {
+		return change, invalid("status", fmt.Sprintf("unknown status %q", change.To))
+	}
+	change, err := s.repo.ChangeStatus(ctx, change)
+	if err != nil {
+		return change, classify(err)
+	}
+	s.logger.InfoContext(ctx, "order status changed",
+		slog.String("id", change.OrderUID),
+		slog.String("from", string(change.From)),
+		slog.String("to", string(change.To)),
+		slog.String("source", change.Source))
+	return change, nil
+}
+
+func (s *Service) StatusHistory(ctx context.Context, id string) (model.StatusHistory, error) {
+	if id == "" {
+		return model.StatusHistory{}, invalid("order_uid", "is required")
+	}
+	h, err := s.repo.StatusHistory(ctx, id)
+	if err != nil {
+		return model.StatusHistory{}, classify(err)
+	}
+	return h, nil
+}
+
+// OrderHistory возвращает журнал изменений заказа; он доступен и для удалённого заказа.
+func (s *Service) OrderHistory(ctx context.Context, id string) ([]model.AuditRecord, error) {
+	if id == "" {
+		return nil, invalid("order_uid", "is required")
+	}
+	list, err := s.repo.OrderHistory(ctx, id)
+	if err != nil {
+		return nil, classify(err)
+	}
+	return list, nil
+}
+
+// classify помечает ошибки хранилища как ErrDependency, не трогая "не найдено", недопустимый
+// переход статуса, ошибки валидации и отмену контекста.
+func classify(err error) error {
+	if errors.Is(err, repository.ErrNotFound) || errors.Is(err, repository.ErrAlreadyApplied) ||
+		errors.Is(err, ErrInvalidOrder) ||
+		errors.Is(err, model.ErrInvalidTransition) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, context.Canceled) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrDependency, err)
+}
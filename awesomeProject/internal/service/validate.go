@@ -0,0 +1,35 @@
+package service
+
+import (
+	"awesomeProject/internal/model"
+	"awesomeProject/internal/money"
+	"fmt"
+)
+
+func Validate(order model.Order) error {
+	if order.OrderUID == "" {
+		return invalid("order_uid", "is required")
+	}
+	seen := make(map[int]bool, len(order.Items))
+	for i, item := range order.Items {
+		if seen[item.ChrtID] {
+			return invalid(fmt.Sprintf("items[%d].chrt_id", i), "duplicates another item")
+		}
+		seen[item.ChrtID] = true
+		if item.Price < 0 || item.TotalPrice < 0 {
+			return invalid(fmt.Sprintf("items[%d]", i), "prices must not be negative")
+		}
+	}
+	p := order.Payment
+	if p.Amount < 0 || p.DeliveryCost < 0 || p.GoodsTotal < 0 || p.CustomFee < 0 {
+		return invalid("payment", "amounts must not be negative")
+	}
+	// Пустая валюта допустима для старых заказов; указанная должна быть кодом ISO 4217.
+	if p.Currency != "" && !money.ValidCurrency(p.Currency) {
+		return invalid("payment.currency", fmt.Sprintf("%q is not an ISO 4217 currency code", p.Currency))
+	}
+	if _, err := order.ItemsTotal(); err != nil {
+		return invalid("items", "total_price sum overflows")
+	}
+	return nil
+}
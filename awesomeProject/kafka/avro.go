@@ -0,0 +1,415 @@
+package kafka
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Разбор бинарной кодировки Avro по схеме писателя. Поддерживается подмножество
+// спецификации, нужное для схем заказа: примитивы, record, array, map, enum, union и
+// logicalType timestamp-millis/timestamp-micros.
+
+type avroSchema struct {
+	typ     string
+	logical string
+	name    string
+	fields  []avroField
+	items   *avroSchema // array и map
+	union   []*avroSchema
+	symbols []string
+}
+
+type avroField struct {
+	name   string
+	schema *avroSchema
+}
+
+func parseAvroSchema(data []byte) (*avroSchema, error) {
+	return parseAvro(json.RawMessage(data), make(map[string]*avroSchema))
+}
+
+func parseAvro(raw json.RawMessage, named map[string]*avroSchema) (*avroSchema, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return nil, errors.New("avro: empty schema")
+	}
+	switch raw[0] {
+	case '"':
+		var name string
+		if err := json.Unmarshal(raw, &name); err != nil {
+			return nil, err
+		}
+		switch name {
+		case "null", "boolean", "int", "long", "float", "double", "bytes", "string":
+			return &avroSchema{typ: name}, nil
+		}
+		if s, ok := named[name]; ok {
+			return s, nil
+		}
+		return nil, fmt.Errorf("avro: unknown type %q", name)
+	case '[':
+		var branches []json.RawMessage
+		if err := json.Unmarshal(raw, &branches); err != nil {
+			return nil, err
+		}
+		s := &avroSchema{typ: "union"}
+		for _, b := range branches {
+			bs, err := parseAvro(b, named)
+			if err != nil {
+				return nil, err
+			}
+			s.union = append(s.union, bs)
+		}
+		return s, nil
+	}
+
+	var obj struct {
+		Type        json.RawMessage `json:"type"`
+		LogicalType string          `json:"logicalType"`
+		Name        string          `json:"name"`
+		Fields      []struct {
+			Name string          `json:"name"`
+			Type json.RawMessage `json:"type"`
+		} `json:"fields"`
+		Items   json.RawMessage `json:"items"`
+		Values  json.RawMessage `json:"values"`
+		Symbols []string        `json:"symbols"`
+	}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, err
+	}
+	var typ string
+	if err := json.Unmarshal(obj.Type, &typ); err != nil {
+		// {"type": {...}} — вложенное описание типа.
+		return parseAvro(obj.Type, named)
+	}
+	s := &avroSchema{typ: typ, logical: obj.LogicalType, name: obj.Name}
+	switch typ {
+	case "record":
+		named[obj.Name] = s
+		for _, f := range obj.Fields {
+			fs, err := parseAvro(f.Type, named)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", f.Name, err)
+			}
+			s.fields = append(s.fields, avroField{name: f.Name, schema: fs})
+		}
+	case "array", "map":
+		items := obj.Items
+		if typ == "map" {
+			items = obj.Values
+		}
+		is, err := parseAvro(items, named)
+		if err != nil {
+			return nil, err
+		}
+		s.items = is
+	case "enum":
+		named[obj.Name] = s
+		s.symbols = obj.Symbols
+	case "null", "boolean", "int", "long", "float", "double", "bytes", "string":
+	default:
+		return nil, fmt.Errorf("avro: unsupported type %q", typ)
+	}
+	return s, nil
+}
+
+var errAvroTruncated = errors.New("avro: truncated data")
+
+type avroReader struct {
+	data []byte
+}
+
+func (r *avroReader) long() (int64, error) {
+	v, n := binary.Varint(r.data)
+	if n <= 0 {
+		return 0, errAvroTruncated
+	}
+	r.data = r.data[n:]
+	return v, nil
+}
+
+func (r *avroReader) bytes() ([]byte, error) {
+	l, err := r.long()
+	if err != nil {
+		return nil, err
+	}
+	if l < 0 || int64(len(r.data)) < l {
+		return nil, errAvroTruncated
+	}
+	b := r.data[:l]
+	r.data = r.data[l:]
+	return b, nil
+}
+
+func (r *avroReader) fixed(n int) ([]byte, error) {
+	if len(r.data) < n {
+		return nil, errAvroTruncated
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b, nil
+}
+
+func (r *avroReader) read(s *avroSchema) (any, error) {
+	switch s.typ {
+	case "null":
+		return nil, nil
+	case "boolean":
+		b, err := r.fixed(1)
+		if err != nil {
+			return nil, err
+		}
+		return b[0] != 0, nil
+	case "int", "long":
+		v, err := r.long()
+		if err != nil {
+			return nil, err
+		}
+		switch s.logical {
+		case "timestamp-millis":
+			return time.UnixMilli(v).UTC().Format(time.RFC3339Nano), nil
+		case "timestamp-micros":
+			return time.UnixMicro(v).UTC().Format(time.RFC3339Nano), nil
+		}
+		return v, nil
+	case "float":
+		b, err := r.fixed(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b))), nil
+	case "double":
+		b, err := r.fixed(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+	case "bytes", "string":
+		b, err := r.bytes()
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	case "enum":
+		i, err := r.long()
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || int(i) >= len(s.symbols) {
+			return nil, fmt.Errorf("avro: enum %s index %d out of range", s.name, i)
+		}
+		return s.symbols[i], nil
+	case "union":
+		i, err := r.long()
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || int(i) >= len(s.union) {
+			return nil, fmt.Errorf("avro: union index %d out of range", i)
+		}
+		return r.read(s.union[i])
+	case "record":
+		out := make(map[string]any, len(s.fields))
+		for _, f := range s.fields {
+			v, err := r.read(f.schema)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", f.name, err)
+			}
+			out[f.name] = v
+		}
+		return out, nil
+	case "array", "map":
+		var (
+			list []any
+			m    map[string]any
+		)
+		if s.typ == "map" {
+			m = make(map[string]any)
+		} else {
+			list = make([]any, 0)
+		}
+		for {
+			count, err := r.long()
+			if err != nil {
+				return nil, err
+			}
+			if count == 0 {
+				break
+			}
+			if count < 0 {
+				// Отрицательный счётчик: за ним идёт размер блока в байтах.
+				count = -count
+				if _, err := r.long(); err != nil {
+					return nil, err
+				}
+			}
+			// Элементы схем заказа занимают хотя бы байт, так что счётчик больше остатка данных —
+			// битое или подделанное сообщение. Без проверки блок элементов null нулевой длины
+			// крутил бы цикл сколь угодно долго.
+			if count < 0 || count > int64(len(r.data)) {
+				return nil, fmt.Errorf("avro: block of %d items exceeds %d remaining bytes", count, len(r.data))
+			}
+			for ; count > 0; count-- {
+				var key []byte
+				if m != nil {
+					if key, err = r.bytes(); err != nil {
+						return nil, err
+					}
+				}
+				v, err := r.read(s.items)
+				if err != nil {
+					return nil, err
+				}
+				if m != nil {
+					m[string(key)] = v
+				} else {
+					list = append(list, v)
+				}
+			}
+		}
+		if m != nil {
+			return m, nil
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("avro: unsupported type %q", s.typ)
+}
+
+func appendAvro(buf []byte, s *avroSchema, v any) ([]byte, error) {
+	switch s.typ {
+	case "null":
+		return buf, nil
+	case "boolean":
+		b, _ := v.(bool)
+		if b {
+			return append(buf, 1), nil
+		}
+		return append(buf, 0), nil
+	case "int", "long":
+		if str, ok := v.(string); ok && strings.HasPrefix(s.logical, "timestamp-") {
+			t, err := time.Parse(time.RFC3339Nano, str)
+			if err != nil {
+				return nil, err
+			}
+			if s.logical == "timestamp-micros" {
+				return binary.AppendVarint(buf, t.UnixMicro()), nil
+			}
+			return binary.AppendVarint(buf, t.UnixMilli()), nil
+		}
+		n, err := toInt(v)
+		if err != nil {
+			return nil, err
+		}
+		return binary.AppendVarint(buf, n), nil
+	case "float", "double":
+		var f float64
+		switch x := v.(type) {
+		case float64:
+			f = x
+		case int64:
+			f = float64(x)
+		}
+		if s.typ == "float" {
+			return binary.LittleEndian.AppendUint32(buf, math.Float32bits(float32(f))), nil
+		}
+		return binary.LittleEndian.AppendUint64(buf, math.Float64bits(f)), nil
+	case "bytes", "string":
+		str, _ := v.(string)
+		buf = binary.AppendVarint(buf, int64(len(str)))
+		return append(buf, str...), nil
+	case "enum":
+		str, _ := v.(string)
+		for i, sym := range s.symbols {
+			if sym == str {
+				return binary.AppendVarint(buf, int64(i)), nil
+			}
+		}
+		return nil, fmt.Errorf("avro: %q is not a symbol of enum %s", str, s.name)
+	case "union":
+		for i, b := range s.union {
+			if avroMatches(b, v) {
+				return appendAvro(binary.AppendVarint(buf, int64(i)), b, v)
+			}
+		}
+		return nil, fmt.Errorf("avro: no union branch for %T", v)
+	case "record":
+		m, _ := v.(map[string]any)
+		for _, f := range s.fields {
+			var err error
+			if buf, err = appendAvro(buf, f.schema, m[f.name]); err != nil {
+				return nil, fmt.Errorf("%s: %w", f.name, err)
+			}
+		}
+		return buf, nil
+	case "array":
+		list, _ := v.([]any)
+		if len(list) > 0 {
+			buf = binary.AppendVarint(buf, int64(len(list)))
+			for _, e := range list {
+				var err error
+				if buf, err = appendAvro(buf, s.items, e); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return binary.AppendVarint(buf, 0), nil
+	case "map":
+		m, _ := v.(map[string]any)
+		if len(m) > 0 {
+			buf = binary.AppendVarint(buf, int64(len(m)))
+			for k, e := range m {
+				buf = binary.AppendVarint(buf, int64(len(k)))
+				buf = append(buf, k...)
+				var err error
+				if buf, err = appendAvro(buf, s.items, e); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return binary.AppendVarint(buf, 0), nil
+	}
+	return nil, fmt.Errorf("avro: unsupported type %q", s.typ)
+}
+
+// avroMatches сообщает, подходит ли значение документа под ветку union: ветка выбирается по
+// типу значения, а не первая ненулевая.
+func avroMatches(s *avroSchema, v any) bool {
+	switch x := v.(type) {
+	case nil:
+		return s.typ == "null"
+	case bool:
+		return s.typ == "boolean"
+	case int64, int32, json.Number:
+		switch s.typ {
+		case "int", "long", "float", "double":
+			return true
+		}
+	case float64:
+		return s.typ == "float" || s.typ == "double"
+	case string:
+		switch s.typ {
+		case "string", "bytes":
+			return true
+		case "int", "long":
+			return strings.HasPrefix(s.logical, "timestamp-")
+		case "enum":
+			for _, sym := range s.symbols {
+				if sym == x {
+					return true
+				}
+			}
+		}
+	case []any:
+		return s.typ == "array"
+	case map[string]any:
+		return s.typ == "record" || s.typ == "map"
+	}
+	return false
+}
@@ -0,0 +1,252 @@
+package kafka
+
+import (
+	"awesomeProject/internal/model"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Заголовки, которыми производитель описывает формат тела сообщения. Без content-type
+// используется формат по умолчанию потребителя, без schema-version — текущая версия.
+// Для Avro версия берётся из реестра схем по ID схемы в самом сообщении.
+const (
+	HeaderContentType   = "content-type"
+	HeaderSchemaVersion = "schema-version"
+	// HeaderEventType отличает события от заказов: с event-type OrderStatusChanged тело —
+	// JSON смены статуса (model.StatusChange), без заголовка — заказ.
+	HeaderEventType = "event-type"
+)
+
+const (
+	FormatJSON     = "json"
+	FormatProtobuf = "protobuf"
+	FormatAvro     = "avro"
+)
+
+// SchemaVersion — текущая версия схемы заказа, в которую поднимаются все более старые.
+//
+// Версия 1 отличалась от текущей двумя полями: date_created передавался числом секунд
+// Unix, а items[].total_price отсутствовал и вычисляется из price и sale.
+const SchemaVersion = 2
+
+var (
+	// ErrUnsupportedVersion — схема сообщения новее, чем понимает этот потребитель, или
+	// неизвестна реестру. Такое сообщение не отбрасывается: его прочитает обновлённая версия.
+	ErrUnsupportedVersion = errors.New("unsupported schema version")
+	ErrUnsupportedFormat  = errors.New("unsupported message format")
+)
+
+// Decoder превращает тело сообщения в заказ текущей версии схемы.
+type Decoder interface {
+	Decode(m kafka.Message) (model.Order, error)
+}
+
+// Decoders выбирает формат по заголовку content-type, проверяет версию схемы и поднимает
+// старые версии до текущей.
+type Decoders struct {
+	registry      *Registry
+	defaultFormat string
+}
+
+func NewDecoders(registry *Registry, defaultFormat string) (*Decoders, error) {
+	if registry == nil {
+		registry = NewRegistry()
+	}
+	format, err := parseFormat(defaultFormat)
+	if err != nil {
+		return nil, err
+	}
+	return &Decoders{registry: registry, defaultFormat: format}, nil
+}
+
+// parseFormat понимает короткие имена форматов и MIME-типы.
+func parseFormat(s string) (string, error) {
+	mt, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(s)), ";")
+	switch strings.TrimSpace(mt) {
+	case "", FormatJSON, "application/json":
+		return FormatJSON, nil
+	case FormatProtobuf, "proto", "application/protobuf", "application/x-protobuf":
+		return FormatProtobuf, nil
+	case FormatAvro, "application/avro", "avro/binary", "application/vnd.kafka.avro.v2+json":
+		return FormatAvro, nil
+	}
+	return "", fmt.Errorf("%w %q", ErrUnsupportedFormat, s)
+}
+
+func header(m kafka.Message, key string) string {
+	for _, h := range m.Headers {
+		if strings.EqualFold(h.Key, key) {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (d *Decoders) Decode(m kafka.Message) (model.Order, error) {
+	format := d.defaultFormat
+	if ct := header(m, HeaderContentType); ct != "" {
+		f, err := parseFormat(ct)
+		if err != nil {
+			return model.Order{}, err
+		}
+		format = f
+	}
+	version := SchemaVersion
+	if v := header(m, HeaderSchemaVersion); v != "" && format != FormatAvro {
+		n, err := strconv.Atoi(strings.TrimPrefix(v, "v"))
+		if err != nil || n < 1 {
+			return model.Order{}, fmt.Errorf("%w: bad %s header %q", ErrUnsupportedVersion, HeaderSchemaVersion, v)
+		}
+		version = n
+	}
+
+	var (
+		doc map[string]any
+		err error
+	)
+	switch format {
+	case FormatJSON:
+		if version == SchemaVersion {
+			var order model.Order
+			return order, json.Unmarshal(m.Value, &order)
+		}
+		if err := checkVersion(version); err != nil {
+			return model.Order{}, err
+		}
+		doc, err = decodeJSONDoc(m.Value)
+	case FormatProtobuf:
+		if err := checkVersion(version); err != nil {
+			return model.Order{}, err
+		}
+		doc, err = decodeProtobuf(m.Value, orderDescriptor(version))
+	case FormatAvro:
+		doc, version, err = d.registry.decodeAvro(m.Value)
+	}
+	if err != nil {
+		return model.Order{}, err
+	}
+	if err := checkVersion(version); err != nil {
+		return model.Order{}, err
+	}
+	return upcast(doc, version)
+}
+
+func checkVersion(v int) error {
+	if v < 1 || v > SchemaVersion {
+		return fmt.Errorf("%w: %d (supported 1..%d)", ErrUnsupportedVersion, v, SchemaVersion)
+	}
+	return nil
+}
+
+func decodeJSONDoc(data []byte) (map[string]any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc map[string]any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, errors.New("payload is not a JSON object")
+	}
+	return doc, nil
+}
+
+// upcasters[v] переводит документ версии v в версию v+1.
+var upcasters = map[int]func(doc map[string]any) error{
+	1: upcastV1,
+}
+
+// upcast поднимает документ версии version до текущей и собирает из него заказ.
+func upcast(doc map[string]any, version int) (model.Order, error) {
+	for v := version; v < SchemaVersion; v++ {
+		if err := upcasters[v](doc); err != nil {
+			return model.Order{}, fmt.Errorf("upcast v%d: %w", v, err)
+		}
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return model.Order{}, err
+	}
+	var order model.Order
+	return order, json.Unmarshal(data, &order)
+}
+
+func upcastV1(doc map[string]any) error {
+	if v, ok := doc["date_created"]; ok {
+		sec, err := toInt(v)
+		if err != nil {
+			return fmt.Errorf("date_created: %w", err)
+		}
+		doc["date_created"] = time.Unix(sec, 0).UTC().Format(time.RFC3339)
+	}
+	items, _ := doc["items"].([]any)
+	for _, it := range items {
+		item, ok := it.(map[string]any)
+		if !ok {
+			continue
+		}
+		if _, ok := item["total_price"]; ok {
+			continue
+		}
+		price, _ := toInt(item["price"])
+		sale, _ := toInt(item["sale"])
+		item["total_price"] = price * (100 - sale) / 100
+	}
+	return nil
+}
+
+func toInt(v any) (int64, error) {
+	switch n := v.(type) {
+	case int64:
+		return n, nil
+	case int32:
+		return int64(n), nil
+	case float64:
+		return int64(n), nil
+	case json.Number:
+		return n.Int64()
+	case nil:
+		return 0, nil
+	}
+	return 0, fmt.Errorf("want a number, got %T", v)
+}
+
+// orderDocument переводит заказ в документ текущей версии схемы для кодировщиков.
+func orderDocument(order model.Order) (map[string]any, error) {
+	data, err := json.Marshal(order)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := decodeJSONDoc(data)
+	if err != nil {
+		return nil, err
+	}
+	return normalizeNumbers(doc).(map[string]any), nil
+}
+
+func normalizeNumbers(v any) any {
+	switch x := v.(type) {
+	case map[string]any:
+		for k, e := range x {
+			x[k] = normalizeNumbers(e)
+		}
+	case []any:
+		for i, e := range x {
+			x[i] = normalizeNumbers(e)
+		}
+	case json.Number:
+		if n, err := x.Int64(); err == nil {
+			return n
+		}
+		f, _ := x.Float64()
+		return f
+	}
+	return v
+}
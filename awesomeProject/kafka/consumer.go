@@ -2,25 +2,47 @@ package kafka
 
 import (
 	"awesomeProject/internal/model"
+	"awesomeProject/internal/repository"
 	"awesomeProject/internal/service"
 	"context"
-	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/segmentio/kafka-go"
 )
 
+type MessageReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
 type Consumer struct {
-	reader *kafka.Reader
+	reader MessageReader
 	logger *slog.Logger
 	svc    *service.Service
+
+	workers  int
+	dispatch string
+	offsets  *offsetTracker
+	group    string
+	decoder  Decoder
+	dlq      Publisher
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
 }
 
-func NewConsumer(svc *service.Service, logger *slog.Logger) *Consumer {
+// NewConsumer настраивает потребителя по переменным окружения KAFKA_*. С store offset'ы
+// хранятся в БД вместе с заказами (см. WithDBOffsets), без него — только в Kafka.
+func NewConsumer(svc *service.Service, store OffsetStore, logger *slog.Logger) (*Consumer, error) {
 	brokers := splitAndTrim(getEnv("KAFKA_BROKERS", "kafka:9092"))
 	topic := getEnv("KAFKA_TOPIC", "orders")
 	groupID := getEnv("KAFKA_GROUP_ID", "order-consumer-1")
@@ -48,75 +70,214 @@ func NewConsumer(svc *service.Service, logger *slog.Logger) *Consumer {
 	default:
 		cfg.StartOffset = kafka.LastOffset
 	}
-	reader := kafka.NewReader(cfg)
+	registry, err := LoadRegistry(getEnv("KAFKA_SCHEMA_REGISTRY_FILE", ""))
+	if err != nil {
+		return nil, err
+	}
+	format := getEnv("KAFKA_DEFAULT_FORMAT", FormatJSON)
+	decoder, err := NewDecoders(registry, format)
+	if err != nil {
+		return nil, err
+	}
+	var reader MessageReader
+	if store != nil {
+		gr, err := newGroupReader(cfg, store, logger)
+		if err != nil {
+			return nil, err
+		}
+		reader = gr
+	} else {
+		reader = kafka.NewReader(cfg)
+	}
+	workers := getEnvInt("KAFKA_WORKERS", 4)
+	dispatch := getEnv("KAFKA_DISPATCH", DispatchByKey)
 	logger.Info("kafka consumer configured",
 		slog.String("brokers", strings.Join(brokers, ",")),
 		slog.String("topic", topic),
 		slog.String("group_id", groupID),
 		slog.String("start_offset", startOffset),
+		slog.Int("workers", workers),
+		slog.String("dispatch", dispatch),
+		slog.Bool("offsets_in_db", store != nil),
+		slog.String("default_format", format),
 	)
+	c := NewConsumerWithReader(reader, svc, logger).WithConcurrency(workers, dispatch).WithDecoder(decoder)
+	if store != nil {
+		c.WithDBOffsets(groupID)
+	}
+	return c, nil
+}
+
+func NewConsumerWithReader(reader MessageReader, svc *service.Service, logger *slog.Logger) *Consumer {
 	return &Consumer{
 		reader: reader,
 		logger: logger,
 		svc:    svc,
+
+		workers:  1,
+		dispatch: DispatchByPartition,
+		offsets:  newOffsetTracker(),
+		decoder:  &Decoders{registry: NewRegistry(), defaultFormat: FormatJSON},
+
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+// WithConcurrency обрабатывает сообщения в workers горутинах, распределяя их по партиции
+// или по ключу сообщения (dispatch). Без ключа сообщение распределяется по партиции.
+func (c *Consumer) WithConcurrency(workers int, dispatch string) *Consumer {
+	c.workers = max(1, workers)
+	if dispatch == DispatchByKey {
+		c.dispatch = DispatchByKey
+	} else {
+		c.dispatch = DispatchByPartition
 	}
+	return c
+}
+
+// WithDecoder задаёт разбор тела сообщений; по умолчанию JSON со встроенным реестром схем.
+func (c *Consumer) WithDecoder(d Decoder) *Consumer {
+	c.decoder = d
+	return c
 }
 
+// WithDBOffsets отмечает каждое сообщение в БД в той же транзакции, что и заказ из него, от
+// имени группы group. Сообщение, уже применённое раньше, пропускается, а не перезаписывает
+// заказ повторно; это верно и при DispatchByKey, когда сообщения партиции пишутся не по порядку.
+func (c *Consumer) WithDBOffsets(group string) *Consumer {
+	c.group = group
+	return c
+}
+
+// Run читает сообщения и раздаёт их воркерам, пока не отменён ctx или не вызван Shutdown.
+// Отмена ctx прерывает и обработку; Shutdown только прекращает чтение новых, давая уже
+// полученным записаться и закоммитить offset.
 func (c *Consumer) Run(ctx context.Context) error {
+	defer close(c.done)
 	defer func() {
 		_ = c.reader.Close()
 	}()
+	fetchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-c.stop:
+			cancel()
+		case <-fetchCtx.Done():
+		}
+	}()
+
+	var wg sync.WaitGroup
+	queues := c.startWorkers(ctx, &wg)
+	defer func() {
+		for _, q := range queues {
+			close(q)
+		}
+		wg.Wait()
+	}()
 
 	for {
-		m, err := c.reader.FetchMessage(ctx)
+		m, err := c.reader.FetchMessage(fetchCtx)
 		if err != nil {
-			if ctx.Err() != nil {
-				c.logger.Info("kafka consumer stopped", slog.Any("reason", ctx.Err()))
+			if fetchCtx.Err() != nil {
+				c.logger.Info("kafka consumer stopped", slog.Any("reason", fetchCtx.Err()))
 				return nil
 			}
 			c.logger.Error("kafka read message failed", slog.Any("err", err))
 			continue
 		}
-
-		var order model.Order
-		if err := json.Unmarshal(m.Value, &order); err != nil {
-			c.logger.Error("kafka message unmarshal failed",
-				slog.Int("partition", m.Partition),
-				slog.Int64("offset", m.Offset),
-				slog.Any("err", err))
-			_ = c.reader.CommitMessages(ctx, m)
-			continue
+		c.offsets.track(m)
+		select {
+		case queues[c.worker(m)] <- m:
+		case <-fetchCtx.Done():
+			// Сообщение не закоммитится и будет прочитано снова после перезапуска.
+			c.logger.Info("kafka consumer stopped", slog.Any("reason", fetchCtx.Err()))
+			return nil
 		}
-		if order.Order_uid == "" {
-			c.logger.Error("kafka message without order_uid, skip",
-				slog.Int("partition", m.Partition),
-				slog.Int64("offset", m.Offset))
-			_ = c.reader.CommitMessages(ctx, m)
-			continue
+	}
+}
+
+// handle обрабатывает сообщение и сообщает, можно ли коммитить его offset: да, если заказ
+// записан, сообщение отброшено как некорректное или отложено в DLQ; нет, если запись не удалась.
+func (c *Consumer) handle(ctx context.Context, m kafka.Message) bool {
+	ctx = repository.WithSource(ctx, fmt.Sprintf("kafka:%s/%d@%d", m.Topic, m.Partition, m.Offset))
+	if header(m, HeaderEventType) == model.EventOrderStatusChanged {
+		return c.handleStatus(ctx, m)
+	}
+	order, err := c.decoder.Decode(m)
+	if err != nil {
+		if errors.Is(err, ErrUnsupportedVersion) {
+			// Повтор схему не разберёт, а неподтверждённое сообщение держало бы коммит всей
+			// партиции: откладываем его в DLQ для версии потребителя, которая эту схему знает.
+			return c.deadLetter(ctx, m, err)
 		}
+		c.logger.Error("kafka message decode failed",
+			slog.Int("partition", m.Partition),
+			slog.Int64("offset", m.Offset),
+			slog.String("content_type", header(m, HeaderContentType)),
+			slog.Any("err", err))
+		return true
+	}
+	if order.OrderUID == "" {
+		c.logger.Error("kafka message without order_uid, skip",
+			slog.Int("partition", m.Partition),
+			slog.Int64("offset", m.Offset))
+		return true
+	}
 
-		if err := c.svc.UpsertOrder(ctx, order); err != nil {
-			c.logger.Error("upsert order failed",
-				slog.String("order_uid", order.Order_uid),
+	ctx = c.withOffset(ctx, m)
+	if _, err := c.svc.UpsertOrder(ctx, order); err != nil {
+		if errors.Is(err, repository.ErrAlreadyApplied) {
+			c.logger.Info("kafka message already applied, skip",
+				slog.String("order_uid", order.OrderUID),
 				slog.Int("partition", m.Partition),
-				slog.Int64("offset", m.Offset),
-				slog.Any("err", err))
-			continue
+				slog.Int64("offset", m.Offset))
+			return true
 		}
-
-		if err := c.reader.CommitMessages(ctx, m); err != nil {
-			c.logger.Error("commit offset failed",
+		if errors.Is(err, service.ErrInvalidOrder) {
+			c.logger.Error("kafka message with invalid order, skip",
+				slog.String("order_uid", order.OrderUID),
 				slog.Int("partition", m.Partition),
 				slog.Int64("offset", m.Offset),
 				slog.Any("err", err))
-			continue
+			return true
 		}
-
-		c.logger.Info("kafka message processed",
-			slog.String("order_uid", order.Order_uid),
+		c.logger.Error("upsert order failed",
+			slog.String("order_uid", order.OrderUID),
 			slog.Int("partition", m.Partition),
 			slog.Int64("offset", m.Offset),
-		)
+			slog.Any("err", err))
+		return false
+	}
+
+	c.logger.Info("kafka message processed",
+		slog.String("order_uid", order.OrderUID),
+		slog.Int("partition", m.Partition),
+		slog.Int64("offset", m.Offset),
+	)
+	return true
+}
+
+func (c *Consumer) withOffset(ctx context.Context, m kafka.Message) context.Context {
+	if c.group == "" {
+		return ctx
+	}
+	return repository.WithConsumerOffset(ctx, repository.ConsumerOffset{
+		Group: c.group, Topic: m.Topic, Partition: m.Partition, Offset: m.Offset,
+	})
+}
+
+// Shutdown прекращает чтение новых сообщений и ждёт, пока воркеры допишут полученные,
+// закоммитят их offset'ы и Run закроет reader. Если ctx истёк раньше, возвращает ctx.Err(): чтобы бросить
+// обработку, отмените контекст, переданный в Run.
+func (c *Consumer) Shutdown(ctx context.Context) error {
+	c.stopOnce.Do(func() { close(c.stop) })
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
 
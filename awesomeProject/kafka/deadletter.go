@@ -0,0 +1,53 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Заголовки, которые потребитель добавляет к сообщению, отложенному в DLQ: почему оно не
+// обработано и откуда взято (topic/partition@offset).
+const (
+	HeaderDeadLetterReason = "dlq-reason"
+	HeaderDeadLetterSource = "dlq-source"
+)
+
+type Publisher interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// WithDeadLetter откладывает в pub сообщения, которые этот потребитель не сможет обработать
+// и повтором (например, с неизвестной ему версией схемы), вместо того чтобы терять их.
+func (c *Consumer) WithDeadLetter(pub Publisher) *Consumer {
+	c.dlq = pub
+	return c
+}
+
+// deadLetter откладывает сообщение в DLQ и сообщает, можно ли коммитить его offset. Без DLQ
+// сообщение только логируется и пропускается; если DLQ недоступна, offset не коммитится,
+// и отправка повторится.
+func (c *Consumer) deadLetter(ctx context.Context, m kafka.Message, reason error) bool {
+	attrs := []any{
+		slog.Int("partition", m.Partition),
+		slog.Int64("offset", m.Offset),
+		slog.String("content_type", header(m, HeaderContentType)),
+		slog.Any("err", reason),
+	}
+	if c.dlq == nil {
+		c.logger.Error("kafka poison message, skip", attrs...)
+		return true
+	}
+	headers := append(slices.Clip(m.Headers),
+		kafka.Header{Key: HeaderDeadLetterReason, Value: []byte(reason.Error())},
+		kafka.Header{Key: HeaderDeadLetterSource, Value: []byte(fmt.Sprintf("%s/%d@%d", m.Topic, m.Partition, m.Offset))})
+	if err := c.dlq.WriteMessages(ctx, kafka.Message{Key: m.Key, Value: m.Value, Headers: headers, Time: m.Time}); err != nil {
+		c.logger.Error("kafka dead letter publish failed", append(attrs, slog.Any("dlq_err", err))...)
+		return false
+	}
+	c.logger.Error("kafka poison message moved to dead letter queue", attrs...)
+	return true
+}
@@ -0,0 +1,179 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// OffsetStore хранит offset'ы в БД: репозиторий отмечает каждое сообщение в одной транзакции
+// с заказом, а позиция партиции сдвигается только по непрерывному префиксу обработанных.
+type OffsetStore interface {
+	ConsumerOffsets(ctx context.Context, group, topic string) (map[int]int64, error)
+	AdvanceConsumerOffset(ctx context.Context, group, topic string, partition int, next int64) error
+}
+
+// groupReader читает топик как участник consumer group, но при каждом назначении партиций
+// начинает их с offset'ов из БД, а не с закоммиченных в Kafka. Коммит в Kafka остаётся:
+// по нему видно отставание группы, и он нужен, если в БД для партиции ещё ничего нет.
+type groupReader struct {
+	cfg    kafka.ReaderConfig
+	store  OffsetStore
+	logger *slog.Logger
+
+	group  *kafka.ConsumerGroup
+	msgs   chan kafka.Message
+	start  sync.Once
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu  sync.Mutex
+	gen *kafka.Generation
+}
+
+func newGroupReader(cfg kafka.ReaderConfig, store OffsetStore, logger *slog.Logger) (*groupReader, error) {
+	group, err := kafka.NewConsumerGroup(kafka.ConsumerGroupConfig{
+		ID:                    cfg.GroupID,
+		Brokers:               cfg.Brokers,
+		Topics:                []string{cfg.Topic},
+		WatchPartitionChanges: cfg.WatchPartitionChanges,
+		SessionTimeout:        cfg.SessionTimeout,
+		HeartbeatInterval:     cfg.HeartbeatInterval,
+		RebalanceTimeout:      cfg.RebalanceTimeout,
+		StartOffset:           cfg.StartOffset,
+	})
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &groupReader{
+		cfg:    cfg,
+		store:  store,
+		logger: logger,
+		group:  group,
+		msgs:   make(chan kafka.Message, cfg.QueueCapacity),
+		ctx:    ctx,
+		cancel: cancel,
+	}, nil
+}
+
+func (r *groupReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	r.start.Do(func() { go r.run() })
+	select {
+	case m := <-r.msgs:
+		return m, nil
+	case <-ctx.Done():
+		return kafka.Message{}, ctx.Err()
+	case <-r.ctx.Done():
+		return kafka.Message{}, kafka.ErrGroupClosed
+	}
+}
+
+// CommitMessages сдвигает позицию партиций в БД и коммитит offset'ы в Kafka в рамках текущего
+// поколения группы. Сообщения приходят из непрерывного префикса обработанных, поэтому всё до
+// них уже применено. После ребалансировки коммит в Kafka может не пройти; это безопасно,
+// потому что позиция, с которой продолжит новый владелец партиции, берётся из БД.
+func (r *groupReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	offsets := make(map[string]map[int]int64)
+	for _, m := range msgs {
+		if offsets[m.Topic] == nil {
+			offsets[m.Topic] = make(map[int]int64)
+		}
+		if m.Offset+1 > offsets[m.Topic][m.Partition] {
+			offsets[m.Topic][m.Partition] = m.Offset + 1
+		}
+	}
+	for topic, partitions := range offsets {
+		for partition, next := range partitions {
+			if err := r.store.AdvanceConsumerOffset(ctx, r.cfg.GroupID, topic, partition, next); err != nil {
+				return err
+			}
+		}
+	}
+	r.mu.Lock()
+	gen := r.gen
+	r.mu.Unlock()
+	if gen == nil {
+		return nil
+	}
+	return gen.CommitOffsets(offsets)
+}
+
+func (r *groupReader) Close() error {
+	r.cancel()
+	return r.group.Close()
+}
+
+func (r *groupReader) run() {
+	for {
+		gen, err := r.group.Next(r.ctx)
+		if err != nil {
+			if r.ctx.Err() != nil || errors.Is(err, kafka.ErrGroupClosed) {
+				return
+			}
+			r.logger.Error("kafka group join failed", slog.Any("err", err))
+			select {
+			case <-time.After(r.cfg.ReadBackoffMax):
+			case <-r.ctx.Done():
+				return
+			}
+			continue
+		}
+		r.mu.Lock()
+		r.gen = gen
+		r.mu.Unlock()
+
+		stored, err := r.store.ConsumerOffsets(r.ctx, r.cfg.GroupID, r.cfg.Topic)
+		if err != nil {
+			// Без offset'ов из БД начинаем с закоммиченных в Kafka: повторы всё равно
+			// отсеет проверка offset'а в транзакции записи заказа.
+			r.logger.Error("load stored kafka offsets failed", slog.Any("err", err))
+		}
+		for _, a := range gen.Assignments[r.cfg.Topic] {
+			offset, fromDB := stored[a.ID]
+			if !fromDB {
+				offset = a.Offset
+			}
+			r.logger.Info("kafka partition assigned",
+				slog.Int("partition", a.ID),
+				slog.Int64("offset", offset),
+				slog.Bool("from_db", fromDB))
+			gen.Start(func(ctx context.Context) { r.readPartition(ctx, a.ID, offset) })
+		}
+	}
+}
+
+func (r *groupReader) readPartition(ctx context.Context, partition int, offset int64) {
+	rd := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:        r.cfg.Brokers,
+		Topic:          r.cfg.Topic,
+		Partition:      partition,
+		MinBytes:       r.cfg.MinBytes,
+		MaxBytes:       r.cfg.MaxBytes,
+		ReadBackoffMin: r.cfg.ReadBackoffMin,
+		ReadBackoffMax: r.cfg.ReadBackoffMax,
+	})
+	defer rd.Close()
+	if err := rd.SetOffset(offset); err != nil {
+		r.logger.Error("kafka seek failed", slog.Int("partition", partition), slog.Any("err", err))
+		return
+	}
+	for {
+		m, err := rd.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				r.logger.Error("kafka partition read failed", slog.Int("partition", partition), slog.Any("err", err))
+			}
+			return
+		}
+		select {
+		case r.msgs <- m:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package kafka
+
+import (
+	"context"
+	"hash/fnv"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Способы распределения сообщений по воркерам. Оба сохраняют порядок обработки внутри ключа:
+// DispatchByPartition — всей партиции, DispatchByKey — одного order_uid (ключа сообщения).
+const (
+	DispatchByPartition = "partition"
+	DispatchByKey       = "key"
+)
+
+// workerQueue — сколько полученных сообщений может ждать своего воркера.
+const workerQueue = 16
+
+// Пауза перед повторной обработкой сообщения, которое не удалось записать: удваивается
+// с каждой попыткой от retryBackoffMin до retryBackoffMax.
+const (
+	retryBackoffMin = 100 * time.Millisecond
+	retryBackoffMax = 10 * time.Second
+)
+
+type partitionKey struct {
+	topic     string
+	partition int
+}
+
+// partitionOffsets помнит полученные, но ещё не закоммиченные offset'ы партиции. Коммитится
+// только непрерывный префикс обработанных: пока более раннее сообщение в работе или не
+// записалось, offset'ы после него не коммитятся и при перезапуске будут прочитаны снова.
+type partitionOffsets struct {
+	mu      sync.Mutex
+	pending []kafka.Message
+	done    map[int64]bool
+}
+
+type offsetTracker struct {
+	mu         sync.Mutex
+	partitions map[partitionKey]*partitionOffsets
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{partitions: make(map[partitionKey]*partitionOffsets)}
+}
+
+func (t *offsetTracker) partition(m kafka.Message) *partitionOffsets {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := partitionKey{m.Topic, m.Partition}
+	p, ok := t.partitions[key]
+	if !ok {
+		p = &partitionOffsets{done: make(map[int64]bool)}
+		t.partitions[key] = p
+	}
+	return p
+}
+
+// track регистрирует полученное сообщение; вызывается в порядке чтения партиции. Offset не
+// больше уже полученного означает, что после ребалансировки партиция читается заново с
+// закоммиченного offset'а, и прежний учёт сбрасывается.
+func (t *offsetTracker) track(m kafka.Message) {
+	p := t.partition(m)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if n := len(p.pending); n > 0 && m.Offset <= p.pending[n-1].Offset {
+		p.pending, p.done = p.pending[:0], make(map[int64]bool)
+	}
+	// Для коммита достаточно координат сообщения, тело не держим.
+	p.pending = append(p.pending, kafka.Message{Topic: m.Topic, Partition: m.Partition, Offset: m.Offset})
+}
+
+// complete отмечает сообщение обработанным и коммитит новый непрерывный префикс, если он
+// сдвинулся. Коммит идёт под блокировкой партиции, чтобы offset'ы коммитились по возрастанию.
+func (t *offsetTracker) complete(m kafka.Message, commit func(kafka.Message) error) error {
+	p := t.partition(m)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done[m.Offset] = true
+	n := 0
+	for n < len(p.pending) && p.done[p.pending[n].Offset] {
+		delete(p.done, p.pending[n].Offset)
+		n++
+	}
+	if n == 0 {
+		return nil
+	}
+	last := p.pending[n-1]
+	p.pending = p.pending[n:]
+	return commit(last)
+}
+
+// worker возвращает номер воркера для сообщения.
+func (c *Consumer) worker(m kafka.Message) int {
+	if c.workers == 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	if c.dispatch == DispatchByKey && len(m.Key) > 0 {
+		_, _ = h.Write(m.Key)
+	} else {
+		_, _ = h.Write([]byte(m.Topic + "/" + strconv.Itoa(m.Partition)))
+	}
+	return int(h.Sum32() % uint32(c.workers))
+}
+
+// startWorkers запускает воркеры; каждый обрабатывает свою очередь по порядку.
+func (c *Consumer) startWorkers(ctx context.Context, wg *sync.WaitGroup) []chan kafka.Message {
+	queues := make([]chan kafka.Message, c.workers)
+	for i := range queues {
+		queues[i] = make(chan kafka.Message, workerQueue)
+		wg.Add(1)
+		go func(q <-chan kafka.Message) {
+			defer wg.Done()
+			for m := range q {
+				if !c.process(ctx, m) {
+					continue
+				}
+				if err := c.offsets.complete(m, func(last kafka.Message) error {
+					return c.reader.CommitMessages(ctx, last)
+				}); err != nil {
+					c.logger.Error("commit offset failed",
+						slog.Int("partition", m.Partition),
+						slog.Int64("offset", m.Offset),
+						slog.Any("err", err))
+				}
+			}
+		}(queues[i])
+	}
+	return queues
+}
+
+// process обрабатывает сообщение, повторяя неудачную запись с растущей паузой, пока она не
+// пройдёт: пропущенное сообщение навсегда остановило бы коммит offset'ов партиции после него.
+// Возвращает false, только если ctx отменён раньше; тогда сообщение прочитается после перезапуска.
+func (c *Consumer) process(ctx context.Context, m kafka.Message) bool {
+	wait := retryBackoffMin
+	for !c.handle(ctx, m) {
+		if ctx.Err() != nil {
+			return false
+		}
+		c.logger.Warn("kafka message will be retried",
+			slog.Int("partition", m.Partition),
+			slog.Int64("offset", m.Offset),
+			slog.Duration("backoff", wait))
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return false
+		case <-t.C:
+		}
+		wait = min(2*wait, retryBackoffMax)
+	}
+	return true
+}
@@ -0,0 +1,20 @@
+package kafka
+
+import (
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// NewWriter создаёт writer для публикации в topic брокеров из KAFKA_BROKERS. Сообщения
+// распределяются по партициям по хешу ключа, так что события одного заказа идут по порядку.
+func NewWriter(topic string) *kafka.Writer {
+	return &kafka.Writer{
+		Addr:                   kafka.TCP(splitAndTrim(getEnv("KAFKA_BROKERS", "kafka:9092"))...),
+		Topic:                  topic,
+		Balancer:               &kafka.Hash{},
+		RequiredAcks:           kafka.RequireAll,
+		AllowAutoTopicCreation: true,
+		BatchTimeout:           10 * time.Millisecond,
+	}
+}
@@ -0,0 +1,246 @@
+package kafka
+
+import (
+	"awesomeProject/internal/model"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Минимальный разбор wire-формата Protobuf для сообщения Order из schemas/order.proto:
+// без генерации кода, по таблице номеров полей. Неизвестные поля пропускаются, как
+// и положено Protobuf, поэтому производитель может добавлять поля, не ломая потребителя.
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+type protoKind int
+
+const (
+	protoString protoKind = iota
+	protoInt
+	protoNested
+	protoTimestamp // google.protobuf.Timestamp
+)
+
+type protoField struct {
+	name     string
+	kind     protoKind
+	repeated bool
+	message  protoMessage
+}
+
+// protoMessage — описание сообщения: поля по номерам.
+type protoMessage map[int]protoField
+
+var (
+	protoDelivery = protoMessage{
+		1: {name: "name"}, 2: {name: "phone"}, 3: {name: "zip"}, 4: {name: "city"},
+		5: {name: "address"}, 6: {name: "region"}, 7: {name: "email"},
+	}
+	protoPayment = protoMessage{
+		1: {name: "transaction"}, 2: {name: "request_id"}, 3: {name: "currency"}, 4: {name: "provider"},
+		5: {name: "amount", kind: protoInt}, 6: {name: "payment_dt", kind: protoInt}, 7: {name: "bank"},
+		8: {name: "delivery_cost", kind: protoInt}, 9: {name: "goods_total", kind: protoInt},
+		10: {name: "custom_fee", kind: protoInt},
+	}
+	protoItem = protoMessage{
+		1: {name: "chrt_id", kind: protoInt}, 2: {name: "track_number"}, 3: {name: "price", kind: protoInt},
+		4: {name: "rid"}, 5: {name: "name"}, 6: {name: "sale", kind: protoInt}, 7: {name: "size"},
+		8: {name: "total_price", kind: protoInt}, 9: {name: "nm_id", kind: protoInt}, 10: {name: "brand"},
+		11: {name: "status", kind: protoInt},
+	}
+)
+
+var protoTimestampMessage = protoMessage{1: {name: "seconds", kind: protoInt}, 2: {name: "nanos", kind: protoInt}}
+
+// orderDescriptor возвращает описание Order для версии схемы. В версии 1 поле 13
+// (date_created) — int64 секунд Unix, начиная с версии 2 — google.protobuf.Timestamp.
+func orderDescriptor(version int) protoMessage {
+	d := protoMessage{
+		1: {name: "order_uid"}, 2: {name: "track_number"}, 3: {name: "entry"},
+		4: {name: "delivery", kind: protoNested, message: protoDelivery},
+		5: {name: "payment", kind: protoNested, message: protoPayment},
+		6: {name: "items", kind: protoNested, message: protoItem, repeated: true},
+		7: {name: "locale"}, 8: {name: "internal_signature"}, 9: {name: "customer_id"},
+		10: {name: "delivery_service"}, 11: {name: "shardkey"}, 12: {name: "sm_id", kind: protoInt},
+		13: {name: "date_created", kind: protoTimestamp}, 14: {name: "oof_shard"},
+	}
+	if version == 1 {
+		d[13] = protoField{name: "date_created", kind: protoInt}
+	}
+	return d
+}
+
+var errProtoTruncated = errors.New("protobuf: truncated message")
+
+func decodeProtobuf(data []byte, desc protoMessage) (map[string]any, error) {
+	doc := make(map[string]any)
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, errProtoTruncated
+		}
+		data = data[n:]
+		num, wire := int(key>>3), int(key&7)
+
+		var (
+			varint uint64
+			raw    []byte
+		)
+		switch wire {
+		case wireVarint:
+			varint, n = binary.Uvarint(data)
+			if n <= 0 {
+				return nil, errProtoTruncated
+			}
+			data = data[n:]
+		case wireFixed64, wireFixed32:
+			size := 8
+			if wire == wireFixed32 {
+				size = 4
+			}
+			if len(data) < size {
+				return nil, errProtoTruncated
+			}
+			data = data[size:]
+		case wireBytes:
+			l, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < l {
+				return nil, errProtoTruncated
+			}
+			raw, data = data[n:n+int(l)], data[n+int(l):]
+		default:
+			return nil, fmt.Errorf("protobuf: unsupported wire type %d in field %d", wire, num)
+		}
+
+		f, ok := desc[num]
+		if !ok {
+			continue
+		}
+		want := wireBytes
+		if f.kind == protoInt {
+			want = wireVarint
+		}
+		if wire != want {
+			return nil, fmt.Errorf("protobuf: field %s: wire type %d, want %d", f.name, wire, want)
+		}
+		var v any
+		switch f.kind {
+		case protoString:
+			v = string(raw)
+		case protoInt:
+			v = int64(varint)
+		case protoNested:
+			sub, err := decodeProtobuf(raw, f.message)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", f.name, err)
+			}
+			v = sub
+		case protoTimestamp:
+			ts, err := decodeProtobuf(raw, protoTimestampMessage)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", f.name, err)
+			}
+			sec, _ := ts["seconds"].(int64)
+			nanos, _ := ts["nanos"].(int64)
+			v = time.Unix(sec, nanos).UTC().Format(time.RFC3339Nano)
+		}
+		if f.repeated {
+			list, _ := doc[f.name].([]any)
+			doc[f.name] = append(list, v)
+		} else {
+			doc[f.name] = v
+		}
+	}
+	return doc, nil
+}
+
+// EncodeProtobuf кодирует заказ в Protobuf текущей версии схемы (schemas/order.proto).
+func EncodeProtobuf(order model.Order) ([]byte, error) {
+	doc, err := orderDocument(order)
+	if err != nil {
+		return nil, err
+	}
+	return encodeProtobuf(nil, doc, orderDescriptor(SchemaVersion))
+}
+
+func encodeProtobuf(buf []byte, doc map[string]any, desc protoMessage) ([]byte, error) {
+	nums := make([]int, 0, len(desc))
+	for num := range desc {
+		nums = append(nums, num)
+	}
+	slices.Sort(nums)
+	for _, num := range nums {
+		f := desc[num]
+		values := []any{doc[f.name]}
+		if f.repeated {
+			values, _ = doc[f.name].([]any)
+		}
+		for _, v := range values {
+			var err error
+			if buf, err = appendProtoField(buf, num, f, v); err != nil {
+				return nil, fmt.Errorf("%s: %w", f.name, err)
+			}
+		}
+	}
+	return buf, nil
+}
+
+func appendProtoField(buf []byte, num int, f protoField, v any) ([]byte, error) {
+	switch f.kind {
+	case protoString:
+		s, _ := v.(string)
+		if s == "" {
+			return buf, nil
+		}
+		return appendProtoBytes(buf, num, []byte(s)), nil
+	case protoInt:
+		n, err := toInt(v)
+		if err != nil || n == 0 {
+			return buf, err
+		}
+		buf = binary.AppendUvarint(buf, uint64(num)<<3|wireVarint)
+		return binary.AppendUvarint(buf, uint64(n)), nil
+	case protoNested:
+		sub, ok := v.(map[string]any)
+		if !ok {
+			return buf, nil
+		}
+		data, err := encodeProtobuf(nil, sub, f.message)
+		if err != nil {
+			return nil, err
+		}
+		return appendProtoBytes(buf, num, data), nil
+	case protoTimestamp:
+		s, _ := v.(string)
+		if s == "" {
+			return buf, nil
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, err
+		}
+		var ts []byte
+		if sec := t.Unix(); sec != 0 {
+			ts = binary.AppendUvarint(binary.AppendUvarint(ts, 1<<3|wireVarint), uint64(sec))
+		}
+		if nanos := t.Nanosecond(); nanos != 0 {
+			ts = binary.AppendUvarint(binary.AppendUvarint(ts, 2<<3|wireVarint), uint64(nanos))
+		}
+		return appendProtoBytes(buf, num, ts), nil
+	}
+	return buf, nil
+}
+
+func appendProtoBytes(buf []byte, num int, data []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(num)<<3|wireBytes)
+	buf = binary.AppendUvarint(buf, uint64(len(data)))
+	return append(buf, data...)
+}
@@ -0,0 +1,146 @@
+package kafka
+
+import (
+	"awesomeProject/internal/model"
+	"embed"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Встроенные схемы заказа. ID совпадают с версией схемы, чтобы их было проще сопоставить.
+//
+//go:embed schemas/*.avsc
+var builtinSchemas embed.FS
+
+var builtin = []struct {
+	id, version int
+	file        string
+}{
+	{1, 1, "schemas/order.v1.avsc"},
+	{2, 2, "schemas/order.v2.avsc"},
+}
+
+// avroMagic — первый байт сообщения в формате Confluent: за ним 4 байта ID схемы (big endian)
+// и тело в бинарной кодировке Avro.
+const avroMagic = 0
+
+type registeredSchema struct {
+	version int
+	schema  *avroSchema
+}
+
+// Registry — локальная замена реестра схем: Avro-схемы по ID вместе с версией схемы заказа,
+// которой они соответствуют. Встроенные схемы есть всегда, дополнительные загружаются из файла.
+type Registry struct {
+	mu      sync.RWMutex
+	schemas map[int]registeredSchema
+}
+
+func NewRegistry() *Registry {
+	r := &Registry{schemas: make(map[int]registeredSchema)}
+	for _, b := range builtin {
+		data, err := builtinSchemas.ReadFile(b.file)
+		if err == nil {
+			err = r.Register(b.id, b.version, data)
+		}
+		if err != nil {
+			panic(fmt.Sprintf("builtin schema %s: %v", b.file, err))
+		}
+	}
+	return r
+}
+
+// Register добавляет Avro-схему с данным ID для версии схемы заказа version.
+func (r *Registry) Register(id, version int, schema []byte) error {
+	s, err := parseAvroSchema(schema)
+	if err != nil {
+		return fmt.Errorf("schema %d: %w", id, err)
+	}
+	if s.typ != "record" {
+		return fmt.Errorf("schema %d: top-level type must be a record, got %s", id, s.typ)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.schemas[id] = registeredSchema{version: version, schema: s}
+	return nil
+}
+
+type registryFile []struct {
+	ID      int             `json:"id"`
+	Version int             `json:"version"`
+	Schema  json.RawMessage `json:"schema"`
+}
+
+// LoadRegistry возвращает реестр со встроенными схемами и схемами из файла path:
+// JSON-массив [{"id": 10, "version": 2, "schema": {...}}, ...]. Пустой path — только встроенные.
+func LoadRegistry(path string) (*Registry, error) {
+	r := NewRegistry()
+	if path == "" {
+		return r, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries registryFile
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, e := range entries {
+		if err := r.Register(e.ID, e.Version, e.Schema); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return r, nil
+}
+
+func (r *Registry) lookup(id int) (registeredSchema, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s, ok := r.schemas[id]
+	if !ok {
+		return registeredSchema{}, fmt.Errorf("%w: unknown schema id %d", ErrUnsupportedVersion, id)
+	}
+	return s, nil
+}
+
+func (r *Registry) decodeAvro(data []byte) (map[string]any, int, error) {
+	if len(data) < 5 || data[0] != avroMagic {
+		return nil, 0, errors.New("avro: missing schema id header")
+	}
+	s, err := r.lookup(int(binary.BigEndian.Uint32(data[1:5])))
+	if err != nil {
+		return nil, 0, err
+	}
+	rd := &avroReader{data: data[5:]}
+	v, err := rd.read(s.schema)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(rd.data) != 0 {
+		return nil, 0, fmt.Errorf("avro: %d trailing bytes", len(rd.data))
+	}
+	return v.(map[string]any), s.version, nil
+}
+
+// EncodeAvro кодирует заказ схемой id в формате Confluent. Схема должна описывать текущую
+// версию заказа.
+func (r *Registry) EncodeAvro(id int, order model.Order) ([]byte, error) {
+	s, err := r.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	if s.version != SchemaVersion {
+		return nil, fmt.Errorf("schema %d is for version %d, orders are encoded as version %d", id, s.version, SchemaVersion)
+	}
+	doc, err := orderDocument(order)
+	if err != nil {
+		return nil, err
+	}
+	buf := binary.BigEndian.AppendUint32([]byte{avroMagic}, uint32(id))
+	return appendAvro(buf, s.schema, doc)
+}
@@ -0,0 +1,61 @@
+package kafka
+
+import (
+	"awesomeProject/internal/model"
+	"awesomeProject/internal/repository"
+	"awesomeProject/internal/service"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// handleStatus применяет событие смены статуса. Недопустимый переход, неизвестный заказ и
+// некорректное тело не исправятся повтором, поэтому такие события пропускаются и коммитятся;
+// повторная доставка уже применённого события — тоже недопустимый переход (в тот же статус).
+func (c *Consumer) handleStatus(ctx context.Context, m kafka.Message) bool {
+	var change model.StatusChange
+	if err := json.Unmarshal(m.Value, &change); err != nil {
+		c.logger.Error("kafka status event decode failed",
+			slog.Int("partition", m.Partition),
+			slog.Int64("offset", m.Offset),
+			slog.Any("err", err))
+		return true
+	}
+	// Источник — само сообщение (его задаёт handle), а не поле в теле события.
+	change.From, change.Source = "", ""
+
+	_, err := c.svc.ChangeStatus(c.withOffset(ctx, m), change)
+	switch {
+	case err == nil:
+		c.logger.Info("kafka status event processed",
+			slog.String("order_uid", change.OrderUID),
+			slog.String("status", string(change.To)),
+			slog.Int("partition", m.Partition),
+			slog.Int64("offset", m.Offset))
+		return true
+	case errors.Is(err, repository.ErrAlreadyApplied):
+		c.logger.Info("kafka message already applied, skip",
+			slog.String("order_uid", change.OrderUID),
+			slog.Int("partition", m.Partition),
+			slog.Int64("offset", m.Offset))
+		return true
+	case errors.Is(err, model.ErrInvalidTransition), errors.Is(err, service.ErrInvalidOrder),
+		errors.Is(err, repository.ErrNotFound):
+		c.logger.Error("kafka status event rejected, skip",
+			slog.String("order_uid", change.OrderUID),
+			slog.String("status", string(change.To)),
+			slog.Int("partition", m.Partition),
+			slog.Int64("offset", m.Offset),
+			slog.Any("err", err))
+		return true
+	}
+	c.logger.Error("change order status failed",
+		slog.String("order_uid", change.OrderUID),
+		slog.Int("partition", m.Partition),
+		slog.Int64("offset", m.Offset),
+		slog.Any("err", err))
+	return false
+}
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    order_uid TEXT NOT NULL,
+    action TEXT NOT NULL,
+    source TEXT NOT NULL,
+    before JSONB,
+    after JSONB,
+    diff JSONB,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_order_uid_idx ON audit_log (order_uid, id);
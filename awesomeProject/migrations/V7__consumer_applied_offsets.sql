@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS consumer_applied_offsets (
+    group_id TEXT NOT NULL,
+    topic TEXT NOT NULL,
+    "partition" INT NOT NULL,
+    "offset" BIGINT NOT NULL,
+    PRIMARY KEY (group_id, topic, "partition", "offset")
+);
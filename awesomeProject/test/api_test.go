@@ -0,0 +1,506 @@
+package test
+
+import (
+	"awesomeProject/internal/api"
+	"awesomeProject/internal/model"
+	"awesomeProject/internal/repository"
+	"awesomeProject/internal/service"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type stubService struct {
+	getFn     func(ctx context.Context, id string) (model.Order, error)
+	upsertFn  func(ctx context.Context, o model.Order) (bool, error)
+	replaceFn func(ctx context.Context, o model.Order) (bool, error)
+	patchFn   func(ctx context.Context, id string, patch []byte) (model.Order, error)
+	deleteFn  func(ctx context.Context, id string) error
+	statusFn  func(ctx context.Context, change model.StatusChange) (model.StatusChange, error)
+	historyFn func(ctx context.Context, id string) (model.StatusHistory, error)
+	auditFn   func(ctx context.Context, id string) ([]model.AuditRecord, error)
+	gotID     string
+}
+
+func (s *stubService) GetOrderByID(ctx context.Context, id string) (model.Order, error) {
+	s.gotID = id
+	if s.getFn != nil {
+		return s.getFn(ctx, id)
+	}
+	return model.Order{OrderUID: id}, nil
+}
+
+func (s *stubService) UpsertOrder(ctx context.Context, o model.Order) (bool, error) {
+	if s.upsertFn != nil {
+		return s.upsertFn(ctx, o)
+	}
+	return true, nil
+}
+
+func (s *stubService) ReplaceOrder(ctx context.Context, o model.Order) (bool, error) {
+	s.gotID = o.OrderUID
+	if s.replaceFn != nil {
+		return s.replaceFn(ctx, o)
+	}
+	return true, nil
+}
+
+func (s *stubService) PatchOrder(ctx context.Context, id string, patch []byte) (model.Order, error) {
+	s.gotID = id
+	if s.patchFn != nil {
+		return s.patchFn(ctx, id, patch)
+	}
+	return model.Order{OrderUID: id}, nil
+}
+
+func (s *stubService) DeleteOrder(ctx context.Context, id string) error {
+	s.gotID = id
+	if s.deleteFn != nil {
+		return s.deleteFn(ctx, id)
+	}
+	return nil
+}
+
+func (s *stubService) ChangeStatus(ctx context.Context, change model.StatusChange) (model.StatusChange, error) {
+	s.gotID = change.OrderUID
+	if s.statusFn != nil {
+		return s.statusFn(ctx, change)
+	}
+	return change, nil
+}
+
+func (s *stubService) StatusHistory(ctx context.Context, id string) (model.StatusHistory, error) {
+	s.gotID = id
+	if s.historyFn != nil {
+		return s.historyFn(ctx, id)
+	}
+	return model.StatusHistory{OrderUID: id, Status: model.StatusCreated}, nil
+}
+
+func (s *stubService) OrderHistory(ctx context.Context, id string) ([]model.AuditRecord, error) {
+	s.gotID = id
+	if s.auditFn != nil {
+		return s.auditFn(ctx, id)
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (s *stubService) UpsertMany(ctx context.Context, list []model.Order, batchSize int) []service.BulkResult {
+	out := make([]service.BulkResult, len(list))
+	for i, o := range list {
+		out[i] = service.BulkResult{Index: i, OrderUID: o.OrderUID, Status: service.BulkCreated}
+		if o.OrderUID == "" {
+			out[i].Status, out[i].Reason = service.BulkInvalid, "order_uid: is required"
+		}
+	}
+	return out
+}
+
+func serve(h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAPI_GetOrder_PathParsing(t *testing.T) {
+	cases := []struct {
+		path   string
+		status int
+		id     string
+	}{
+		{"/order/abc", http.StatusOK, "abc"},
+		{"/order/abc/", http.StatusOK, "abc"},
+		{"/order/a%20b", http.StatusOK, "a b"},
+		{"/order/a%2Fb", http.StatusOK, "a/b"},
+		{"/order/", http.StatusBadRequest, ""},
+		{"/order/%20", http.StatusBadRequest, ""},
+		{"/order/a/b", http.StatusBadRequest, ""},
+		{"/order/a/b/", http.StatusBadRequest, ""},
+	}
+	for _, tc := range cases {
+		svc := &stubService{}
+		rec := serve(api.NewRouter(svc, api.Config{}), http.MethodGet, tc.path, "")
+		if rec.Code != tc.status {
+			t.Fatalf("%s: ожидали %d, получили %d (%s)", tc.path, tc.status, rec.Code, rec.Body)
+		}
+		if svc.gotID != tc.id {
+			t.Fatalf("%s: ожидали id %q, получили %q", tc.path, tc.id, svc.gotID)
+		}
+	}
+}
+
+func TestAPI_MethodHandling(t *testing.T) {
+	h := api.NewRouter(&stubService{}, api.Config{})
+	cases := []struct {
+		method, path string
+		status       int
+		allow        string
+	}{
+		{http.MethodHead, "/order/abc", http.StatusOK, ""},
+		{http.MethodPost, "/order/abc", http.StatusMethodNotAllowed, "GET, HEAD"},
+		{http.MethodDelete, "/order/abc", http.StatusMethodNotAllowed, "GET, HEAD"},
+		{http.MethodGet, "/order", http.StatusMethodNotAllowed, "POST"},
+		{http.MethodPut, "/order", http.StatusMethodNotAllowed, "POST"},
+	}
+	for _, tc := range cases {
+		rec := serve(h, tc.method, tc.path, "")
+		if rec.Code != tc.status {
+			t.Fatalf("%s %s: ожидали %d, получили %d", tc.method, tc.path, tc.status, rec.Code)
+		}
+		if got := rec.Header().Get("Allow"); got != tc.allow {
+			t.Fatalf("%s %s: Allow %q, ожидали %q", tc.method, tc.path, got, tc.allow)
+		}
+	}
+}
+
+func TestAPI_GetOrder_ErrorMapping(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+	}{
+		{repository.ErrNotFound, http.StatusNotFound},
+		{context.DeadlineExceeded, http.StatusGatewayTimeout},
+		{context.Canceled, api.StatusClientClosedRequest},
+		{&service.ValidationError{Field: "order_uid", Reason: "is required"}, http.StatusUnprocessableEntity},
+		{fmt.Errorf("%w: %w", service.ErrDependency, errors.New("connection refused")), http.StatusServiceUnavailable},
+		{errors.New("boom"), http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		svc := &stubService{getFn: func(ctx context.Context, id string) (model.Order, error) {
+			return model.Order{}, tc.err
+		}}
+		rec := serve(api.NewRouter(svc, api.Config{}), http.MethodGet, "/order/x", "")
+		if rec.Code != tc.status {
+			t.Fatalf("%v: ожидали %d, получили %d", tc.err, tc.status, rec.Code)
+		}
+		var body api.ErrorResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%v: ошибка должна быть JSON: %v (%s)", tc.err, err, rec.Body)
+		}
+		if body.Code == "" || body.Message == "" || body.RequestID == "" {
+			t.Fatalf("%v: неполный конверт ошибки %+v", tc.err, body)
+		}
+	}
+}
+
+func TestAPI_ErrorEnvelope_EchoesRequestID(t *testing.T) {
+	svc := &stubService{getFn: func(ctx context.Context, id string) (model.Order, error) {
+		return model.Order{}, repository.ErrNotFound
+	}}
+	req := httptest.NewRequest(http.MethodGet, "/order/x", nil)
+	req.Header.Set("X-Request-ID", "req-42")
+	rec := httptest.NewRecorder()
+	api.NewRouter(svc, api.Config{}).ServeHTTP(rec, req)
+	var body api.ErrorResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &body)
+	if body.Code != "not_found" || body.RequestID != "req-42" || rec.Header().Get("X-Request-ID") != "req-42" {
+		t.Fatalf("неожиданный ответ %+v, заголовок %q", body, rec.Header().Get("X-Request-ID"))
+	}
+}
+
+func TestAPI_GetOrder_Timeout(t *testing.T) {
+	svc := &stubService{getFn: func(ctx context.Context, id string) (model.Order, error) {
+		<-ctx.Done()
+		return model.Order{}, ctx.Err()
+	}}
+	h := api.NewRouter(svc, api.Config{Timeout: 20 * time.Millisecond})
+	start := time.Now()
+	rec := serve(h, http.MethodGet, "/order/slow", "")
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("ожидали 504, получили %d", rec.Code)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatalf("таймаут роутера не применился")
+	}
+}
+
+func TestAPI_GetOrder_ResponseShape(t *testing.T) {
+	exp := model.Order{OrderUID: "id1", TrackNumber: "TN", Items: []model.Item{{ChrtID: 1}}}
+	svc := &stubService{getFn: func(ctx context.Context, id string) (model.Order, error) { return exp, nil }}
+	rec := serve(api.NewRouter(svc, api.Config{}), http.MethodGet, "/order/id1", "")
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Fatalf("Content-Type %q", ct)
+	}
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("ответ не JSON: %v", err)
+	}
+	for _, key := range []string{"order_uid", "track_number", "delivery", "payment", "items", "date_created"} {
+		if _, ok := body[key]; !ok {
+			t.Fatalf("в ответе нет поля %q", key)
+		}
+	}
+}
+
+func TestAPI_PostOrder(t *testing.T) {
+	var stored model.Order
+	svc := &stubService{upsertFn: func(ctx context.Context, o model.Order) (bool, error) {
+		stored = o
+		return true, nil
+	}}
+	h := api.NewRouter(svc, api.Config{})
+
+	rec := serve(h, http.MethodPost, "/order", `{"order_uid":"p1","track_number":"T"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("ожидали 201, получили %d", rec.Code)
+	}
+	var body map[string]string
+	_ = json.Unmarshal(rec.Body.Bytes(), &body)
+	if body["status"] != "ok" || body["order_uid"] != "p1" || stored.OrderUID != "p1" {
+		t.Fatalf("неожиданный ответ %v / %+v", body, stored)
+	}
+
+	if rec := serve(h, http.MethodPost, "/order", `{"order_uid":`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("битый JSON: ожидали 400, получили %d", rec.Code)
+	}
+	if rec := serve(h, http.MethodPost, "/order", `{"track_number":"T"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("без order_uid: ожидали 422, получили %d", rec.Code)
+	}
+	svc.upsertFn = func(ctx context.Context, o model.Order) (bool, error) {
+		return false, nil
+	}
+	if rec := serve(h, http.MethodPost, "/order", `{"order_uid":"p1"}`); rec.Code != http.StatusConflict {
+		t.Fatalf("существующий order_uid: ожидали 409, получили %d", rec.Code)
+	}
+	svc.upsertFn = func(ctx context.Context, o model.Order) (bool, error) {
+		return false, fmt.Errorf("%w: %w", service.ErrDependency, errors.New("db down"))
+	}
+	if rec := serve(h, http.MethodPost, "/order", `{"order_uid":"p2"}`); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("ошибка БД: ожидали 503, получили %d", rec.Code)
+	}
+}
+
+func TestAPIv1_Routes(t *testing.T) {
+	cases := []struct {
+		method, path, body string
+		status             int
+		id                 string
+	}{
+		{http.MethodGet, "/api/v1/orders/abc", "", http.StatusOK, "abc"},
+		{http.MethodHead, "/api/v1/orders/abc", "", http.StatusOK, "abc"},
+		{http.MethodGet, "/api/v1/orders/a%2Fb", "", http.StatusOK, "a/b"},
+		{http.MethodPut, "/api/v1/orders/abc", `{"track_number":"T"}`, http.StatusCreated, "abc"},
+		{http.MethodPut, "/api/v1/orders/abc", `{"order_uid":"other"}`, http.StatusUnprocessableEntity, ""},
+		{http.MethodPatch, "/api/v1/orders/abc", `{"delivery":{"city":"Kazan"}}`, http.StatusOK, "abc"},
+		{http.MethodPatch, "/api/v1/orders/abc", `{"delivery":`, http.StatusBadRequest, ""},
+		{http.MethodDelete, "/api/v1/orders/abc", "", http.StatusNoContent, "abc"},
+		{http.MethodPost, "/api/v1/orders", `{"order_uid":"n1"}`, http.StatusCreated, ""},
+	}
+	for _, tc := range cases {
+		svc := &stubService{}
+		rec := serve(api.NewRouter(svc, api.Config{}), tc.method, tc.path, tc.body)
+		if rec.Code != tc.status {
+			t.Fatalf("%s %s: ожидали %d, получили %d (%s)", tc.method, tc.path, tc.status, rec.Code, rec.Body)
+		}
+		if svc.gotID != tc.id {
+			t.Fatalf("%s %s: ожидали id %q, получили %q", tc.method, tc.path, tc.id, svc.gotID)
+		}
+	}
+}
+
+func TestAPIv1_MethodNotAllowed(t *testing.T) {
+	h := api.NewRouter(&stubService{}, api.Config{})
+	cases := []struct{ method, path, allow string }{
+		{http.MethodPost, "/api/v1/orders/abc", "GET, HEAD, PUT, PATCH, DELETE"},
+		{http.MethodGet, "/api/v1/orders", "POST"},
+		{http.MethodDelete, "/api/v1/orders", "POST"},
+	}
+	for _, tc := range cases {
+		rec := serve(h, tc.method, tc.path, "")
+		if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != tc.allow {
+			t.Fatalf("%s %s: %d, Allow %q", tc.method, tc.path, rec.Code, rec.Header().Get("Allow"))
+		}
+		var body api.ErrorResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Code != "method_not_allowed" {
+			t.Fatalf("%s %s: ожидали JSON-ошибку, получили %s", tc.method, tc.path, rec.Body)
+		}
+	}
+}
+
+func TestAPI_LegacyRoutesAreDeprecated(t *testing.T) {
+	rec := serve(api.NewRouter(&stubService{}, api.Config{}), http.MethodGet, "/order/abc", "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Deprecation") != "true" {
+		t.Fatalf("старый маршрут должен работать и помечаться устаревшим: %d %v", rec.Code, rec.Header())
+	}
+	if link := rec.Header().Get("Link"); !strings.Contains(link, "/api/v1/orders/abc") {
+		t.Fatalf("Link должен указывать на новый маршрут, получили %q", link)
+	}
+}
+
+func postWithKey(h http.Handler, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/orders", strings.NewReader(body))
+	req.Header.Set("Idempotency-Key", key)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAPI_IdempotencyKey(t *testing.T) {
+	calls := 0
+	svc := &stubService{upsertFn: func(ctx context.Context, o model.Order) (bool, error) {
+		calls++
+		return true, nil
+	}}
+	h := api.NewRouter(svc, api.Config{Idempotency: api.NewMemoryIdempotencyStore()})
+
+	first := postWithKey(h, "k1", `{"order_uid":"i1"}`)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("ожидали 201, получили %d", first.Code)
+	}
+	again := postWithKey(h, "k1", `{"order_uid":"i1"}`)
+	if again.Code != first.Code || again.Body.String() != first.Body.String() {
+		t.Fatalf("повтор должен вернуть исходный ответ: %d %s", again.Code, again.Body)
+	}
+	if again.Header().Get("Idempotent-Replayed") != "true" || calls != 1 {
+		t.Fatalf("повтор не должен доходить до сервиса: calls=%d", calls)
+	}
+	if rec := postWithKey(h, "k1", `{"order_uid":"i2"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("ключ с другим телом: ожидали 422, получили %d", rec.Code)
+	}
+	if rec := postWithKey(h, "k2", `{"order_uid":"i2"}`); rec.Code != http.StatusCreated || calls != 2 {
+		t.Fatalf("новый ключ должен выполняться: %d calls=%d", rec.Code, calls)
+	}
+}
+
+func TestAPI_IdempotencyKey_ServerErrorIsNotStored(t *testing.T) {
+	fail := true
+	svc := &stubService{upsertFn: func(ctx context.Context, o model.Order) (bool, error) {
+		if fail {
+			return false, fmt.Errorf("%w: %w", service.ErrDependency, errors.New("db down"))
+		}
+		return true, nil
+	}}
+	h := api.NewRouter(svc, api.Config{Idempotency: api.NewMemoryIdempotencyStore()})
+	if rec := postWithKey(h, "k", `{"order_uid":"i1"}`); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("ожидали 503, получили %d", rec.Code)
+	}
+	fail = false
+	if rec := postWithKey(h, "k", `{"order_uid":"i1"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("после 5xx ключ должен освобождаться, получили %d", rec.Code)
+	}
+}
+
+func TestAPI_MemoryIdempotencyStore_PurgesExpiredKeys(t *testing.T) {
+	ctx := context.Background()
+	store := api.NewMemoryIdempotencyStore()
+	if _, err := store.ReserveIdempotencyKey(ctx, "old", "fp", 20*time.Millisecond); err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	// Проход по карте при резервировании другого ключа удаляет просроченный.
+	if _, err := store.ReserveIdempotencyKey(ctx, "new", "fp", 20*time.Millisecond); err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+	n, err := store.PurgeIdempotencyKeys(ctx, time.Now().Add(time.Minute))
+	if err != nil || n != 1 {
+		t.Fatalf("просроченный ключ должен быть удалён при проходе, осталось %d (err=%v)", n, err)
+	}
+	if rec, err := store.ReserveIdempotencyKey(ctx, "new", "fp", time.Hour); err != nil || rec != nil {
+		t.Fatalf("удалённый ключ должен заниматься заново: rec=%v err=%v", rec, err)
+	}
+}
+
+func TestAPI_BulkIngestion(t *testing.T) {
+	h := api.NewRouter(&stubService{}, api.Config{})
+	type response struct {
+		Results []service.BulkResult `json:"results"`
+		Summary map[string]int       `json:"summary"`
+	}
+	check := func(rec *httptest.ResponseRecorder, want []string) {
+		t.Helper()
+		if rec.Code != http.StatusOK {
+			t.Fatalf("ожидали 200, получили %d (%s)", rec.Code, rec.Body)
+		}
+		var resp response
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("ответ не JSON: %v", err)
+		}
+		if len(resp.Results) != len(want) {
+			t.Fatalf("ожидали %d результатов, получили %+v", len(want), resp.Results)
+		}
+		for i, st := range want {
+			if resp.Results[i].Index != i || resp.Results[i].Status != st {
+				t.Fatalf("результат #%d: %+v, ожидали %s", i, resp.Results[i], st)
+			}
+		}
+	}
+
+	array := `[{"order_uid":"a"},{"order_uid":"b","sm_id":"oops"},{"track_number":"T"},{"order_uid":"c"}]`
+	check(serve(h, http.MethodPost, "/api/v1/orders/bulk", array),
+		[]string{service.BulkCreated, service.BulkInvalid, service.BulkInvalid, service.BulkCreated})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/orders/bulk",
+		strings.NewReader("{\"order_uid\":\"a\"}\n{broken\n\n{\"order_uid\":\"b\"}\n"))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	check(rec, []string{service.BulkCreated, service.BulkInvalid, service.BulkCreated})
+
+	if rec := serve(h, http.MethodPost, "/api/v1/orders/bulk", `{"order_uid":"a"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("объект вместо массива: ожидали 400, получили %d", rec.Code)
+	}
+	small := api.NewRouter(&stubService{}, api.Config{BulkMaxBytes: 16})
+	if rec := serve(small, http.MethodPost, "/api/v1/orders/bulk", array); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("большое тело: ожидали 413, получили %d", rec.Code)
+	}
+}
+
+func TestAPI_BodyDecoding(t *testing.T) {
+	strict := api.NewRouter(&stubService{}, api.Config{StrictJSON: true, MaxBodyBytes: 64})
+	lax := api.NewRouter(&stubService{}, api.Config{})
+	cases := []struct {
+		name     string
+		h        http.Handler
+		method   string
+		path     string
+		ctype    string
+		body     string
+		wantCode int
+		wantErr  string
+		wantMsg  string
+	}{
+		{"ok", strict, http.MethodPost, "/api/v1/orders", "application/json; charset=utf-8", `{"order_uid":"a"}`, http.StatusCreated, "", ""},
+		{"без content-type", strict, http.MethodPost, "/api/v1/orders", "", `{"order_uid":"a"}`, http.StatusCreated, "", ""},
+		{"text/plain", strict, http.MethodPost, "/api/v1/orders", "text/plain", `{"order_uid":"a"}`, http.StatusUnsupportedMediaType, "unsupported_media_type", ""},
+		{"merge-patch для PUT", strict, http.MethodPut, "/api/v1/orders/a", "application/merge-patch+json", `{"order_uid":"a"}`, http.StatusCreated, "", ""},
+		{"form для PATCH", strict, http.MethodPatch, "/api/v1/orders/a", "application/x-www-form-urlencoded", `a=1`, http.StatusUnsupportedMediaType, "unsupported_media_type", ""},
+		{"слишком большое тело", strict, http.MethodPost, "/api/v1/orders", "", `{"order_uid":"` + strings.Repeat("x", 100) + `"}`, http.StatusRequestEntityTooLarge, "body_too_large", "64"},
+		{"неизвестное поле", strict, http.MethodPost, "/api/v1/orders", "", `{"order_uid":"a","oops":1}`, http.StatusBadRequest, "unknown_field", `"oops"`},
+		{"неизвестное поле в payment", strict, http.MethodPost, "/api/v1/orders", "", `{"order_uid":"a","payment":{"oops":1}}`, http.StatusBadRequest, "unknown_field", `"oops"`},
+		{"неверный тип payment_dt", lax, http.MethodPost, "/api/v1/orders", "", `{"order_uid":"a","payment":{"payment_dt":"x"}}`, http.StatusBadRequest, "invalid_field", ""},
+		{"неизвестное поле без strict", lax, http.MethodPost, "/api/v1/orders", "", `{"order_uid":"a","oops":1}`, http.StatusCreated, "", ""},
+		{"данные после объекта", lax, http.MethodPost, "/api/v1/orders", "", `{"order_uid":"a"}{"order_uid":"b"}`, http.StatusBadRequest, "trailing_data", "offset"},
+		{"неверный тип поля", lax, http.MethodPost, "/api/v1/orders", "", `{"order_uid":"a","sm_id":"x"}`, http.StatusBadRequest, "invalid_field", `"sm_id"`},
+		{"синтаксическая ошибка", lax, http.MethodPost, "/api/v1/orders", "", `{"order_uid" "a"}`, http.StatusBadRequest, "invalid_json", "offset 14"},
+		{"пустое тело", lax, http.MethodPost, "/api/v1/orders", "", ``, http.StatusBadRequest, "empty_body", ""},
+		{"мусор после массива", lax, http.MethodPost, "/api/v1/orders/bulk", "", `[{"order_uid":"a"}] x`, http.StatusBadRequest, "trailing_data", ""},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		if tc.ctype != "" {
+			req.Header.Set("Content-Type", tc.ctype)
+		}
+		rec := httptest.NewRecorder()
+		tc.h.ServeHTTP(rec, req)
+		if rec.Code != tc.wantCode {
+			t.Fatalf("%s: ожидали %d, получили %d (%s)", tc.name, tc.wantCode, rec.Code, rec.Body)
+		}
+		if tc.wantErr == "" {
+			continue
+		}
+		var resp api.ErrorResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: ответ не JSON: %v", tc.name, err)
+		}
+		if resp.Code != tc.wantErr || !strings.Contains(resp.Message, tc.wantMsg) {
+			t.Fatalf("%s: ожидали код %q с %q в сообщении, получили %+v", tc.name, tc.wantErr, tc.wantMsg, resp)
+		}
+	}
+}
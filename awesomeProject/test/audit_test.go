@@ -0,0 +1,115 @@
+package test
+
+import (
+	"awesomeProject/internal/api"
+	"awesomeProject/internal/model"
+	"awesomeProject/internal/repository"
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAudit_Diff(t *testing.T) {
+	before := piiOrder()
+	if d, err := model.Diff(before, before); err != nil || len(d) != 0 {
+		t.Fatalf("одинаковые заказы: %+v, %v", d, err)
+	}
+
+	after := piiOrder()
+	after.Payment.Amount = 1817
+	after.Delivery.Phone = "+9721111111"
+	after.Items[0].Price = 453
+	after.Items = append(after.Items, model.Item{ChrtID: 2, Name: "Lipstick"})
+	diff, err := model.Diff(before, after)
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	var paths []string
+	for _, c := range diff {
+		paths = append(paths, c.Path)
+	}
+	want := []string{"delivery.phone", "items[0].price", "items[1]", "payment.amount"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Fatalf("пути изменений: %v, ожидали %v", paths, want)
+	}
+	if phone := diff[0]; phone.Old != "+97*****000" || phone.New != "+97*****111" {
+		t.Fatalf("телефон в diff должен быть замаскирован: %+v", phone)
+	}
+	if added := diff[2]; added.Old != nil || added.New == nil {
+		t.Fatalf("добавленный товар: %+v", added)
+	}
+	if amount := diff[3]; amount.Old != json.Number("0") || amount.New != json.Number("1817") {
+		t.Fatalf("сумма: %+v", amount)
+	}
+}
+
+func TestAPI_OrderHistory(t *testing.T) {
+	before, after := piiOrder(), piiOrder()
+	after.Payment.Amount = 10
+	records := []model.AuditRecord{
+		{ID: 1, OrderUID: "o1", Action: model.AuditCreated, Source: "kafka:orders/0@3", After: &before},
+		{ID: 2, OrderUID: "o1", Action: model.AuditUpdated, Source: "http:sub:alice", Before: &before, After: &after,
+			Diff: []model.FieldChange{{Path: "payment.amount", Old: 0, New: 10}}},
+	}
+	svc := &stubService{auditFn: func(ctx context.Context, id string) ([]model.AuditRecord, error) {
+		if id != "o1" {
+			return nil, repository.ErrNotFound
+		}
+		return records, nil
+	}}
+	get := func(h http.Handler, path string) (int, []model.AuditRecord) {
+		t.Helper()
+		rec := serve(h, http.MethodGet, path, "")
+		var resp struct {
+			OrderUID string              `json:"order_uid"`
+			History  []model.AuditRecord `json:"history"`
+		}
+		_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp.History
+	}
+
+	code, got := get(api.NewRouter(svc, api.Config{}), "/api/v1/orders/o1/history")
+	if code != http.StatusOK || len(got) != 2 || got[1].Source != "http:sub:alice" || got[1].Before.Delivery.Phone != "+9720000000" {
+		t.Fatalf("история: код %d, %+v", code, got)
+	}
+	if code, _ := get(api.NewRouter(svc, api.Config{}), "/api/v1/orders/missing/history"); code != http.StatusNotFound {
+		t.Fatalf("нет истории: ожидали 404, получили %d", code)
+	}
+	masked := api.NewRouter(svc, api.Config{PII: api.PIIPolicy{MaskAnonymous: true}})
+	if _, got := get(masked, "/api/v1/orders/o1/history"); got[0].After.Delivery.Phone != "+97*****000" ||
+		got[1].Before.Delivery.Phone != "+97*****000" {
+		t.Fatalf("снимки в истории должны маскироваться по политике PII: %+v", got)
+	}
+	if records[0].After.Delivery.Phone != "+9720000000" {
+		t.Fatal("маскировка не должна менять данные сервиса")
+	}
+	if rec := serve(masked, http.MethodDelete, "/api/v1/orders/o1/history", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("DELETE history: код %d", rec.Code)
+	}
+}
+
+func TestAPI_WritesCarrySource(t *testing.T) {
+	var sources []string
+	svc := &stubService{
+		replaceFn: func(ctx context.Context, o model.Order) (bool, error) {
+			sources = append(sources, repository.SourceFrom(ctx))
+			return false, nil
+		},
+		deleteFn: func(ctx context.Context, id string) error {
+			sources = append(sources, repository.SourceFrom(ctx))
+			return nil
+		},
+	}
+	h := api.NewRouter(svc, api.Config{})
+	serve(h, http.MethodPut, "/api/v1/orders/o1", `{"order_uid":"o1"}`)
+	serve(h, http.MethodDelete, "/api/v1/orders/o1", "")
+	if strings.Join(sources, ",") != "http:ip:192.0.2.1,http:ip:192.0.2.1" {
+		t.Fatalf("источник изменений из API: %v", sources)
+	}
+	if repository.SourceFrom(context.Background()) != "system" {
+		t.Fatal("без источника изменение должно записываться от имени system")
+	}
+}
@@ -0,0 +1,234 @@
+package test
+
+import (
+	"awesomeProject/internal/api"
+	"awesomeProject/internal/auth"
+	"awesomeProject/internal/model"
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func b64(v any) string {
+	data, _ := json.Marshal(v)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func signHS256(secret string, claims map[string]any) string {
+	signed := b64(map[string]string{"alg": "HS256", "typ": "JWT"}) + "." + b64(claims)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func signRS256(key *rsa.PrivateKey, kid string, claims map[string]any) string {
+	signed := b64(map[string]string{"alg": "RS256", "kid": kid}) + "." + b64(claims)
+	sum := sha256.Sum256([]byte(signed))
+	sig, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func bearer(token string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func TestAuth_APIKeys(t *testing.T) {
+	keys, err := auth.ParseAPIKeys("k-read:dashboard:reader, k-ops:ops:admin")
+	if err != nil {
+		t.Fatalf("ParseAPIKeys: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if _, err := keys.Authenticate(req); !errors.Is(err, auth.ErrNoCredentials) {
+		t.Fatalf("без ключа ожидали ErrNoCredentials, получили %v", err)
+	}
+	req.Header.Set(auth.HeaderAPIKey, "k-ops")
+	id, err := keys.Authenticate(req)
+	if err != nil || id.Subject != "ops" || id.Role != auth.RoleAdmin || id.Method != "api_key" {
+		t.Fatalf("неверная личность: %+v, %v", id, err)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "ApiKey k-read")
+	if id, err := keys.Authenticate(req); err != nil || id.Role != auth.RoleReader {
+		t.Fatalf("Authorization: ApiKey: %+v, %v", id, err)
+	}
+	req.Header.Set("Authorization", "ApiKey nope")
+	if _, err := keys.Authenticate(req); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Fatalf("неизвестный ключ: ожидали ErrInvalidToken, получили %v", err)
+	}
+	for _, bad := range []string{"onlykey", "k::reader", "k:svc:root"} {
+		if _, err := auth.ParseAPIKeys(bad); err == nil {
+			t.Fatalf("%q: ожидали ошибку разбора", bad)
+		}
+	}
+}
+
+func TestAuth_JWT_HMAC(t *testing.T) {
+	v, err := auth.NewJWTVerifier(auth.JWTConfig{HMACSecret: []byte("s3cret"), Issuer: "idp", Audience: "orders"})
+	if err != nil {
+		t.Fatalf("NewJWTVerifier: %v", err)
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+	valid := map[string]any{"sub": "alice", "iss": "idp", "aud": []string{"orders", "other"}, "exp": exp,
+		"role": []string{"reader", "writer"}, "scope": "orders pii"}
+	id, err := v.Authenticate(bearer(signHS256("s3cret", valid)))
+	if err != nil || id.Subject != "alice" || id.Role != auth.RoleWriter || id.Method != "jwt" || !id.HasScope("pii") {
+		t.Fatalf("валидный токен: %+v, %v", id, err)
+	}
+
+	with := func(k string, val any) map[string]any {
+		c := map[string]any{}
+		for kk, vv := range valid {
+			c[kk] = vv
+		}
+		if val == nil {
+			delete(c, k)
+		} else {
+			c[k] = val
+		}
+		return c
+	}
+	bad := map[string]string{
+		"чужой секрет":   signHS256("other", valid),
+		"истёк":          signHS256("s3cret", with("exp", time.Now().Add(-time.Hour).Unix())),
+		"без exp":        signHS256("s3cret", with("exp", nil)),
+		"ещё не активен": signHS256("s3cret", with("nbf", time.Now().Add(time.Hour).Unix())),
+		"чужой iss":      signHS256("s3cret", with("iss", "evil")),
+		"чужой aud":      signHS256("s3cret", with("aud", "billing")),
+		"alg none":       b64(map[string]string{"alg": "none"}) + "." + b64(valid) + ".",
+		"мусор":          "a.b",
+	}
+	for name, token := range bad {
+		if _, err := v.Authenticate(bearer(token)); !errors.Is(err, auth.ErrInvalidToken) {
+			t.Fatalf("%s: ожидали ErrInvalidToken, получили %v", name, err)
+		}
+	}
+}
+
+func TestAuth_JWT_JWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := fmt.Sprintf(`{"keys":[{"kty":"RSA","kid":"k1","n":%q,"e":%q},{"kty":"oct","kid":"h1","k":%q}]}`,
+		base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		base64.RawURLEncoding.EncodeToString([]byte("hmac-from-jwks")))
+	set, err := auth.ParseJWKS([]byte(jwks))
+	if err != nil {
+		t.Fatalf("ParseJWKS: %v", err)
+	}
+	v, _ := auth.NewJWTVerifier(auth.JWTConfig{JWKS: set, RoleClaim: "roles"})
+	claims := map[string]any{"sub": "svc", "exp": time.Now().Add(time.Minute).Unix(), "roles": "admin"}
+	id, err := v.Authenticate(bearer(signRS256(key, "k1", claims)))
+	if err != nil || id.Role != auth.RoleAdmin {
+		t.Fatalf("RS256: %+v, %v", id, err)
+	}
+	if _, err := v.Authenticate(bearer(signRS256(key, "k2", claims))); err == nil {
+		t.Fatal("неизвестный kid должен отклоняться")
+	}
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	if _, err := v.Authenticate(bearer(signRS256(other, "k1", claims))); err == nil {
+		t.Fatal("подпись чужим ключом должна отклоняться")
+	}
+}
+
+func TestAPI_Authorization(t *testing.T) {
+	keys, _ := auth.ParseAPIKeys("r:dash:reader,w:ingest:writer,a:ops:admin")
+	var seen auth.Identity
+	svc := &stubService{deleteFn: func(ctx context.Context, id string) error {
+		seen, _ = auth.FromContext(ctx)
+		return nil
+	}}
+	h := api.NewRouter(svc, api.Config{Auth: auth.Chain(keys)})
+	cases := []struct {
+		key, method, path, body string
+		want                    int
+	}{
+		{"", http.MethodGet, "/api/v1/orders/x", "", http.StatusUnauthorized},
+		{"bogus", http.MethodGet, "/api/v1/orders/x", "", http.StatusUnauthorized},
+		{"r", http.MethodGet, "/api/v1/orders/x", "", http.StatusOK},
+		{"r", http.MethodGet, "/order/x", "", http.StatusOK},
+		{"r", http.MethodPost, "/api/v1/orders", `{"order_uid":"a"}`, http.StatusForbidden},
+		{"w", http.MethodPost, "/api/v1/orders", `{"order_uid":"a"}`, http.StatusCreated},
+		{"w", http.MethodPost, "/api/v1/orders/bulk", `[{"order_uid":"a"}]`, http.StatusOK},
+		{"w", http.MethodDelete, "/api/v1/orders/x", "", http.StatusForbidden},
+		{"a", http.MethodDelete, "/api/v1/orders/x", "", http.StatusNoContent},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		if tc.key != "" {
+			req.Header.Set(auth.HeaderAPIKey, tc.key)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Fatalf("%s %s с ключом %q: ожидали %d, получили %d (%s)", tc.method, tc.path, tc.key, tc.want, rec.Code, rec.Body)
+		}
+		if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+			t.Fatal("в ответе 401 нет WWW-Authenticate")
+		}
+	}
+	if seen.Subject != "ops" || seen.Role != auth.RoleAdmin {
+		t.Fatalf("личность не дошла до сервиса: %+v", seen)
+	}
+}
+
+func TestAPI_IdempotencyKeyScopedBySubject(t *testing.T) {
+	keys, _ := auth.ParseAPIKeys("w1:ingest:writer,w2:billing:writer")
+	calls := 0
+	svc := &stubService{upsertFn: func(ctx context.Context, o model.Order) (bool, error) {
+		calls++
+		return true, nil
+	}}
+	h := api.NewRouter(svc, api.Config{Auth: auth.Chain(keys), Idempotency: api.NewMemoryIdempotencyStore()})
+	post := func(apiKey, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/orders", strings.NewReader(body))
+		req.Header.Set(auth.HeaderAPIKey, apiKey)
+		req.Header.Set("Idempotency-Key", "same")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := post("w1", `{"order_uid":"s1"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("ожидали 201, получили %d", rec.Code)
+	}
+	rec := post("w2", `{"order_uid":"s2"}`)
+	if rec.Code != http.StatusCreated || rec.Header().Get("Idempotent-Replayed") != "" || calls != 2 {
+		t.Fatalf("ключ другого субъекта не должен совпадать: %d calls=%d (%s)", rec.Code, calls, rec.Body)
+	}
+	if rec := post("w1", `{"order_uid":"s1"}`); rec.Header().Get("Idempotent-Replayed") != "true" || calls != 2 {
+		t.Fatalf("повтор того же субъекта должен вернуть сохранённый ответ: calls=%d", calls)
+	}
+}
+
+func TestAuth_LogHandlerAddsIdentity(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(auth.LogHandler(slog.NewTextHandler(&buf, nil))).With(slog.String("component", "test"))
+	ctx := auth.WithIdentity(context.Background(), auth.Identity{Subject: "alice", Role: auth.RoleWriter})
+	logger.InfoContext(ctx, "order deleted")
+	logger.Info("no identity")
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if !strings.Contains(lines[0], "auth_subject=alice") || !strings.Contains(lines[0], "auth_role=writer") {
+		t.Fatalf("в записи нет личности: %s", lines[0])
+	}
+	if strings.Contains(lines[1], "auth_subject") {
+		t.Fatalf("личность без контекста: %s", lines[1])
+	}
+}
@@ -0,0 +1,207 @@
+package test
+
+import (
+	"awesomeProject/internal/generator"
+	"awesomeProject/internal/model"
+	"awesomeProject/kafka"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+func message(value []byte, headers ...string) kafkago.Message {
+	m := kafkago.Message{Value: value}
+	for i := 0; i+1 < len(headers); i += 2 {
+		m.Headers = append(m.Headers, kafkago.Header{Key: headers[i], Value: []byte(headers[i+1])})
+	}
+	return m
+}
+
+func sameOrder(t *testing.T, got, want model.Order) {
+	t.Helper()
+	got.DateCreated, want.DateCreated = got.DateCreated.UTC(), want.DateCreated.UTC()
+	g, _ := json.Marshal(got)
+	w, _ := json.Marshal(want)
+	if string(g) != string(w) {
+		t.Fatalf("заказ изменился при декодировании:\nwant %s\ngot  %s", w, g)
+	}
+}
+
+func TestDecoders_CurrentVersionRoundTrip(t *testing.T) {
+	reg := kafka.NewRegistry()
+	dec, err := kafka.NewDecoders(reg, kafka.FormatJSON)
+	if err != nil {
+		t.Fatalf("NewDecoders: %v", err)
+	}
+	exp := generator.New(45).Order()
+
+	data, _ := json.Marshal(exp)
+	got, err := dec.Decode(message(data))
+	if err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	sameOrder(t, got, exp)
+
+	pb, err := kafka.EncodeProtobuf(exp)
+	if err != nil {
+		t.Fatalf("EncodeProtobuf: %v", err)
+	}
+	got, err = dec.Decode(message(pb, kafka.HeaderContentType, "application/x-protobuf"))
+	if err != nil {
+		t.Fatalf("protobuf: %v", err)
+	}
+	sameOrder(t, got, exp)
+
+	avro, err := reg.EncodeAvro(2, exp)
+	if err != nil {
+		t.Fatalf("EncodeAvro: %v", err)
+	}
+	got, err = dec.Decode(message(avro, kafka.HeaderContentType, "avro"))
+	if err != nil {
+		t.Fatalf("avro: %v", err)
+	}
+	sameOrder(t, got, exp)
+
+	// Формат по умолчанию используется, когда заголовка нет.
+	avroDefault, _ := kafka.NewDecoders(reg, "avro")
+	if _, err := avroDefault.Decode(message(avro)); err != nil {
+		t.Fatalf("avro по умолчанию: %v", err)
+	}
+}
+
+func TestDecoders_UpcastVersion1(t *testing.T) {
+	reg := kafka.NewRegistry()
+	dec, _ := kafka.NewDecoders(reg, kafka.FormatJSON)
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	check := func(name string, got model.Order, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got.OrderUID != "v1" || !got.DateCreated.Equal(created) || len(got.Items) != 1 ||
+			got.Items[0].TotalPrice != 750 {
+			t.Fatalf("%s: версия 1 не поднята до текущей: %+v", name, got)
+		}
+	}
+
+	v1 := `{"order_uid":"v1","date_created":1709294400,"items":[{"price":1000,"sale":25}]}`
+	got, err := dec.Decode(message([]byte(v1), kafka.HeaderSchemaVersion, "1"))
+	check("json", got, err)
+
+	// Protobuf v1: поле 13 — int64 секунд; собрано вручную по wire-формату.
+	item := protoVarint(protoVarint(nil, 3, 1000), 6, 25)
+	pb := protoBytes(nil, 1, []byte("v1"))
+	pb = protoBytes(pb, 6, item)
+	pb = protoVarint(pb, 13, uint64(created.Unix()))
+	pb = protoBytes(pb, 99, []byte("неизвестное поле пропускается"))
+	got, err = dec.Decode(message(pb, kafka.HeaderContentType, "protobuf", kafka.HeaderSchemaVersion, "1"))
+	check("protobuf", got, err)
+
+	// Avro v1 со своей схемой в реестре: версию задаёт схема, а не заголовок.
+	if err := reg.Register(100, 1, []byte(`{"type":"record","name":"OrderV1","fields":[
+		{"name":"order_uid","type":"string"},
+		{"name":"date_created","type":"long"},
+		{"name":"items","type":{"type":"array","items":{"type":"record","name":"ItemV1","fields":[
+			{"name":"price","type":"long"},{"name":"sale","type":"long"}]}}},
+		{"name":"comment","type":["null","string"]}]}`)); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	av := binary.BigEndian.AppendUint32([]byte{0}, 100)
+	av = avroString(av, "v1")
+	av = binary.AppendVarint(av, created.Unix())
+	av = binary.AppendVarint(av, 1)
+	av = binary.AppendVarint(binary.AppendVarint(av, 1000), 25)
+	av = binary.AppendVarint(av, 0)
+	av = binary.AppendVarint(av, 0) // comment: null
+	got, err = dec.Decode(message(av, kafka.HeaderContentType, "avro"))
+	check("avro", got, err)
+}
+
+func TestDecoders_Rejects(t *testing.T) {
+	dec, _ := kafka.NewDecoders(nil, kafka.FormatJSON)
+	data, _ := json.Marshal(generator.New(46).Order())
+
+	if _, err := dec.Decode(message(data, kafka.HeaderSchemaVersion, "3")); !errors.Is(err, kafka.ErrUnsupportedVersion) {
+		t.Fatalf("версия новее текущей: ожидали ErrUnsupportedVersion, получили %v", err)
+	}
+	unknown := binary.BigEndian.AppendUint32([]byte{0}, 777)
+	if _, err := dec.Decode(message(unknown, kafka.HeaderContentType, "avro")); !errors.Is(err, kafka.ErrUnsupportedVersion) {
+		t.Fatalf("неизвестная схема: ожидали ErrUnsupportedVersion, получили %v", err)
+	}
+	if _, err := dec.Decode(message(data, kafka.HeaderContentType, "text/xml")); !errors.Is(err, kafka.ErrUnsupportedFormat) {
+		t.Fatalf("неизвестный формат: ожидали ErrUnsupportedFormat, получили %v", err)
+	}
+	_, err := dec.Decode(message([]byte{0x0a, 0x10, 'x'}, kafka.HeaderContentType, "protobuf"))
+	if err == nil || errors.Is(err, kafka.ErrUnsupportedVersion) {
+		t.Fatalf("обрезанный protobuf — ошибка разбора, получили %v", err)
+	}
+	if _, err := kafka.NewDecoders(nil, "yaml"); !errors.Is(err, kafka.ErrUnsupportedFormat) {
+		t.Fatalf("неизвестный формат по умолчанию: %v", err)
+	}
+}
+
+func TestAvro_UnionBranchByValueType(t *testing.T) {
+	reg := kafka.NewRegistry()
+	if err := reg.Register(101, kafka.SchemaVersion, []byte(`{"type":"record","name":"Order","fields":[
+		{"name":"order_uid","type":["null","long","string"]},
+		{"name":"sm_id","type":["null","string","long"]}]}`)); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	exp := model.Order{OrderUID: "u1", SmID: 99}
+	data, err := reg.EncodeAvro(101, exp)
+	if err != nil {
+		t.Fatalf("EncodeAvro: %v", err)
+	}
+	dec, _ := kafka.NewDecoders(reg, kafka.FormatJSON)
+	got, err := dec.Decode(message(data, kafka.HeaderContentType, "avro"))
+	if err != nil || got.OrderUID != exp.OrderUID || got.SmID != exp.SmID {
+		t.Fatalf("ветка union должна выбираться по типу значения: %+v %v", got, err)
+	}
+
+	if err := reg.Register(102, kafka.SchemaVersion, []byte(`{"type":"record","name":"Order","fields":[
+		{"name":"order_uid","type":["null","long"]}]}`)); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if _, err := reg.EncodeAvro(102, exp); err == nil {
+		t.Fatal("строка без подходящей ветки union должна давать ошибку")
+	}
+}
+
+func TestAvro_RejectsOversizedBlockCount(t *testing.T) {
+	reg := kafka.NewRegistry()
+	// Элементы null не занимают байтов: без проверки счётчика разбор крутился бы 2^40 раз.
+	if err := reg.Register(103, kafka.SchemaVersion, []byte(`{"type":"record","name":"Order","fields":[
+		{"name":"order_uid","type":"string"},
+		{"name":"items","type":{"type":"array","items":"null"}}]}`)); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	dec, _ := kafka.NewDecoders(reg, kafka.FormatJSON)
+	for name, block := range map[string][]int64{"count": {1 << 40}, "negative count": {-(1 << 40), 0}} {
+		av := binary.BigEndian.AppendUint32([]byte{0}, 103)
+		av = avroString(av, "x")
+		for _, n := range block {
+			av = binary.AppendVarint(av, n)
+		}
+		av = binary.AppendVarint(av, 0)
+		if _, err := dec.Decode(message(av, kafka.HeaderContentType, "avro")); err == nil {
+			t.Fatalf("%s: счётчик больше остатка данных должен отклоняться", name)
+		}
+	}
+}
+
+func protoVarint(buf []byte, num int, v uint64) []byte {
+	return binary.AppendUvarint(binary.AppendUvarint(buf, uint64(num)<<3), v)
+}
+
+func protoBytes(buf []byte, num int, data []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(num)<<3|2)
+	return append(binary.AppendUvarint(buf, uint64(len(data))), data...)
+}
+
+func avroString(buf []byte, s string) []byte {
+	return append(binary.AppendVarint(buf, int64(len(s))), s...)
+}
@@ -0,0 +1,106 @@
+package test
+
+import (
+	"awesomeProject/internal/envelope"
+	"awesomeProject/internal/model"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testKeyring(t *testing.T, primary string, ids ...string) *envelope.Keyring {
+	t.Helper()
+	keys := make(map[string][]byte, len(ids))
+	for i, id := range ids {
+		keys[id] = bytes.Repeat([]byte{byte(i + 1)}, 32)
+	}
+	k, err := envelope.NewKeyring(primary, keys)
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	return k
+}
+
+func TestEnvelope_RoundTripAndRotation(t *testing.T) {
+	old := testKeyring(t, "k1", "k1")
+	ct, err := old.Encrypt("+9720000000")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if !strings.HasPrefix(ct, "enc:v1:k1:") || strings.Contains(ct, "9720000000") {
+		t.Fatalf("неожиданный шифротекст: %s", ct)
+	}
+	if again, _ := old.Encrypt("+9720000000"); again == ct {
+		t.Fatal("шифрование должно быть недетерминированным")
+	}
+	if pt, err := old.Decrypt(ct); err != nil || pt != "+9720000000" {
+		t.Fatalf("Decrypt: %q, %v", pt, err)
+	}
+	if pt, err := old.Decrypt("plain text"); err != nil || pt != "plain text" {
+		t.Fatalf("открытый текст должен читаться как есть: %q, %v", pt, err)
+	}
+
+	rotated := testKeyring(t, "k2", "k1", "k2")
+	if !rotated.Stale(ct) || !rotated.Stale("plain") || rotated.Stale("") {
+		t.Fatal("Stale: шифротекст старым ключом и открытый текст устарели, пустая строка — нет")
+	}
+	fresh, changed, err := rotated.Reseal(ct)
+	if err != nil || !changed || !strings.HasPrefix(fresh, "enc:v1:k2:") {
+		t.Fatalf("Reseal: %q, %v, %v", fresh, changed, err)
+	}
+	if _, changed, _ := rotated.Reseal(fresh); changed {
+		t.Fatal("актуальное значение не должно перешифровываться")
+	}
+	if _, err := old.Decrypt(fresh); !errors.Is(err, envelope.ErrUnknownKey) {
+		t.Fatalf("без ключа k2 ожидали ErrUnknownKey, получили %v", err)
+	}
+	tampered := fresh[:len(fresh)-2] + "AA"
+	if _, err := rotated.Decrypt(tampered); !errors.Is(err, envelope.ErrCorrupt) {
+		t.Fatalf("изменённый шифротекст: ожидали ErrCorrupt, получили %v", err)
+	}
+}
+
+func TestEnvelope_SealOpenDelivery(t *testing.T) {
+	k := testKeyring(t, "k1", "k1")
+	d := piiOrder().Delivery
+	sealed, err := envelope.Seal(k, d)
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if sealed.City != d.City || sealed.Zip != d.Zip || sealed.Phone == d.Phone || sealed.Email == d.Email ||
+		sealed.Name == d.Name || sealed.Address == d.Address {
+		t.Fatalf("шифруются только поля с тегом pii: %+v", sealed)
+	}
+	if !envelope.HasStale(testKeyring(t, "k2", "k1", "k2"), sealed) || envelope.HasStale(k, sealed) {
+		t.Fatal("HasStale неверно определяет устаревшие поля")
+	}
+	opened, err := envelope.Open(k, sealed)
+	if err != nil || opened != d {
+		t.Fatalf("Open: %+v, %v", opened, err)
+	}
+	if same, _ := envelope.Seal[model.Delivery](nil, d); same != d {
+		t.Fatal("без ключей Seal не должен менять значение")
+	}
+}
+
+func TestEnvelope_FromEnv(t *testing.T) {
+	t.Setenv("ENCRYPTION_KEY_FILE", "")
+	t.Setenv("ENCRYPTION_KEYS", "")
+	if k, err := envelope.FromEnv(); k != nil || err != nil {
+		t.Fatalf("без настроек шифрование выключено: %v, %v", k, err)
+	}
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32))
+	t.Setenv("ENCRYPTION_KEYS", "old="+key+",new="+key)
+	t.Setenv("ENCRYPTION_PRIMARY_KEY", "new")
+	k, err := envelope.FromEnv()
+	if err != nil || k.Primary() != "new" {
+		t.Fatalf("FromEnv: %v, %v", k, err)
+	}
+	t.Setenv("ENCRYPTION_KEYS", "short="+base64.StdEncoding.EncodeToString([]byte("x")))
+	t.Setenv("ENCRYPTION_PRIMARY_KEY", "")
+	if _, err := envelope.FromEnv(); err == nil {
+		t.Fatal("ключ не той длины должен отклоняться")
+	}
+}
@@ -0,0 +1,55 @@
+package test
+
+import (
+	"awesomeProject/internal/generator"
+	"reflect"
+	"testing"
+)
+
+func TestGenerator_SameSeedSameOrders(t *testing.T) {
+	a := generator.New(42).Orders(20)
+	b := generator.New(42).Orders(20)
+	if !reflect.DeepEqual(a, b) {
+		t.Fatalf("одинаковый seed должен давать одинаковые заказы")
+	}
+	c := generator.New(43).Orders(20)
+	if reflect.DeepEqual(a, c) {
+		t.Fatalf("разные seed должны давать разные заказы")
+	}
+}
+
+func TestGenerator_OrdersAreConsistent(t *testing.T) {
+	for _, o := range generator.New(7).Orders(200) {
+		if o.OrderUID == "" || o.Payment.Transaction != o.OrderUID {
+			t.Fatalf("%s: transaction должен совпадать с order_uid", o.OrderUID)
+		}
+		if len(o.Items) == 0 {
+			t.Fatalf("%s: заказ без товаров", o.OrderUID)
+		}
+		goods := 0
+		seen := map[int]bool{}
+		for _, it := range o.Items {
+			if it.TrackNumber != o.TrackNumber {
+				t.Fatalf("%s: track_number товара %q != %q", o.OrderUID, it.TrackNumber, o.TrackNumber)
+			}
+			if want := it.Price * (100 - it.Sale) / 100; it.TotalPrice != want {
+				t.Fatalf("%s: total_price %d, ожидали %d", o.OrderUID, it.TotalPrice, want)
+			}
+			if seen[it.ChrtID] {
+				t.Fatalf("%s: повторный chrt_id %d", o.OrderUID, it.ChrtID)
+			}
+			seen[it.ChrtID] = true
+			goods += it.TotalPrice
+		}
+		p := o.Payment
+		if p.GoodsTotal != goods {
+			t.Fatalf("%s: goods_total %d, сумма товаров %d", o.OrderUID, p.GoodsTotal, goods)
+		}
+		if p.Amount != p.GoodsTotal+p.DeliveryCost+p.CustomFee {
+			t.Fatalf("%s: amount %d не равен goods_total+delivery_cost+custom_fee", o.OrderUID, p.Amount)
+		}
+		if p.Currency == "" || o.Locale == "" {
+			t.Fatalf("%s: пустая валюта или локаль", o.OrderUID)
+		}
+	}
+}
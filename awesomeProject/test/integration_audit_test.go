@@ -0,0 +1,76 @@
+//go:build integration
+
+package test
+
+import (
+	"awesomeProject/internal/generator"
+	"awesomeProject/internal/model"
+	"awesomeProject/internal/repository"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestIntegration_Repository_AuditLog(t *testing.T) {
+	db := openTestDB(t)
+	repo := repository.NewRepository(db).WithKeyring(testKeyring(t, "k1", "k1"))
+	o := generator.New(47).Order()
+
+	fromKafka := repository.WithSource(context.Background(), "kafka:orders/0@7")
+	if _, err := repo.InsertOrder(fromKafka, o); err != nil {
+		t.Fatalf("InsertOrder: %v", err)
+	}
+	if _, err := repo.InsertOrder(fromKafka, o); err != nil {
+		t.Fatalf("повторный InsertOrder: %v", err)
+	}
+	changed := o
+	changed.Payment.Amount++
+	if _, err := repo.ReplaceOrder(repository.WithSource(context.Background(), "http:sub:alice"), changed); err != nil {
+		t.Fatalf("ReplaceOrder: %v", err)
+	}
+	if err := repo.DeleteOrder(context.Background(), o.OrderUID); err != nil {
+		t.Fatalf("DeleteOrder: %v", err)
+	}
+
+	var raw string
+	if err := db.QueryRow("SELECT after::text FROM audit_log WHERE order_uid=$1 ORDER BY id LIMIT 1", o.OrderUID).Scan(&raw); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(raw, o.Delivery.Phone) || !strings.Contains(raw, "enc:v1:k1:") {
+		t.Fatalf("доставка в журнале должна храниться зашифрованной: %s", raw)
+	}
+
+	check := func(repo *repository.Repository) {
+		t.Helper()
+		list, err := repo.OrderHistory(context.Background(), o.OrderUID)
+		if err != nil || len(list) != 3 {
+			t.Fatalf("OrderHistory: %d записей, %v", len(list), err)
+		}
+		created, updated, deleted := list[0], list[1], list[2]
+		if created.Action != model.AuditCreated || created.Source != "kafka:orders/0@7" || created.Before != nil ||
+			created.After.Delivery.Phone != o.Delivery.Phone {
+			t.Fatalf("создание: %+v", created)
+		}
+		if updated.Action != model.AuditUpdated || updated.Source != "http:sub:alice" ||
+			len(updated.Diff) != 1 || updated.Diff[0].Path != "payment.amount" ||
+			updated.Before.Payment.Amount != o.Payment.Amount || updated.After.Payment.Amount != changed.Payment.Amount {
+			t.Fatalf("изменение: %+v", updated)
+		}
+		if deleted.Action != model.AuditDeleted || deleted.Source != "system" || deleted.After != nil ||
+			deleted.Before.Delivery.Phone != o.Delivery.Phone {
+			t.Fatalf("удаление: %+v", deleted)
+		}
+	}
+	check(repo)
+
+	rotated := repository.NewRepository(db).WithKeyring(testKeyring(t, "k2", "k1", "k2"))
+	if n, err := rotated.ReencryptAudit(context.Background(), 2); err != nil || n != 3 {
+		t.Fatalf("ReencryptAudit: %d, %v", n, err)
+	}
+	check(repository.NewRepository(db).WithKeyring(testKeyring(t, "k2", "k2")))
+
+	if _, err := repo.OrderHistory(context.Background(), "missing"); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("нет истории: ожидали ErrNotFound, получили %v", err)
+	}
+}
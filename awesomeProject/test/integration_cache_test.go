@@ -0,0 +1,76 @@
+//go:build integration
+
+package test
+
+import (
+	"awesomeProject/internal/cache"
+	"awesomeProject/internal/generator"
+	"awesomeProject/internal/model"
+	"reflect"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestIntegration_Cache_SharedThroughRedis(t *testing.T) {
+	mr := miniredis.RunT(t)
+	t.Setenv("REDIS_ADDR", mr.Addr())
+
+	writer, err := cache.NewCache(quietLogger())
+	if err != nil {
+		t.Fatalf("NewCache: %v", err)
+	}
+	exp := generator.New(21).Order()
+	writer.Set(exp)
+	if !mr.Exists(exp.OrderUID) {
+		t.Fatalf("заказ должен быть записан в redis")
+	}
+
+	reader, err := cache.NewCache(quietLogger())
+	if err != nil {
+		t.Fatalf("NewCache: %v", err)
+	}
+	got, ok := reader.Get(exp.OrderUID)
+	if !ok {
+		t.Fatalf("второй экземпляр кэша должен найти заказ в redis")
+	}
+	if !reflect.DeepEqual(normalizeOrder(got), normalizeOrder(exp)) {
+		t.Fatalf("want %+v, got %+v", exp, got)
+	}
+}
+
+func TestIntegration_Cache_FallsBackToMemoryWhenRedisDown(t *testing.T) {
+	mr := miniredis.RunT(t)
+	t.Setenv("REDIS_ADDR", mr.Addr())
+	c, err := cache.NewCache(quietLogger())
+	if err != nil {
+		t.Fatalf("NewCache: %v", err)
+	}
+	mr.Close()
+
+	exp := generator.New(22).Order()
+	c.Set(exp)
+	if _, ok := c.Get(exp.OrderUID); !ok {
+		t.Fatalf("при упавшем redis заказ должен отдаваться из памяти")
+	}
+	if _, ok := c.Get("missing"); ok {
+		t.Fatalf("неизвестный заказ не должен находиться")
+	}
+}
+
+func TestIntegration_Cache_StartsWithoutRedis(t *testing.T) {
+	mr := miniredis.RunT(t)
+	addr := mr.Addr()
+	mr.Close()
+	t.Setenv("REDIS_ADDR", addr)
+
+	c, err := cache.NewCache(quietLogger())
+	if err != nil {
+		t.Fatalf("NewCache без redis не должен возвращать ошибку: %v", err)
+	}
+	exp := generator.New(23).Order()
+	c.BulkSet([]model.Order{exp})
+	if _, ok := c.Get(exp.OrderUID); !ok {
+		t.Fatalf("in-memory кэш должен работать без redis")
+	}
+}
@@ -0,0 +1,117 @@
+//go:build integration
+
+package test
+
+import (
+	"awesomeProject/internal/cache"
+	"awesomeProject/internal/generator"
+	"awesomeProject/internal/model"
+	"awesomeProject/internal/repository"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestIntegration_Repository_EncryptsDeliveryAtRest(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	plain := repository.NewRepository(db)
+	legacy := generator.New(31).Order()
+	if _, err := plain.InsertOrder(ctx, legacy); err != nil {
+		t.Fatalf("InsertOrder без шифрования: %v", err)
+	}
+
+	repo := repository.NewRepository(db).WithKeyring(testKeyring(t, "k1", "k1"))
+	exp := generator.New(32).Order()
+	if _, err := repo.InsertOrder(ctx, exp); err != nil {
+		t.Fatalf("InsertOrder: %v", err)
+	}
+	var phone, city string
+	if err := db.QueryRow("SELECT phone, city FROM delivery WHERE order_uid=$1", exp.OrderUID).Scan(&phone, &city); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(phone, "enc:v1:k1:") || city != exp.Delivery.City {
+		t.Fatalf("в БД должен быть шифротекст телефона и открытый город: %q, %q", phone, city)
+	}
+	for _, o := range []struct{ uid, phone string }{{exp.OrderUID, exp.Delivery.Phone}, {legacy.OrderUID, legacy.Delivery.Phone}} {
+		got, err := repo.GetOrderById(ctx, o.uid)
+		if err != nil || got.Delivery.Phone != o.phone {
+			t.Fatalf("GetOrderById(%s): %+v, %v", o.uid, got.Delivery, err)
+		}
+	}
+
+	rotated := repository.NewRepository(db).WithKeyring(testKeyring(t, "k2", "k1", "k2"))
+	n, err := rotated.ReencryptDeliveries(ctx, 1)
+	if err != nil || n != 2 {
+		t.Fatalf("ReencryptDeliveries: %d, %v", n, err)
+	}
+	if n, _ := rotated.ReencryptDeliveries(ctx, 1); n != 0 {
+		t.Fatalf("повторный проход ничего не должен менять, обновлено %d", n)
+	}
+	if err := db.QueryRow("SELECT phone FROM delivery WHERE order_uid=$1", legacy.OrderUID).Scan(&phone); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(phone, "enc:v1:k2:") {
+		t.Fatalf("после ротации ожидали ключ k2: %q", phone)
+	}
+	all, err := repository.NewRepository(db).WithKeyring(testKeyring(t, "k2", "k2")).LoadAll(ctx)
+	if err != nil || len(all) != 2 {
+		t.Fatalf("LoadAll только с новым ключом: %d, %v", len(all), err)
+	}
+}
+
+func TestIntegration_Repository_ReencryptRotatesEveryPIIField(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	exp := generator.New(34).Order()
+	if _, err := repository.NewRepository(db).WithKeyring(testKeyring(t, "k1", "k1")).InsertOrder(ctx, exp); err != nil {
+		t.Fatalf("InsertOrder: %v", err)
+	}
+	rotated := repository.NewRepository(db).WithKeyring(testKeyring(t, "k2", "k1", "k2"))
+	if n, err := rotated.ReencryptDeliveries(ctx, 10); err != nil || n != 1 {
+		t.Fatalf("ReencryptDeliveries: %d, %v", n, err)
+	}
+	// Колонки delivery названы так же, как JSON-поля model.Delivery.
+	typ := reflect.TypeOf(model.Delivery{})
+	for i := range typ.NumField() {
+		f := typ.Field(i)
+		col := strings.Split(f.Tag.Get("json"), ",")[0]
+		var v string
+		if err := db.QueryRow(`SELECT "`+col+`" FROM delivery WHERE order_uid=$1`, exp.OrderUID).Scan(&v); err != nil {
+			t.Fatalf("%s: %v", col, err)
+		}
+		if _, ok := f.Tag.Lookup("pii"); ok != strings.HasPrefix(v, "enc:v1:k2:") {
+			t.Fatalf("поле %s после ротации: %q (pii=%v)", col, v, ok)
+		}
+	}
+}
+
+func TestIntegration_Cache_EncryptsDeliveryInRedis(t *testing.T) {
+	mr := miniredis.RunT(t)
+	t.Setenv("REDIS_ADDR", mr.Addr())
+	k1 := testKeyring(t, "k1", "k1")
+	writer, _ := cache.NewCache(quietLogger())
+	writer.WithKeyring(k1)
+	exp := generator.New(33).Order()
+	writer.Set(exp)
+
+	raw, _ := mr.Get(exp.OrderUID)
+	if strings.Contains(raw, exp.Delivery.Phone) || !strings.Contains(raw, exp.Delivery.City) {
+		t.Fatalf("в redis телефон должен быть зашифрован: %s", raw)
+	}
+	reader, _ := cache.NewCache(quietLogger())
+	reader.WithKeyring(testKeyring(t, "k2", "k1", "k2"))
+	got, ok := reader.Get(exp.OrderUID)
+	if !ok || !reflect.DeepEqual(normalizeOrder(got), normalizeOrder(exp)) {
+		t.Fatalf("второй экземпляр должен расшифровать заказ: %v %+v", ok, got.Delivery)
+	}
+	if n, err := reader.Reencrypt(context.Background()); err != nil || n != 1 {
+		t.Fatalf("Reencrypt: %d, %v", n, err)
+	}
+	if raw, _ := mr.Get(exp.OrderUID); !strings.Contains(raw, "enc:v1:k2:") || mr.TTL(exp.OrderUID) <= 0 {
+		t.Fatalf("после Reencrypt ожидали ключ k2 и сохранённый TTL: %s", raw)
+	}
+}
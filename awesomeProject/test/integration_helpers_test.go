@@ -0,0 +1,175 @@
+//go:build integration
+
+package test
+
+import (
+	"awesomeProject/internal/model"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/segmentio/kafka-go"
+)
+
+func quietLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func pgDSN() string {
+	if v := os.Getenv("INTEGRATION_PG_DSN"); v != "" {
+		return v
+	}
+	return "host=localhost port=5433 user=postgres password=postgres dbname=postgres sslmode=disable"
+}
+
+// openTestDB создаёт отдельную схему под тест, применяет миграции и удаляет схему по завершении.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	admin, err := sql.Open("postgres", pgDSN())
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := admin.PingContext(ctx); err != nil {
+		_ = admin.Close()
+		t.Skipf("postgres недоступен (%v); запустите docker compose -f docker-compose.test.yml up -d", err)
+	}
+	schema := fmt.Sprintf("it_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		_ = admin.Close()
+	})
+
+	db, err := sql.Open("postgres", pgDSN()+" search_path="+schema)
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	files, err := filepath.Glob(filepath.Join("..", "migrations", "*.sql"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("миграции не найдены: %v", err)
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatalf("read %s: %v", f, err)
+		}
+		if _, err := db.Exec(string(data)); err != nil {
+			t.Fatalf("apply %s: %v", f, err)
+		}
+	}
+	return db
+}
+
+func normalizeOrder(o model.Order) model.Order {
+	o.DateCreated = o.DateCreated.UTC()
+	items := append([]model.Item(nil), o.Items...)
+	sort.Slice(items, func(i, j int) bool { return items[i].ChrtID < items[j].ChrtID })
+	o.Items = items
+	return o
+}
+
+// memKafka — in-process замена партиции Kafka с consumer group семантикой:
+// Fetch отдаёт сообщения после текущей позиции, Commit сдвигает сохранённый offset,
+// а reopen имитирует перезапуск потребителя с последнего закоммиченного offset.
+type memKafka struct {
+	mu        sync.Mutex
+	log       []kafka.Message
+	committed int64
+	notify    chan struct{}
+}
+
+func newMemKafka() *memKafka {
+	return &memKafka{notify: make(chan struct{})}
+}
+
+func (k *memKafka) produce(key string, value []byte, headers ...kafka.Header) {
+	k.mu.Lock()
+	k.log = append(k.log, kafka.Message{
+		Topic:     "orders",
+		Partition: 0,
+		Offset:    int64(len(k.log)),
+		Key:       []byte(key),
+		Value:     value,
+		Headers:   headers,
+	})
+	close(k.notify)
+	k.notify = make(chan struct{})
+	k.mu.Unlock()
+}
+
+func (k *memKafka) committedOffset() int64 {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.committed
+}
+
+func (k *memKafka) reopen() *memReader {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return &memReader{k: k, pos: k.committed}
+}
+
+type memReader struct {
+	k   *memKafka
+	pos int64
+}
+
+func (r *memReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	for {
+		r.k.mu.Lock()
+		if r.pos < int64(len(r.k.log)) {
+			m := r.k.log[r.pos]
+			r.pos++
+			r.k.mu.Unlock()
+			return m, nil
+		}
+		wait := r.k.notify
+		r.k.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return kafka.Message{}, ctx.Err()
+		case <-wait:
+		}
+	}
+}
+
+func (r *memReader) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	r.k.mu.Lock()
+	defer r.k.mu.Unlock()
+	for _, m := range msgs {
+		if m.Offset+1 > r.k.committed {
+			r.k.committed = m.Offset + 1
+		}
+	}
+	return nil
+}
+
+func (r *memReader) Close() error { return nil }
+
+func waitFor(t *testing.T, timeout time.Duration, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("условие не выполнилось за %s", timeout)
+}
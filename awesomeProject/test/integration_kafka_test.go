@@ -0,0 +1,277 @@
+//go:build integration
+
+package test
+
+import (
+	"awesomeProject/internal/generator"
+	"awesomeProject/internal/model"
+	"awesomeProject/internal/repository"
+	"awesomeProject/internal/service"
+	"awesomeProject/kafka"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+func runConsumer(t *testing.T, c *kafka.Consumer) (stop func()) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := c.Run(ctx); err != nil {
+			t.Errorf("consumer.Run: %v", err)
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func TestIntegration_Consumer_StoresAndCommits(t *testing.T) {
+	db := openTestDB(t)
+	repo := repository.NewRepository(db)
+	svc := service.NewService(repo, newMockCache(), quietLogger())
+	broker := newMemKafka()
+
+	orders := generator.New(31).Orders(3)
+	for _, o := range orders {
+		data, _ := json.Marshal(o)
+		broker.produce(o.OrderUID, data)
+	}
+	broker.produce("bad", []byte("{not json"))
+	broker.produce("empty", []byte(`{"track_number":"X"}`))
+
+	stop := runConsumer(t, kafka.NewConsumerWithReader(broker.reopen(), svc, quietLogger()))
+	waitFor(t, 5*time.Second, func() bool { return broker.committedOffset() == 5 })
+	stop()
+
+	for _, o := range orders {
+		if _, err := repo.GetOrderById(context.Background(), o.OrderUID); err != nil {
+			t.Fatalf("заказ %s должен быть сохранён: %v", o.OrderUID, err)
+		}
+	}
+}
+
+func TestIntegration_Consumer_RedeliversUncommittedAfterRestart(t *testing.T) {
+	db := openTestDB(t)
+	broker := newMemKafka()
+	exp := generator.New(32).Order()
+	data, _ := json.Marshal(exp)
+	broker.produce(exp.OrderUID, data)
+
+	attempted := make(chan struct{}, 1)
+	failing := &mockRepo{insertFn: func(ctx context.Context, o model.Order) error {
+		select {
+		case attempted <- struct{}{}:
+		default:
+		}
+		return errors.New("db is down")
+	}}
+	svc := service.NewService(failing, newMockCache(), quietLogger())
+	stop := runConsumer(t, kafka.NewConsumerWithReader(broker.reopen(), svc, quietLogger()))
+	select {
+	case <-attempted:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("потребитель не попытался записать заказ")
+	}
+	stop()
+	if got := broker.committedOffset(); got != 0 {
+		t.Fatalf("offset не должен коммититься при ошибке записи, committed=%d", got)
+	}
+
+	repo := repository.NewRepository(db)
+	svc = service.NewService(repo, newMockCache(), quietLogger())
+	stop = runConsumer(t, kafka.NewConsumerWithReader(broker.reopen(), svc, quietLogger()))
+	waitFor(t, 5*time.Second, func() bool { return broker.committedOffset() == 1 })
+	stop()
+	if _, err := repo.GetOrderById(context.Background(), exp.OrderUID); err != nil {
+		t.Fatalf("после перезапуска сообщение должно быть доставлено повторно: %v", err)
+	}
+}
+
+func TestIntegration_Consumer_RetriesFailedWriteAndCommitsLaterOffsets(t *testing.T) {
+	broker := newMemKafka()
+	orders := generator.New(35).Orders(3)
+	for _, o := range orders {
+		data, _ := json.Marshal(o)
+		broker.produce(o.OrderUID, data)
+	}
+
+	var mu sync.Mutex
+	written := make(map[string]bool)
+	failed := false
+	repo := &mockRepo{insertFn: func(ctx context.Context, o model.Order) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if o.OrderUID == orders[0].OrderUID && !failed {
+			failed = true
+			return errors.New("db is down")
+		}
+		written[o.OrderUID] = true
+		return nil
+	}}
+	svc := service.NewService(repo, &lockedCache{mockCache: newMockCache()}, quietLogger())
+	stop := runConsumer(t, kafka.NewConsumerWithReader(broker.reopen(), svc, quietLogger()))
+	defer stop()
+
+	waitFor(t, 5*time.Second, func() bool { return broker.committedOffset() == 3 })
+	mu.Lock()
+	defer mu.Unlock()
+	if !failed || len(written) != 3 {
+		t.Fatalf("сообщение после ошибки записи должно быть обработано повторно: failed=%v written=%v", failed, written)
+	}
+}
+
+func TestIntegration_Consumer_MovesUnsupportedSchemaToDeadLetter(t *testing.T) {
+	broker := newMemKafka()
+	orders := generator.New(36).Orders(2)
+	future, _ := json.Marshal(orders[0])
+	broker.produce(orders[0].OrderUID, future, kafkago.Header{Key: kafka.HeaderSchemaVersion, Value: []byte("3")})
+	data, _ := json.Marshal(orders[1])
+	broker.produce(orders[1].OrderUID, data)
+
+	var mu sync.Mutex
+	var written []string
+	repo := &mockRepo{insertFn: func(ctx context.Context, o model.Order) error {
+		mu.Lock()
+		defer mu.Unlock()
+		written = append(written, o.OrderUID)
+		return nil
+	}}
+	dlq := &memPublisher{fails: 1}
+	svc := service.NewService(repo, newMockCache(), quietLogger())
+	stop := runConsumer(t, kafka.NewConsumerWithReader(broker.reopen(), svc, quietLogger()).WithDeadLetter(dlq))
+	defer stop()
+
+	waitFor(t, 5*time.Second, func() bool { return broker.committedOffset() == 2 })
+	sent := dlq.sent()
+	if len(sent) != 1 || string(sent[0].Key) != orders[0].OrderUID ||
+		header(sent[0], kafka.HeaderDeadLetterSource) != "orders/0@0" || header(sent[0], kafka.HeaderDeadLetterReason) == "" {
+		t.Fatalf("сообщение с неизвестной схемой должно попасть в DLQ с причиной: %+v", sent)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(written) != 1 || written[0] != orders[1].OrderUID {
+		t.Fatalf("следующее сообщение должно быть записано: %v", written)
+	}
+}
+
+func TestIntegration_Consumer_ShutdownDrainsInFlight(t *testing.T) {
+	broker := newMemKafka()
+	exp := generator.New(33).Order()
+	data, _ := json.Marshal(exp)
+	broker.produce(exp.OrderUID, data)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	slow := &mockRepo{insertFn: func(ctx context.Context, o model.Order) error {
+		close(started)
+		<-release
+		return ctx.Err()
+	}}
+	c := kafka.NewConsumerWithReader(broker.reopen(), service.NewService(slow, newMockCache(), quietLogger()), quietLogger())
+	stop := runConsumer(t, c)
+	defer stop()
+	<-started
+
+	done := make(chan error, 1)
+	go func() { done <- c.Shutdown(context.Background()) }()
+	select {
+	case err := <-done:
+		t.Fatalf("Shutdown вернулся до окончания записи: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if got := broker.committedOffset(); got != 1 {
+		t.Fatalf("offset дописанного сообщения должен быть закоммичен, committed=%d", got)
+	}
+	broker.produce("late", data)
+	time.Sleep(50 * time.Millisecond)
+	if got := broker.committedOffset(); got != 1 {
+		t.Fatalf("после Shutdown новые сообщения не читаются, committed=%d", got)
+	}
+}
+
+// lockedCache делает mockCache безопасным для воркеров потребителя.
+type lockedCache struct {
+	mu sync.Mutex
+	*mockCache
+}
+
+func (c *lockedCache) Get(id string) (model.Order, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.mockCache.Get(id)
+}
+
+func (c *lockedCache) Set(o model.Order) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.mockCache.Set(o)
+}
+
+func TestIntegration_Consumer_ConcurrentKeysContiguousCommit(t *testing.T) {
+	broker := newMemKafka()
+	orders := generator.New(34).Orders(3)
+	slowUID := orders[0].OrderUID
+	for _, o := range append(orders, orders[0]) {
+		data, _ := json.Marshal(o)
+		broker.produce(o.OrderUID, data)
+	}
+
+	var mu sync.Mutex
+	var written []string
+	release := make(chan struct{})
+	first := true
+	repo := &mockRepo{insertFn: func(ctx context.Context, o model.Order) error {
+		mu.Lock()
+		slow := o.OrderUID == slowUID && first
+		if slow {
+			first = false
+		}
+		mu.Unlock()
+		if slow {
+			<-release
+		}
+		mu.Lock()
+		written = append(written, o.OrderUID)
+		mu.Unlock()
+		return nil
+	}}
+	svc := service.NewService(repo, &lockedCache{mockCache: newMockCache()}, quietLogger())
+	c := kafka.NewConsumerWithReader(broker.reopen(), svc, quietLogger()).WithConcurrency(4, kafka.DispatchByKey)
+	stop := runConsumer(t, c)
+	defer stop()
+
+	waitFor(t, 5*time.Second, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(written) == 2
+	})
+	if got := broker.committedOffset(); got != 0 {
+		t.Fatalf("пока первое сообщение в работе, offset'ы после него не коммитятся, committed=%d", got)
+	}
+	close(release)
+	waitFor(t, 5*time.Second, func() bool { return broker.committedOffset() == 4 })
+
+	mu.Lock()
+	defer mu.Unlock()
+	var slowWrites []int
+	for i, uid := range written {
+		if uid == slowUID {
+			slowWrites = append(slowWrites, i)
+		}
+	}
+	if len(written) != 4 || len(slowWrites) != 2 || slowWrites[0] != 2 {
+		t.Fatalf("сообщения одного ключа обрабатываются по порядку, остальные — не дожидаясь: %v", written)
+	}
+}
@@ -0,0 +1,164 @@
+//go:build integration
+
+package test
+
+import (
+	"awesomeProject/internal/generator"
+	"awesomeProject/internal/model"
+	"awesomeProject/internal/repository"
+	"awesomeProject/internal/service"
+	"awesomeProject/kafka"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIntegration_Repository_OffsetStoredWithOrder(t *testing.T) {
+	repo := repository.NewRepository(openTestDB(t))
+	ctx := context.Background()
+	at := func(offset int64) context.Context {
+		return repository.WithConsumerOffset(ctx, repository.ConsumerOffset{
+			Group: "g", Topic: "orders", Partition: 2, Offset: offset,
+		})
+	}
+	orders := generator.New(51).Orders(3)
+	if _, err := repo.InsertOrder(at(7), orders[0]); err != nil {
+		t.Fatalf("InsertOrder: %v", err)
+	}
+	if _, err := repo.InsertOrder(at(5), orders[1]); err != nil {
+		t.Fatalf("более ранний offset, ещё не применённый, должен записываться: %v", err)
+	}
+	if _, err := repo.InsertOrder(at(7), orders[2]); !errors.Is(err, repository.ErrAlreadyApplied) {
+		t.Fatalf("offset 7 уже применён, ожидали ErrAlreadyApplied, получили %v", err)
+	}
+	if err := repo.AdvanceConsumerOffset(ctx, "g", "orders", 2, 8); err != nil {
+		t.Fatalf("AdvanceConsumerOffset: %v", err)
+	}
+	if _, err := repo.InsertOrder(at(3), orders[2]); !errors.Is(err, repository.ErrAlreadyApplied) {
+		t.Fatalf("offset ниже позиции партиции, ожидали ErrAlreadyApplied, получили %v", err)
+	}
+	if _, err := repo.GetOrderById(ctx, orders[2].OrderUID); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("отклонённое сообщение не должно записывать заказ: %v", err)
+	}
+	got, err := repo.ConsumerOffsets(ctx, "g", "orders")
+	if err != nil || got[2] != 8 {
+		t.Fatalf("ConsumerOffsets: %v %v", got, err)
+	}
+}
+
+// failingRepo не записывает заказ fail при первой попытке.
+type failingRepo struct {
+	*repository.Repository
+	mu     sync.Mutex
+	fail   string
+	failed bool
+}
+
+func (r *failingRepo) InsertOrder(ctx context.Context, o model.Order) (bool, error) {
+	r.mu.Lock()
+	fail := o.OrderUID == r.fail && !r.failed
+	r.failed = r.failed || fail
+	r.mu.Unlock()
+	if fail {
+		return false, errors.New("db is down")
+	}
+	return r.Repository.InsertOrder(ctx, o)
+}
+
+func TestIntegration_Consumer_DBOffsetsWithKeyDispatch(t *testing.T) {
+	repo := repository.NewRepository(openTestDB(t))
+	ctx := context.Background()
+	broker := newMemKafka()
+	orders := generator.New(54).Orders(8)
+	for _, o := range orders {
+		data, _ := json.Marshal(o)
+		broker.produce(o.OrderUID, data)
+	}
+	failing := &failingRepo{Repository: repo, fail: orders[3].OrderUID}
+	svc := service.NewService(failing, &lockedCache{mockCache: newMockCache()}, quietLogger())
+	c := kafka.NewConsumerWithReader(broker.reopen(), svc, quietLogger()).
+		WithConcurrency(4, kafka.DispatchByKey).WithDBOffsets("g")
+	stop := runConsumer(t, c)
+	waitFor(t, 5*time.Second, func() bool {
+		if broker.committedOffset() < 3 {
+			return false
+		}
+		for i, o := range orders {
+			if _, err := repo.GetOrderById(ctx, o.OrderUID); i != 3 && err != nil {
+				return false
+			}
+		}
+		return true
+	})
+	stop()
+	if _, err := repo.GetOrderById(ctx, orders[3].OrderUID); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("заказ с упавшей записью не должен сохраниться: %v", err)
+	}
+	if got := broker.committedOffset(); got != 3 {
+		t.Fatalf("коммитится только префикс до упавшего сообщения, committed=%d", got)
+	}
+
+	cache := &lockedCache{mockCache: newMockCache()}
+	svc = service.NewService(repo, cache, quietLogger())
+	c = kafka.NewConsumerWithReader(broker.reopen(), svc, quietLogger()).
+		WithConcurrency(4, kafka.DispatchByKey).WithDBOffsets("g")
+	stop = runConsumer(t, c)
+	waitFor(t, 5*time.Second, func() bool { return broker.committedOffset() == 8 })
+	stop()
+	if _, err := repo.GetOrderById(ctx, orders[3].OrderUID); err != nil {
+		t.Fatalf("после перезапуска упавшее сообщение должно примениться: %v", err)
+	}
+	if cache.setCount != 1 {
+		t.Fatalf("применяться должно только упавшее сообщение, setCount=%d", cache.setCount)
+	}
+}
+
+func TestIntegration_Consumer_DBOffsetsSkipReplayAfterCrash(t *testing.T) {
+	db := openTestDB(t)
+	repo := repository.NewRepository(db)
+	broker := newMemKafka()
+	orders := generator.New(52).Orders(3)
+	for _, o := range orders {
+		data, _ := json.Marshal(o)
+		broker.produce(o.OrderUID, data)
+	}
+	svc := service.NewService(repo, newMockCache(), quietLogger())
+	stop := runConsumer(t, kafka.NewConsumerWithReader(broker.reopen(), svc, quietLogger()).WithDBOffsets("g"))
+	waitFor(t, 5*time.Second, func() bool { return broker.committedOffset() == 3 })
+	stop()
+
+	// Имитируем падение между записью в БД и коммитом в Kafka: коммит потерян.
+	broker.mu.Lock()
+	broker.committed = 0
+	broker.mu.Unlock()
+	cache := newMockCache()
+	svc = service.NewService(repo, cache, quietLogger())
+	stop = runConsumer(t, kafka.NewConsumerWithReader(broker.reopen(), svc, quietLogger()).WithDBOffsets("g"))
+	waitFor(t, 5*time.Second, func() bool { return broker.committedOffset() == 3 })
+	stop()
+	if cache.setCount != 0 {
+		t.Fatalf("повторно прочитанные сообщения не должны применяться, setCount=%d", cache.setCount)
+	}
+}
+
+func TestIntegration_Consumer_AlreadyAppliedIsCommitted(t *testing.T) {
+	broker := newMemKafka()
+	o := generator.New(53).Order()
+	data, _ := json.Marshal(o)
+	broker.produce(o.OrderUID, data)
+
+	repo := &mockRepo{insertFn: func(context.Context, model.Order) error {
+		return repository.ErrAlreadyApplied
+	}}
+	cache := newMockCache()
+	svc := service.NewService(repo, cache, quietLogger())
+	stop := runConsumer(t, kafka.NewConsumerWithReader(broker.reopen(), svc, quietLogger()).WithDBOffsets("g"))
+	waitFor(t, 5*time.Second, func() bool { return broker.committedOffset() == 1 })
+	stop()
+	if cache.setCount != 0 {
+		t.Fatalf("уже применённое сообщение коммитится без записи в кэш")
+	}
+}
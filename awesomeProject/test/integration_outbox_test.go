@@ -0,0 +1,111 @@
+//go:build integration
+
+package test
+
+import (
+	"awesomeProject/internal/generator"
+	"awesomeProject/internal/model"
+	"awesomeProject/internal/repository"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIntegration_Outbox_WrittenWithOrderChanges(t *testing.T) {
+	repo := repository.NewRepository(openTestDB(t))
+	ctx := context.Background()
+	o := generator.New(41).Order()
+	if created, err := repo.InsertOrder(ctx, o); err != nil || !created {
+		t.Fatalf("InsertOrder: created=%v err=%v", created, err)
+	}
+	if created, err := repo.InsertOrder(ctx, o); err != nil || created {
+		t.Fatalf("повторный InsertOrder: created=%v err=%v", created, err)
+	}
+	if _, err := repo.ReplaceOrder(ctx, o); err != nil {
+		t.Fatalf("ReplaceOrder: %v", err)
+	}
+	if err := repo.DeleteOrder(ctx, o.OrderUID); err != nil {
+		t.Fatalf("DeleteOrder: %v", err)
+	}
+
+	var got []model.OrderEvent
+	collect := func(ctx context.Context, batch []repository.OutboxRecord) error {
+		for _, r := range batch {
+			var ev model.OrderEvent
+			if err := json.Unmarshal(r.Payload, &ev); err != nil || ev.Type != r.EventType {
+				t.Fatalf("payload %s: %v", r.Payload, err)
+			}
+			got = append(got, ev)
+		}
+		return nil
+	}
+	if n, err := repo.RelayOutbox(ctx, 10, collect); err != nil || n != 3 {
+		t.Fatalf("RelayOutbox: n=%d err=%v", n, err)
+	}
+	want := []string{model.EventOrderCreated, model.EventOrderUpdated, model.EventOrderDeleted}
+	for i, ev := range got {
+		if ev.Type != want[i] || ev.OrderUID != o.OrderUID || (ev.Order == nil) != (i == 2) {
+			t.Fatalf("событие %d: %+v", i, ev)
+		}
+	}
+	if n, err := repo.RelayOutbox(ctx, 10, collect); err != nil || n != 0 {
+		t.Fatalf("опубликованные события не отдаются повторно: n=%d err=%v", n, err)
+	}
+	if n, err := repo.PurgeOutbox(ctx, time.Now().Add(time.Minute)); err != nil || n != 3 {
+		t.Fatalf("PurgeOutbox: n=%d err=%v", n, err)
+	}
+}
+
+func TestIntegration_Outbox_FailedPublishIsRetried(t *testing.T) {
+	repo := repository.NewRepository(openTestDB(t))
+	ctx := context.Background()
+	if _, err := repo.InsertOrder(ctx, generator.New(42).Order()); err != nil {
+		t.Fatalf("InsertOrder: %v", err)
+	}
+	boom := errors.New("kafka unavailable")
+	if _, err := repo.RelayOutbox(ctx, 10, func(context.Context, []repository.OutboxRecord) error { return boom }); !errors.Is(err, boom) {
+		t.Fatalf("ожидали ошибку публикации, получили %v", err)
+	}
+	n, err := repo.RelayOutbox(ctx, 10, func(context.Context, []repository.OutboxRecord) error { return nil })
+	if err != nil || n != 1 {
+		t.Fatalf("неопубликованное событие должно остаться в outbox: n=%d err=%v", n, err)
+	}
+}
+
+func TestIntegration_Outbox_PayloadIsNotEncrypted(t *testing.T) {
+	repo := repository.NewRepository(openTestDB(t)).WithKeyring(testKeyring(t, "k1", "k1"))
+	ctx := context.Background()
+	o := generator.New(43).Order()
+	if _, err := repo.InsertOrder(ctx, o); err != nil {
+		t.Fatalf("InsertOrder: %v", err)
+	}
+	if _, err := repo.UpdateOrder(ctx, o.OrderUID, func(cur model.Order) (model.Order, error) {
+		cur.Delivery.City = "Kazan"
+		return cur, nil
+	}); err != nil {
+		t.Fatalf("UpdateOrder: %v", err)
+	}
+	var got []model.OrderEvent
+	if _, err := repo.RelayOutbox(ctx, 10, func(_ context.Context, batch []repository.OutboxRecord) error {
+		for _, r := range batch {
+			if strings.Contains(string(r.Payload), "enc:v1:") {
+				t.Fatalf("в событии шифротекст хранилища: %s", r.Payload)
+			}
+			var ev model.OrderEvent
+			if err := json.Unmarshal(r.Payload, &ev); err != nil {
+				t.Fatalf("payload %s: %v", r.Payload, err)
+			}
+			got = append(got, ev)
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("RelayOutbox: %v", err)
+	}
+	if len(got) != 2 || got[0].Order.Delivery != o.Delivery || got[1].Order.Delivery.Phone != o.Delivery.Phone ||
+		got[1].Order.Delivery.City != "Kazan" {
+		t.Fatalf("в событиях ожидали доставку в открытом виде: %+v", got)
+	}
+}
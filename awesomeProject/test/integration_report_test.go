@@ -0,0 +1,44 @@
+//go:build integration
+
+package test
+
+import (
+	"awesomeProject/internal/generator"
+	"awesomeProject/internal/model"
+	"awesomeProject/internal/repository"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestIntegration_Repository_RevenueByCurrency(t *testing.T) {
+	repo := repository.NewRepository(openTestDB(t))
+	ctx := context.Background()
+	day := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	gen := generator.New(48)
+	insert := func(currency string, amount int, created time.Time) model.Order {
+		o := gen.Order()
+		o.Payment.Currency, o.Payment.Amount, o.DateCreated = currency, amount, created
+		if _, err := repo.InsertOrder(ctx, o); err != nil {
+			t.Fatalf("InsertOrder: %v", err)
+		}
+		return o
+	}
+	insert("RUB", 1000, day)
+	insert("RUB", 2500, day.Add(time.Hour))
+	insert("USD", 700, day)
+	insert("USD", 100, day.AddDate(0, 0, -5)) // вне периода
+	cancelled := insert("USD", 9000, day)
+	if _, err := repo.ChangeStatus(ctx, model.StatusChange{OrderUID: cancelled.OrderUID, To: model.StatusCancelled}); err != nil {
+		t.Fatalf("ChangeStatus: %v", err)
+	}
+
+	rows, err := repo.RevenueByCurrency(ctx, day.Add(-time.Hour), day.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("RevenueByCurrency: %v", err)
+	}
+	want := []model.CurrencyRevenue{{Currency: "RUB", Orders: 2, Amount: 3500}, {Currency: "USD", Orders: 1, Amount: 700}}
+	if len(rows) != len(want) || rows[0] != want[0] || rows[1] != want[1] {
+		t.Fatalf("выручка %+v, ожидали %+v", rows, want)
+	}
+}
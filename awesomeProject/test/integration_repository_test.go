@@ -0,0 +1,197 @@
+//go:build integration
+
+package test
+
+import (
+	"awesomeProject/internal/generator"
+	"awesomeProject/internal/repository"
+	"awesomeProject/internal/service"
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIntegration_Repository_InsertGetRoundTrip(t *testing.T) {
+	db := openTestDB(t)
+	repo := repository.NewRepository(db)
+	ctx := context.Background()
+	for _, exp := range generator.New(11).Orders(5) {
+		if _, err := repo.InsertOrder(ctx, exp); err != nil {
+			t.Fatalf("InsertOrder: %v", err)
+		}
+		got, err := repo.GetOrderById(ctx, exp.OrderUID)
+		if err != nil {
+			t.Fatalf("GetOrderById: %v", err)
+		}
+		if !reflect.DeepEqual(normalizeOrder(got), normalizeOrder(exp)) {
+			t.Fatalf("round-trip изменил заказ:\nwant %+v\ngot  %+v", exp, got)
+		}
+	}
+}
+
+func TestIntegration_Repository_NotFound(t *testing.T) {
+	repo := repository.NewRepository(openTestDB(t))
+	_, err := repo.GetOrderById(context.Background(), "missing")
+	if !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("ожидали ErrNotFound, получили %v", err)
+	}
+}
+
+func TestIntegration_Repository_LoadAll(t *testing.T) {
+	repo := repository.NewRepository(openTestDB(t))
+	ctx := context.Background()
+	src := generator.New(12).Orders(10)
+	for _, o := range src {
+		if _, err := repo.InsertOrder(ctx, o); err != nil {
+			t.Fatalf("InsertOrder: %v", err)
+		}
+	}
+	all, err := repo.LoadAll(ctx)
+	if err != nil {
+		t.Fatalf("LoadAll: %v", err)
+	}
+	if len(all) != len(src) {
+		t.Fatalf("ожидали %d заказов, получили %d", len(src), len(all))
+	}
+	byID := make(map[string]int, len(all))
+	for i, o := range all {
+		byID[o.OrderUID] = i
+	}
+	for _, exp := range src {
+		i, ok := byID[exp.OrderUID]
+		if !ok {
+			t.Fatalf("заказ %s не загружен", exp.OrderUID)
+		}
+		if !reflect.DeepEqual(normalizeOrder(all[i]), normalizeOrder(exp)) {
+			t.Fatalf("LoadAll вернул другой заказ %s", exp.OrderUID)
+		}
+	}
+}
+
+func TestIntegration_Service_WarmupFromPostgres(t *testing.T) {
+	repo := repository.NewRepository(openTestDB(t))
+	ctx := context.Background()
+	src := generator.New(13).Orders(3)
+	for _, o := range src {
+		if _, err := repo.InsertOrder(ctx, o); err != nil {
+			t.Fatalf("InsertOrder: %v", err)
+		}
+	}
+	cache := newMockCache()
+	svc := service.NewService(repo, cache, quietLogger())
+	if err := svc.Warmup(ctx); err != nil {
+		t.Fatalf("Warmup: %v", err)
+	}
+	for _, o := range src {
+		if _, ok := cache.mem[o.OrderUID]; !ok {
+			t.Fatalf("заказ %s должен оказаться в кэше", o.OrderUID)
+		}
+	}
+}
+
+func TestIntegration_Repository_ReplaceAndDelete(t *testing.T) {
+	repo := repository.NewRepository(openTestDB(t))
+	ctx := context.Background()
+	gen := generator.New(14)
+	order := gen.Order()
+
+	created, err := repo.ReplaceOrder(ctx, order)
+	if err != nil || !created {
+		t.Fatalf("первый ReplaceOrder должен создать заказ: created=%v err=%v", created, err)
+	}
+	order.Delivery.City = "Kazan"
+	order.Items = gen.Order().Items
+	for i := range order.Items {
+		order.Items[i].TrackNumber = order.TrackNumber
+	}
+	created, err = repo.ReplaceOrder(ctx, order)
+	if err != nil || created {
+		t.Fatalf("повторный ReplaceOrder должен обновить заказ: created=%v err=%v", created, err)
+	}
+	got, err := repo.GetOrderById(ctx, order.OrderUID)
+	if err != nil {
+		t.Fatalf("GetOrderById: %v", err)
+	}
+	if !reflect.DeepEqual(normalizeOrder(got), normalizeOrder(order)) {
+		t.Fatalf("заказ не перезаписан:\nwant %+v\ngot  %+v", order, got)
+	}
+
+	if err := repo.DeleteOrder(ctx, order.OrderUID); err != nil {
+		t.Fatalf("DeleteOrder: %v", err)
+	}
+	if err := repo.DeleteOrder(ctx, order.OrderUID); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("повторное удаление должно вернуть ErrNotFound, получили %v", err)
+	}
+}
+
+func TestIntegration_Repository_IdempotencyKeys(t *testing.T) {
+	repo := repository.NewRepository(openTestDB(t))
+	ctx := context.Background()
+	rec, err := repo.ReserveIdempotencyKey(ctx, "k1", "fp1", time.Hour)
+	if err != nil || rec != nil {
+		t.Fatalf("первый Reserve должен занять ключ: rec=%v err=%v", rec, err)
+	}
+	rec, err = repo.ReserveIdempotencyKey(ctx, "k1", "fp1", time.Hour)
+	if err != nil || rec == nil || rec.Status != 0 {
+		t.Fatalf("ключ должен быть в процессе: rec=%+v err=%v", rec, err)
+	}
+	if err := repo.CompleteIdempotencyKey(ctx, "k1", 201, []byte(`{"status":"ok"}`)); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	rec, err = repo.ReserveIdempotencyKey(ctx, "k1", "fp2", time.Hour)
+	if err != nil || rec == nil || rec.Status != 201 || rec.Fingerprint != "fp1" || string(rec.Body) != `{"status":"ok"}` {
+		t.Fatalf("ожидали сохранённый ответ: rec=%+v err=%v", rec, err)
+	}
+
+	if _, err := repo.ReserveIdempotencyKey(ctx, "k2", "fp", time.Hour); err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+	if err := repo.ReleaseIdempotencyKey(ctx, "k2"); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if rec, err := repo.ReserveIdempotencyKey(ctx, "k2", "fp", time.Hour); err != nil || rec != nil {
+		t.Fatalf("освобождённый ключ должен заниматься заново: rec=%v err=%v", rec, err)
+	}
+
+	n, err := repo.PurgeIdempotencyKeys(ctx, time.Now().Add(time.Minute))
+	if err != nil || n != 2 {
+		t.Fatalf("PurgeIdempotencyKeys должен удалить оба ключа: n=%d err=%v", n, err)
+	}
+	if rec, err := repo.ReserveIdempotencyKey(ctx, "k1", "fp3", time.Hour); err != nil || rec != nil {
+		t.Fatalf("удалённый ключ должен заниматься заново: rec=%v err=%v", rec, err)
+	}
+}
+
+func TestIntegration_Service_ConcurrentPatchesKeepBothChanges(t *testing.T) {
+	repo := repository.NewRepository(openTestDB(t))
+	ctx := context.Background()
+	o := generator.New(32).Order()
+	if _, err := repo.InsertOrder(ctx, o); err != nil {
+		t.Fatalf("InsertOrder: %v", err)
+	}
+	svc := service.NewService(repo, &lockedCache{mockCache: newMockCache()}, quietLogger())
+	patches := []string{`{"delivery":{"city":"Kazan"}}`, `{"payment":{"bank":"beta"}}`}
+	var wg sync.WaitGroup
+	errs := make([]error, len(patches))
+	for i, p := range patches {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = svc.PatchOrder(ctx, o.OrderUID, []byte(p))
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		t.Fatalf("PatchOrder: %v", err)
+	}
+	got, err := repo.GetOrderById(ctx, o.OrderUID)
+	if err != nil {
+		t.Fatalf("GetOrderById: %v", err)
+	}
+	if got.Delivery.City != "Kazan" || got.Payment.Bank != "beta" {
+		t.Fatalf("параллельные патчи не должны терять изменения: %+v %+v", got.Delivery, got.Payment)
+	}
+}
@@ -0,0 +1,184 @@
+//go:build integration
+
+package test
+
+import (
+	"awesomeProject/internal/generator"
+	"awesomeProject/internal/model"
+	"awesomeProject/internal/repository"
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func countOrders(t *testing.T, db *sql.DB, id string) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM orders WHERE order_uid=$1", id).Scan(&n); err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	return n
+}
+
+func TestIntegration_Sharded_RoutingAndRebalance(t *testing.T) {
+	db0, db1 := openTestDB(t), openTestDB(t)
+	repos := map[string]*repository.Repository{"s0": repository.NewRepository(db0), "s1": repository.NewRepository(db1)}
+	m := repository.ShardMap{
+		Shards:  map[string]string{"s0": "", "s1": ""},
+		Keys:    map[string]string{"0": "s0", "1": "s1"},
+		Default: "s0",
+	}
+	sharded, err := repository.NewSharded(m, repos)
+	if err != nil {
+		t.Fatalf("NewSharded: %v", err)
+	}
+	ctx := context.Background()
+	gen := generator.New(50)
+	a, b := gen.Order(), gen.Order()
+	a.ShardKey, b.ShardKey = "0", "1"
+	a.Payment.Currency, b.Payment.Currency = "RUB", "RUB"
+	for _, o := range []model.Order{a, b} {
+		if _, err := sharded.InsertOrder(ctx, o); err != nil {
+			t.Fatalf("InsertOrder: %v", err)
+		}
+	}
+	if countOrders(t, db0, a.OrderUID) != 1 || countOrders(t, db1, a.OrderUID) != 0 ||
+		countOrders(t, db1, b.OrderUID) != 1 || countOrders(t, db0, b.OrderUID) != 0 {
+		t.Fatalf("заказы легли не на свои шарды")
+	}
+	if got, err := sharded.GetOrderById(ctx, b.OrderUID); err != nil || got.OrderUID != b.OrderUID {
+		t.Fatalf("поиск по order_uid на другом шарде: %v", err)
+	}
+	if _, err := sharded.GetOrderById(ctx, "missing"); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("неизвестный заказ: ожидали ErrNotFound, получили %v", err)
+	}
+	if _, err := sharded.ChangeStatus(ctx, model.StatusChange{OrderUID: a.OrderUID, To: model.StatusPaid}); err != nil {
+		t.Fatalf("ChangeStatus: %v", err)
+	}
+	all, err := sharded.LoadAll(ctx)
+	if err != nil || len(all) != 2 {
+		t.Fatalf("LoadAll: %d заказов, %v", len(all), err)
+	}
+	revenue, err := sharded.RevenueByCurrency(ctx, time.Time{}, time.Now().AddDate(10, 0, 0))
+	if err != nil || len(revenue) != 1 || revenue[0].Orders != 2 || revenue[0].Amount != int64(a.Payment.Amount+b.Payment.Amount) {
+		t.Fatalf("выручка со всех шардов: %+v, %v", revenue, err)
+	}
+
+	// Смена shardkey не переносит заказ: он меняется там, где лежит, до rebalance.
+	a.ShardKey = "1"
+	if created, err := sharded.ReplaceOrder(ctx, a); err != nil || created {
+		t.Fatalf("ReplaceOrder: created=%v, %v", created, err)
+	}
+	if countOrders(t, db0, a.OrderUID) != 1 || countOrders(t, db1, a.OrderUID) != 0 {
+		t.Fatalf("заказ не должен переезжать при записи")
+	}
+
+	dry, err := sharded.Rebalance(ctx, 1, true)
+	if err != nil || dry.Checked != 2 || dry.Moved["s0->s1"] != 1 || countOrders(t, db1, a.OrderUID) != 0 {
+		t.Fatalf("dry-run: %+v, %v", dry, err)
+	}
+	res, err := sharded.Rebalance(ctx, 1, false)
+	if err != nil || res.Moved["s0->s1"] != 1 {
+		t.Fatalf("Rebalance: %+v, %v", res, err)
+	}
+	if countOrders(t, db0, a.OrderUID) != 0 || countOrders(t, db1, a.OrderUID) != 1 {
+		t.Fatalf("заказ не перенесён")
+	}
+	h, err := sharded.StatusHistory(ctx, a.OrderUID)
+	if err != nil || h.Status != model.StatusPaid || len(h.History) != 1 {
+		t.Fatalf("статус после переноса: %+v, %v", h, err)
+	}
+	audit, err := sharded.OrderHistory(ctx, a.OrderUID)
+	if err != nil || len(audit) != 2 || audit[0].Action != model.AuditCreated || audit[1].Action != model.AuditUpdated {
+		t.Fatalf("журнал после переноса: %+v, %v", audit, err)
+	}
+	if again, err := sharded.Rebalance(ctx, 10, false); err != nil || len(again.Moved) != 0 {
+		t.Fatalf("повторный rebalance: %+v, %v", again, err)
+	}
+
+	if err := sharded.DeleteOrder(ctx, a.OrderUID); err != nil {
+		t.Fatalf("DeleteOrder: %v", err)
+	}
+	if audit, err := sharded.OrderHistory(ctx, a.OrderUID); err != nil || audit[len(audit)-1].Action != model.AuditDeleted {
+		t.Fatalf("журнал удалённого заказа: %+v, %v", audit, err)
+	}
+}
+
+func TestIntegration_Sharded_RebalanceKeepsDestinationAudit(t *testing.T) {
+	db0, db1 := openTestDB(t), openTestDB(t)
+	repos := map[string]*repository.Repository{"s0": repository.NewRepository(db0), "s1": repository.NewRepository(db1)}
+	m := repository.ShardMap{
+		Shards:  map[string]string{"s0": "", "s1": ""},
+		Keys:    map[string]string{"0": "s0", "1": "s1"},
+		Default: "s0",
+	}
+	sharded, err := repository.NewSharded(m, repos)
+	if err != nil {
+		t.Fatalf("NewSharded: %v", err)
+	}
+	ctx := context.Background()
+	a := generator.New(51).Order()
+	a.ShardKey = "0"
+	if _, err := sharded.InsertOrder(ctx, a); err != nil {
+		t.Fatalf("InsertOrder: %v", err)
+	}
+	a.ShardKey = "1"
+	if _, err := sharded.ReplaceOrder(ctx, a); err != nil {
+		t.Fatalf("ReplaceOrder: %v", err)
+	}
+	// На dst уже есть своя история заказа и копия первой записи от прерванного переноса.
+	if _, err := db1.Exec("INSERT INTO audit_log (order_uid, action, source, changed_at) VALUES ($1,$2,'test',$3)",
+		a.OrderUID, model.AuditDeleted, time.Now().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	var first struct {
+		action, source      string
+		before, after, diff []byte
+		changedAt           time.Time
+	}
+	if err := db0.QueryRow("SELECT action, source, before, after, diff, changed_at FROM audit_log "+
+		"WHERE order_uid=$1 ORDER BY id LIMIT 1", a.OrderUID).Scan(&first.action, &first.source, &first.before,
+		&first.after, &first.diff, &first.changedAt); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db1.Exec("INSERT INTO audit_log (order_uid, action, source, before, after, diff, changed_at) "+
+		"VALUES ($1,$2,$3,$4,$5,$6,$7)", a.OrderUID, first.action, first.source, first.before, first.after,
+		first.diff, first.changedAt); err != nil {
+		t.Fatal(err)
+	}
+
+	if res, err := sharded.Rebalance(ctx, 10, false); err != nil || res.Moved["s0->s1"] != 1 {
+		t.Fatalf("Rebalance: %+v, %v", res, err)
+	}
+	audit, err := sharded.OrderHistory(ctx, a.OrderUID)
+	if err != nil || len(audit) != 3 || audit[0].Action != model.AuditDeleted ||
+		audit[1].Action != model.AuditCreated || audit[2].Action != model.AuditUpdated {
+		t.Fatalf("перенос должен дописать журнал на dst без потерь и повторов: %+v, %v", audit, err)
+	}
+}
+
+func TestIntegration_Sharded_ConsumerOffsetsTakeMinimum(t *testing.T) {
+	repos := map[string]*repository.Repository{
+		"s0": repository.NewRepository(openTestDB(t)),
+		"s1": repository.NewRepository(openTestDB(t)),
+	}
+	m := repository.ShardMap{Shards: map[string]string{"s0": "", "s1": ""}, Default: "s0"}
+	sharded, err := repository.NewSharded(m, repos)
+	if err != nil {
+		t.Fatalf("NewSharded: %v", err)
+	}
+	ctx := context.Background()
+	if err := sharded.AdvanceConsumerOffset(ctx, "g", "orders", 0, 4); err != nil {
+		t.Fatalf("AdvanceConsumerOffset: %v", err)
+	}
+	// Сдвиг дошёл только до одного шарда.
+	if err := repos["s1"].AdvanceConsumerOffset(ctx, "g", "orders", 0, 10); err != nil {
+		t.Fatalf("AdvanceConsumerOffset: %v", err)
+	}
+	got, err := sharded.ConsumerOffsets(ctx, "g", "orders")
+	if err != nil || got[0] != 4 {
+		t.Fatalf("ожидали наименьший offset 4, получили %v %v", got, err)
+	}
+}
@@ -0,0 +1,112 @@
+//go:build integration
+
+package test
+
+import (
+	"awesomeProject/internal/generator"
+	"awesomeProject/internal/model"
+	"awesomeProject/internal/repository"
+	"awesomeProject/internal/service"
+	"awesomeProject/kafka"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+func TestIntegration_Repository_StatusLifecycle(t *testing.T) {
+	repo := repository.NewRepository(openTestDB(t))
+	ctx := context.Background()
+	o := generator.New(46).Order()
+	if _, err := repo.InsertOrder(ctx, o); err != nil {
+		t.Fatalf("InsertOrder: %v", err)
+	}
+	h, err := repo.StatusHistory(ctx, o.OrderUID)
+	if err != nil || h.Status != model.StatusCreated || len(h.History) != 0 {
+		t.Fatalf("новый заказ: %+v, %v", h, err)
+	}
+
+	paid, err := repo.ChangeStatus(ctx, model.StatusChange{OrderUID: o.OrderUID, To: model.StatusPaid, Source: "http:test"})
+	if err != nil || paid.From != model.StatusCreated || paid.ChangedAt.IsZero() {
+		t.Fatalf("created -> paid: %+v, %v", paid, err)
+	}
+	if _, err := repo.ChangeStatus(ctx, model.StatusChange{OrderUID: o.OrderUID, To: model.StatusDelivered}); !errors.Is(err, model.ErrInvalidTransition) {
+		t.Fatalf("paid -> delivered: ожидали ErrInvalidTransition, получили %v", err)
+	}
+	if _, err := repo.ChangeStatus(ctx, model.StatusChange{OrderUID: "missing", To: model.StatusPaid}); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("неизвестный заказ: ожидали ErrNotFound, получили %v", err)
+	}
+	// Перезапись заказа не сбрасывает статус.
+	if _, err := repo.ReplaceOrder(ctx, o); err != nil {
+		t.Fatalf("ReplaceOrder: %v", err)
+	}
+
+	h, err = repo.StatusHistory(ctx, o.OrderUID)
+	if err != nil || h.Status != model.StatusPaid || len(h.History) != 1 {
+		t.Fatalf("история: %+v, %v", h, err)
+	}
+	if c := h.History[0]; c.From != model.StatusCreated || c.To != model.StatusPaid || c.Source != "http:test" {
+		t.Fatalf("запись истории: %+v", c)
+	}
+}
+
+type statusRepo struct {
+	mockRepo
+	mu      sync.Mutex
+	changes []model.StatusChange
+}
+
+func (r *statusRepo) ChangeStatus(ctx context.Context, c model.StatusChange) (model.StatusChange, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c.OrderUID != "o1" {
+		return c, repository.ErrNotFound
+	}
+	c.From, c.Source = model.StatusCreated, repository.SourceFrom(ctx)
+	if len(r.changes) > 0 {
+		c.From = r.changes[len(r.changes)-1].To
+	}
+	if !c.From.CanTransitionTo(c.To) {
+		return c, model.ErrInvalidTransition
+	}
+	r.changes = append(r.changes, c)
+	return c, nil
+}
+
+func (r *statusRepo) recorded() []model.StatusChange {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]model.StatusChange(nil), r.changes...)
+}
+
+func TestIntegration_Consumer_StatusEvents(t *testing.T) {
+	repo := &statusRepo{}
+	svc := service.NewService(repo, newMockCache(), quietLogger())
+	broker := newMemKafka()
+	status := kafkago.Header{Key: kafka.HeaderEventType, Value: []byte(model.EventOrderStatusChanged)}
+
+	broker.produce("o1", []byte(`{"order_uid":"o1","status":"paid","reason":"card","changed_at":"2024-05-01T10:00:00Z"}`), status)
+	broker.produce("o1", []byte(`{"order_uid":"o1","status":"paid"}`), status)       // повтор — недопустимый переход
+	broker.produce("o2", []byte(`{"order_uid":"o2","status":"paid"}`), status)       // неизвестный заказ
+	broker.produce("o1", []byte(`{"order_uid":"o1","status":"teleported"}`), status) // неизвестный статус
+	broker.produce("o1", []byte(`{not json`), status)
+	broker.produce("o1", []byte(`{"order_uid":"o1","status":"assembled"}`), status)
+
+	stop := runConsumer(t, kafka.NewConsumerWithReader(broker.reopen(), svc, quietLogger()))
+	waitFor(t, 5*time.Second, func() bool { return broker.committedOffset() == 6 })
+	stop()
+
+	got := repo.recorded()
+	if len(got) != 2 || got[0].To != model.StatusPaid || got[1].To != model.StatusAssembled {
+		t.Fatalf("применённые смены статуса: %+v", got)
+	}
+	if got[0].Source != "kafka:orders/0@0" || got[1].Source != "kafka:orders/0@5" {
+		t.Fatalf("источник должен указывать на сообщение: %q, %q", got[0].Source, got[1].Source)
+	}
+	if got[0].Reason != "card" || !got[0].ChangedAt.Equal(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Fatalf("время и причина из события: %+v", got[0])
+	}
+}
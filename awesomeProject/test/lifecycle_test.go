@@ -0,0 +1,89 @@
+package test
+
+import (
+	"awesomeProject/internal/lifecycle"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func TestLifecycle_PhasesRunInOrder(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	hook := func(name string, d time.Duration) lifecycle.Hook {
+		return lifecycle.Hook{Name: name, Stop: func(ctx context.Context) error {
+			time.Sleep(d)
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			return nil
+		}}
+	}
+	err := lifecycle.New(discard, time.Second).
+		Phase("drain", hook("http", 30*time.Millisecond), hook("kafka", 0)).
+		Phase("cache", hook("redis", 0)).
+		Phase("storage", hook("postgres", 0)).
+		Shutdown(context.Background())
+	if err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if strings.Join(order, ",") != "kafka,http,redis,postgres" {
+		t.Fatalf("хуки фазы идут параллельно, фазы — по очереди; получили %v", order)
+	}
+}
+
+func TestLifecycle_DeadlineAbandonsAndStillCloses(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	closed := false
+	start := time.Now()
+	err := lifecycle.New(logger, 50*time.Millisecond).
+		Phase("drain",
+			lifecycle.Hook{Name: "kafka", Stop: func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			}},
+			lifecycle.Hook{Name: "stuck", Stop: func(context.Context) error {
+				select {}
+			}},
+		).
+		Phase("storage", lifecycle.Hook{Name: "postgres", Stop: func(context.Context) error {
+			closed = true
+			return nil
+		}}).
+		Shutdown(context.Background())
+
+	if took := time.Since(start); took > time.Second {
+		t.Fatalf("Shutdown не должен ждать зависший хук, ждал %s", took)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "drain/kafka") ||
+		!strings.Contains(err.Error(), "drain/stuck") {
+		t.Fatalf("ожидали ошибку с брошенными kafka и stuck: %v", err)
+	}
+	if !closed {
+		t.Fatalf("после дедлайна хранилище всё равно должно закрываться")
+	}
+	out := logs.String()
+	if !strings.Contains(out, "component=kafka") || !strings.Contains(out, "still running at shutdown deadline") ||
+		!strings.Contains(out, "component=stuck") {
+		t.Fatalf("в логе нет брошенных компонентов: %s", out)
+	}
+}
+
+func TestLifecycle_StopError(t *testing.T) {
+	boom := errors.New("boom")
+	err := lifecycle.New(discard, 0).
+		Phase("cache", lifecycle.Hook{Name: "redis", Stop: func(context.Context) error { return boom }}).
+		Shutdown(context.Background())
+	if !errors.Is(err, boom) {
+		t.Fatalf("ожидали ошибку хука, получили %v", err)
+	}
+}
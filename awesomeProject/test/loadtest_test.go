@@ -0,0 +1,65 @@
+package test
+
+import (
+	"awesomeProject/internal/loadtest"
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestLoadtest_LocalReportsThroughputAndHits(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	env := loadtest.NewLocal(loadtest.LocalConfig{Consumers: 2}, logger)
+	defer env.Close()
+	rep, err := loadtest.Run(context.Background(), loadtest.Config{
+		Duration:       500 * time.Millisecond,
+		ProduceRate:    200,
+		ReadRate:       200,
+		ReportInterval: 100 * time.Millisecond,
+		Seed:           3,
+	}, env, logger)
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if rep.Produced == 0 || rep.Ingested == 0 {
+		t.Fatalf("ожидали произведённые и принятые заказы: %+v", rep)
+	}
+	if rep.Reads == 0 || rep.ReadLatency.Count == 0 {
+		t.Fatalf("ожидали замеры чтения: %+v", rep)
+	}
+	if rep.CacheHitRatio == 0 {
+		t.Fatalf("ожидали попадания в кэш после записи")
+	}
+	if len(rep.Timeline) == 0 {
+		t.Fatalf("ожидали промежуточные замеры")
+	}
+}
+
+func BenchmarkLoadtest_ColdVsWarm(b *testing.B) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	for _, cold := range []bool{false, true} {
+		name := "warm"
+		if cold {
+			name = "cold"
+		}
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				env := loadtest.NewLocal(loadtest.LocalConfig{DBLatency: time.Millisecond, ColdCache: cold}, logger)
+				rep, err := loadtest.Run(context.Background(), loadtest.Config{
+					Duration:    time.Second,
+					ProduceRate: 500,
+					ReadRate:    2000,
+				}, env, logger)
+				env.Close()
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.ReportMetric(float64(rep.ReadLatency.P99.Microseconds()), "read_p99_us")
+				b.ReportMetric(rep.IngestPerSec, "ingest/s")
+				b.ReportMetric(rep.CacheHitRatio, "hit_ratio")
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package test
+
+import (
+	"awesomeProject/internal/api"
+	"awesomeProject/internal/auth"
+	"awesomeProject/internal/model"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPI_PanicRecovery(t *testing.T) {
+	var logs bytes.Buffer
+	svc := &stubService{getFn: func(ctx context.Context, id string) (model.Order, error) {
+		panic("boom")
+	}}
+	h := api.NewRouter(svc, api.Config{Logger: slog.New(slog.NewTextHandler(&logs, nil))})
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/x", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("паника: ожидали 500, получили %d", rec.Code)
+	}
+	var resp api.ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Code != "internal" || resp.RequestID != "req-1" {
+		t.Fatalf("ожидали JSON-ошибку internal с request_id: %s", rec.Body)
+	}
+	out := logs.String()
+	if !strings.Contains(out, "http handler panic") || !strings.Contains(out, "panic=boom") ||
+		!strings.Contains(out, `msg="http request"`) || !strings.Contains(out, "status=500") {
+		t.Fatalf("в логе нет паники или access-записи: %s", out)
+	}
+}
+
+func TestAPI_PanicRecovery_AfterPartialWriteWithGzip(t *testing.T) {
+	h := api.Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"order_uid":`))
+		panic("boom")
+	}), api.Middlewares(api.Config{Gzip: true, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})...)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/x", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("X-Request-ID", "req-3")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("паника после частичной записи: ожидали 500, получили %d (%q)", rec.Code, rec.Body)
+	}
+	var resp api.ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Code != "internal" || resp.RequestID != "req-3" {
+		t.Fatalf("ожидали JSON-ошибку internal без обрывка ответа: %q", rec.Body)
+	}
+}
+
+func TestAPI_AccessLog(t *testing.T) {
+	var logs bytes.Buffer
+	keys, _ := auth.ParseAPIKeys("k:alice:reader")
+	h := api.NewRouter(&stubService{}, api.Config{Auth: keys, Logger: slog.New(slog.NewTextHandler(&logs, nil))})
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/x", nil)
+	req.Header.Set(auth.HeaderAPIKey, "k")
+	req.Header.Set("X-Request-ID", "req-2")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/orders/y", nil))
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("ожидали две строки access-лога: %q", lines)
+	}
+	for _, want := range []string{"method=GET", "path=/api/v1/orders/x", "status=200", "request_id=req-2", "subject=alice", "duration="} {
+		if !strings.Contains(lines[0], want) {
+			t.Fatalf("в access-логе нет %q: %s", want, lines[0])
+		}
+	}
+	if !strings.Contains(lines[1], "status=401") || strings.Contains(lines[1], "subject=") {
+		t.Fatalf("неаутентифицированный запрос: %s", lines[1])
+	}
+}
+
+func TestAPI_CORS(t *testing.T) {
+	h := api.NewRouter(&stubService{}, api.Config{CORS: api.CORSConfig{
+		AllowedOrigins: []string{"https://ui.example"}, MaxAge: time.Minute,
+	}})
+	pre := httptest.NewRequest(http.MethodOptions, "/api/v1/orders/x", nil)
+	pre.Header.Set("Origin", "https://ui.example")
+	pre.Header.Set("Access-Control-Request-Method", "DELETE")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, pre)
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Origin") != "https://ui.example" ||
+		!strings.Contains(rec.Header().Get("Access-Control-Allow-Methods"), "DELETE") ||
+		!strings.Contains(rec.Header().Get("Access-Control-Allow-Headers"), "Idempotency-Key") ||
+		rec.Header().Get("Access-Control-Max-Age") != "60" {
+		t.Fatalf("preflight: %d %v", rec.Code, rec.Header())
+	}
+
+	get := httptest.NewRequest(http.MethodGet, "/api/v1/orders/x", nil)
+	get.Header.Set("Origin", "https://ui.example")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, get)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Header().Get("Access-Control-Expose-Headers"), "X-Request-ID") {
+		t.Fatalf("простой запрос: %d %v", rec.Code, rec.Header())
+	}
+
+	get.Header.Set("Origin", "https://evil.example")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, get)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("чужой Origin не должен получать CORS-заголовки: %v", rec.Header())
+	}
+}
+
+func TestAPI_Gzip(t *testing.T) {
+	big := model.Order{OrderUID: "big", Items: make([]model.Item, 50)}
+	svc := &stubService{getFn: func(ctx context.Context, id string) (model.Order, error) {
+		if id == "big" {
+			return big, nil
+		}
+		return model.Order{OrderUID: id}, nil
+	}}
+	h := api.NewRouter(svc, api.Config{Gzip: true})
+	get := func(id, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/"+id, nil)
+		if accept != "" {
+			req.Header.Set("Accept-Encoding", accept)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("big", "br, gzip")
+	if rec.Header().Get("Content-Encoding") != "gzip" || !strings.Contains(rec.Header().Get("Vary"), "Accept-Encoding") {
+		t.Fatalf("большой ответ должен сжиматься: %v", rec.Header())
+	}
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	data, _ := io.ReadAll(zr)
+	var got model.Order
+	if err := json.Unmarshal(data, &got); err != nil || got.OrderUID != "big" || len(got.Items) != 50 {
+		t.Fatalf("распакованный ответ: %v %s", err, data)
+	}
+
+	if rec := get("small", "gzip"); rec.Header().Get("Content-Encoding") != "" || !strings.Contains(rec.Body.String(), "small") {
+		t.Fatalf("короткий ответ не сжимается: %v", rec.Header())
+	}
+	if rec := get("big", "gzip;q=0"); rec.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("gzip;q=0 — отказ от сжатия: %v", rec.Header())
+	}
+}
+
+func TestAPI_NewServerHasTimeouts(t *testing.T) {
+	srv := api.NewServer(":0", http.NotFoundHandler(), api.ServerConfig{WriteTimeout: time.Second}, nil)
+	if srv.ReadHeaderTimeout <= 0 || srv.ReadTimeout <= 0 || srv.IdleTimeout <= 0 || srv.WriteTimeout != time.Second ||
+		srv.MaxHeaderBytes <= 0 {
+		t.Fatalf("таймауты сервера не заданы: %+v", srv)
+	}
+}
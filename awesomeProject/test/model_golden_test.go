@@ -0,0 +1,93 @@
+package test
+
+import (
+	"awesomeProject/internal/generator"
+	"awesomeProject/internal/model"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var updateGolden = flag.Bool("update", false, "перезаписать golden-файлы в testdata")
+
+// checkGolden сравнивает JSON заказа с testdata/name побайтно. С -update файл перезаписывается.
+func checkGolden(t *testing.T, name string, o model.Order) {
+	t.Helper()
+	got, err := json.MarshalIndent(o, "", "  ")
+	if err != nil {
+		t.Fatalf("MarshalIndent: %v", err)
+	}
+	got = append(got, '\n')
+	path := filepath.Join("testdata", name)
+	if *updateGolden {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("golden-файл: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("JSON заказа отличается от %s:\n%s", path, got)
+	}
+}
+
+func TestModel_GoldenWireFormat(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "order.golden.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var o model.Order
+	if err := json.Unmarshal(data, &o); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if o.OrderUID != "b563feb7b2b84b6test" || o.Entry != model.EntryWBIL || o.Locale != model.LocaleEN ||
+		o.ShardKey != "9" || o.SmID != 99 || o.OOFShard != "1" {
+		t.Fatalf("поля заказа: %+v", o)
+	}
+	if want := time.Date(2021, 11, 26, 6, 22, 7, 0, time.UTC); !o.Payment.PaymentTime.Equal(want) {
+		t.Fatalf("payment_dt: %v, ожидали %v", o.Payment.PaymentTime, want)
+	}
+	if len(o.Items) != 1 || o.Items[0].ChrtID != 9934930 || o.Items[0].RID != "ab4219087a764ae0btest" ||
+		o.Items[0].Status != model.ItemStatusAccepted {
+		t.Fatalf("позиции: %+v", o.Items)
+	}
+	// Разобранный заказ сериализуется обратно в тот же JSON: те же ключи, порядок и значения.
+	checkGolden(t, "order.golden.json", o)
+}
+
+func TestModel_GoldenGeneratedOrder(t *testing.T) {
+	o := generator.New(49).Order()
+	checkGolden(t, "generated.golden.json", o)
+
+	data, _ := json.Marshal(o)
+	var back model.Order
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if !back.Payment.PaymentTime.Equal(o.Payment.PaymentTime.Time) || back.Items[0].Status != o.Items[0].Status {
+		t.Fatalf("заказ не пережил JSON: %+v", back.Payment)
+	}
+}
+
+func TestModel_PaymentTime(t *testing.T) {
+	data, err := json.Marshal(model.Payment{Currency: "RUB"})
+	if err != nil || !bytes.Contains(data, []byte(`"payment_dt":0`)) {
+		t.Fatalf("нулевое время оплаты должно передаваться как 0: %s, %v", data, err)
+	}
+	p := model.Payment{Bank: "alpha", PaymentTime: model.FromUnixSeconds(1637907727)}
+	if err := json.Unmarshal([]byte(`{"amount":100,"payment_dt":0}`), &p); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if !p.PaymentTime.IsZero() || p.Amount != 100 || p.Bank != "alpha" {
+		t.Fatalf("payment_dt 0 — нулевое время, отсутствующие поля не меняются: %+v", p)
+	}
+	if err := json.Unmarshal([]byte(`{"payment_dt":"yesterday"}`), &p); err == nil {
+		t.Fatalf("строка в payment_dt должна отклоняться")
+	}
+}
@@ -0,0 +1,226 @@
+package test
+
+import (
+	"awesomeProject/internal/api"
+	"awesomeProject/internal/model"
+	"awesomeProject/internal/money"
+	"awesomeProject/internal/service"
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMoney_MinorUnits(t *testing.T) {
+	for code, want := range map[string]int{"RUB": 2, "USD": 2, "JPY": 0, "KWD": 3, "CLF": 4} {
+		if got, err := money.Exponent(code); err != nil || got != want {
+			t.Fatalf("Exponent(%s) = %d, %v; ожидали %d", code, got, err, want)
+		}
+	}
+	for _, code := range []string{"", "rub", "XYZ", "RUR"} {
+		if _, err := money.New(1, code); !errors.Is(err, money.ErrUnknownCurrency) {
+			t.Fatalf("New(%q): ожидали ErrUnknownCurrency, получили %v", code, err)
+		}
+	}
+	for m, want := range map[money.Money]string{
+		{Amount: 123450, Currency: "RUB"}: "1234.50 RUB",
+		{Amount: -5, Currency: "USD"}:     "-0.05 USD",
+		{Amount: 980, Currency: "JPY"}:    "980 JPY",
+		{Amount: 1500, Currency: "KWD"}:   "1.500 KWD",
+	} {
+		if got := m.String(); got != want {
+			t.Fatalf("String: %q, ожидали %q", got, want)
+		}
+	}
+}
+
+func TestMoney_Arithmetic(t *testing.T) {
+	rub := money.Money{Amount: 100, Currency: "RUB"}
+	if _, err := rub.Add(money.Money{Amount: 1, Currency: "USD"}); !errors.Is(err, money.ErrCurrencyMismatch) {
+		t.Fatalf("RUB + USD: ожидали ErrCurrencyMismatch, получили %v", err)
+	}
+	if _, err := rub.Add(money.Money{Amount: math.MaxInt64, Currency: "RUB"}); !errors.Is(err, money.ErrOverflow) {
+		t.Fatalf("переполнение сложения: %v", err)
+	}
+	if _, err := rub.Mul(math.MaxInt64 / 10); !errors.Is(err, money.ErrOverflow) {
+		t.Fatalf("переполнение умножения: %v", err)
+	}
+	if got, err := rub.Mul(3); err != nil || got.Amount != 300 {
+		t.Fatalf("Mul: %v, %v", got, err)
+	}
+	total, err := money.Sum("RUB", rub, rub, money.Money{Amount: 50, Currency: "RUB"})
+	if err != nil || total.Amount != 250 || total.Currency != "RUB" {
+		t.Fatalf("Sum: %v, %v", total, err)
+	}
+}
+
+func TestMoney_Convert(t *testing.T) {
+	rates, err := money.NewRates("USD", map[string]string{"RUB": "90", "JPY": "150.5", "KWD": "0.307"})
+	if err != nil {
+		t.Fatalf("NewRates: %v", err)
+	}
+	cases := []struct {
+		in   money.Money
+		to   string
+		want int64
+	}{
+		{money.Money{Amount: 9000, Currency: "RUB"}, "USD", 100},  // 90.00 RUB = 1.00 USD
+		{money.Money{Amount: 4545, Currency: "RUB"}, "USD", 51},   // 0.505 USD -> 0.51
+		{money.Money{Amount: -4545, Currency: "RUB"}, "USD", -51}, // половина — от нуля
+		{money.Money{Amount: 100, Currency: "USD"}, "JPY", 151},   // 150.5 JPY -> 151
+		{money.Money{Amount: 1000, Currency: "USD"}, "KWD", 3070}, // 3.070 KWD
+		{money.Money{Amount: 301, Currency: "JPY"}, "RUB", 18000}, // 301/150.5*90 = 180.00 RUB
+		{money.Money{Amount: 777, Currency: "RUB"}, "RUB", 777},
+	}
+	for _, tc := range cases {
+		got, err := rates.Convert(tc.in, tc.to)
+		if err != nil || got.Amount != tc.want || got.Currency != tc.to {
+			t.Fatalf("%v -> %s: %v, %v; ожидали %d", tc.in, tc.to, got, err, tc.want)
+		}
+	}
+	if _, err := rates.Convert(money.Money{Amount: 1, Currency: "EUR"}, "USD"); !errors.Is(err, money.ErrNoRate) {
+		t.Fatalf("валюта без курса: ожидали ErrNoRate, получили %v", err)
+	}
+	if _, err := money.NewRates("USD", map[string]string{"EUR": "-1"}); err == nil {
+		t.Fatalf("отрицательный курс должен отклоняться")
+	}
+}
+
+func TestMoney_LoadRates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	if err := os.WriteFile(path, []byte(`{"base":"RUB","rates":{"USD":"0.011","EUR":0.0102}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	rates, err := money.LoadRates(path)
+	if err != nil || rates.Base() != "RUB" {
+		t.Fatalf("LoadRates: %v", err)
+	}
+	if got, err := rates.Convert(money.Money{Amount: 102, Currency: "EUR"}, "RUB"); err != nil || got.Amount != 10000 {
+		t.Fatalf("1.02 EUR -> RUB: %v, %v", got, err)
+	}
+	if err := os.WriteFile(path, []byte(`{"base":"RUB","rates":{"BTC":"0.0000001"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := money.LoadRates(path); !errors.Is(err, money.ErrUnknownCurrency) {
+		t.Fatalf("код не из ISO 4217: ожидали ErrUnknownCurrency, получили %v", err)
+	}
+}
+
+func TestValidate_Currency(t *testing.T) {
+	o := model.Order{OrderUID: "o1", Payment: model.Payment{Currency: "RUB", Amount: 100}}
+	if err := service.Validate(o); err != nil {
+		t.Fatalf("RUB: %v", err)
+	}
+	o.Payment.Currency = ""
+	if err := service.Validate(o); err != nil {
+		t.Fatalf("пустая валюта допустима для старых заказов: %v", err)
+	}
+	o.Payment.Currency = "rub"
+	var verr *service.ValidationError
+	if err := service.Validate(o); !errors.As(err, &verr) || verr.Field != "payment.currency" {
+		t.Fatalf("rub: ожидали ошибку payment.currency, получили %v", err)
+	}
+	o.Payment.Currency = "USD"
+	o.Items = []model.Item{{ChrtID: 1, TotalPrice: math.MaxInt64}, {ChrtID: 2, TotalPrice: 1}}
+	if err := service.Validate(o); !errors.As(err, &verr) || verr.Field != "items" {
+		t.Fatalf("переполнение суммы позиций: %v", err)
+	}
+}
+
+type revenueStore []model.CurrencyRevenue
+
+func (s revenueStore) RevenueByCurrency(ctx context.Context, from, to time.Time) ([]model.CurrencyRevenue, error) {
+	return s, nil
+}
+
+func TestReports_Revenue(t *testing.T) {
+	rates, _ := money.NewRates("USD", map[string]string{"RUB": "90", "EUR": "0.9"})
+	store := revenueStore{
+		{Currency: "", Orders: 1, Amount: 500},
+		{Currency: "EUR", Orders: 2, Amount: 900},
+		{Currency: "KZT", Orders: 1, Amount: 100000},
+		{Currency: "RUB", Orders: 3, Amount: 18000},
+	}
+	reports := service.NewReports(store, rates)
+	to := time.Now()
+	from := to.Add(-time.Hour)
+
+	r, err := reports.Revenue(context.Background(), from, to, "")
+	if err != nil {
+		t.Fatalf("Revenue: %v", err)
+	}
+	// 9.00 EUR = 10.00 USD, 180.00 RUB = 2.00 USD.
+	if r.Currency != "USD" || r.Total.Amount != 1200 || r.Orders != 5 {
+		t.Fatalf("итог: %+v", r)
+	}
+	if len(r.ByCurrency) != 4 || len(r.Unconverted) != 2 || r.Unconverted[0] != "" || r.Unconverted[1] != "KZT" {
+		t.Fatalf("строки %+v, без курса %v", r.ByCurrency, r.Unconverted)
+	}
+	if line := r.ByCurrency[1]; line.Amount.Amount != 900 || line.Converted == nil || line.Converted.Amount != 1000 {
+		t.Fatalf("строка EUR: %+v", line)
+	}
+
+	r, err = reports.Revenue(context.Background(), from, to, "RUB")
+	if err != nil || r.Total.Amount != 90000+18000 {
+		t.Fatalf("итог в RUB: %+v, %v", r.Total, err)
+	}
+	if _, err := reports.Revenue(context.Background(), from, to, "XYZ"); !errors.Is(err, service.ErrInvalidOrder) {
+		t.Fatalf("неизвестная валюта: %v", err)
+	}
+	if _, err := service.NewReports(store, nil).Revenue(context.Background(), from, to, ""); !errors.Is(err, service.ErrInvalidOrder) {
+		t.Fatalf("без курсов валюта отчёта обязательна: %v", err)
+	}
+}
+
+type stubReports struct {
+	from, to time.Time
+	currency string
+}
+
+func (s *stubReports) Revenue(ctx context.Context, from, to time.Time, currency string) (service.RevenueReport, error) {
+	s.from, s.to, s.currency = from, to, currency
+	if currency == "XYZ" {
+		return service.RevenueReport{}, &service.ValidationError{Field: "currency", Reason: "unknown"}
+	}
+	return service.RevenueReport{From: from, To: to, Currency: currency, Total: money.Money{Amount: 1, Currency: currency}}, nil
+}
+
+func TestAPI_RevenueReport(t *testing.T) {
+	if rec := serve(api.NewRouter(&stubService{}, api.Config{}), http.MethodGet, "/api/v1/reports/revenue", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("без Config.Reports маршрута быть не должно: код %d", rec.Code)
+	}
+	reports := &stubReports{}
+	h := api.NewRouter(&stubService{}, api.Config{Reports: reports})
+
+	rec := serve(h, http.MethodGet, "/api/v1/reports/revenue?from=2024-03-01&to=2024-03-31&currency=eur", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("код %d, тело %s", rec.Code, rec.Body)
+	}
+	wantFrom := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	if !reports.from.Equal(wantFrom) || !reports.to.Equal(wantFrom.AddDate(0, 1, 0)) || reports.currency != "EUR" {
+		t.Fatalf("в сервис передано %v..%v %q", reports.from, reports.to, reports.currency)
+	}
+	var resp service.RevenueReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Total.Currency != "EUR" {
+		t.Fatalf("ответ %s: %v", rec.Body, err)
+	}
+
+	if rec := serve(h, http.MethodGet, "/api/v1/reports/revenue", ""); rec.Code != http.StatusOK ||
+		reports.to.Sub(reports.from) != 30*24*time.Hour {
+		t.Fatalf("период по умолчанию: код %d, %v..%v", rec.Code, reports.from, reports.to)
+	}
+	if rec := serve(h, http.MethodGet, "/api/v1/reports/revenue?from=yesterday", ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("некорректная дата: код %d", rec.Code)
+	}
+	if rec := serve(h, http.MethodGet, "/api/v1/reports/revenue?currency=XYZ", ""); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("неизвестная валюта: код %d", rec.Code)
+	}
+	if rec := serve(h, http.MethodPost, "/api/v1/reports/revenue", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("POST: код %d", rec.Code)
+	}
+}
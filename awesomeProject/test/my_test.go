@@ -2,8 +2,10 @@ package test
 
 import (
 	"awesomeProject/internal/model"
+	"awesomeProject/internal/repository"
 	"awesomeProject/internal/service"
 	"context"
+	"errors"
 	"log/slog"
 	"os"
 	"reflect"
@@ -15,13 +17,35 @@ type mockRepo struct {
 	insertFn  func(ctx context.Context, o model.Order) error
 	getFn     func(ctx context.Context, id string) (model.Order, error)
 	loadAllFn func(ctx context.Context) ([]model.Order, error)
+	replaceFn func(ctx context.Context, o model.Order) (bool, error)
+	batchFn   func(ctx context.Context, list []model.Order) ([]bool, error)
+	deleteFn  func(ctx context.Context, id string) error
+	statusFn  func(ctx context.Context, change model.StatusChange) (model.StatusChange, error)
+	historyFn func(ctx context.Context, id string) (model.StatusHistory, error)
+	auditFn   func(ctx context.Context, id string) ([]model.AuditRecord, error)
 }
 
-func (m *mockRepo) InsertOrder(ctx context.Context, o model.Order) error {
+func (m *mockRepo) InsertOrder(ctx context.Context, o model.Order) (bool, error) {
 	if m.insertFn != nil {
-		return m.insertFn(ctx, o)
+		err := m.insertFn(ctx, o)
+		return err == nil, err
 	}
-	return nil
+	return true, nil
+}
+func (m *mockRepo) UpdateOrder(ctx context.Context, id string,
+	update func(model.Order) (model.Order, error)) (model.Order, error) {
+	current, err := m.GetOrderById(ctx, id)
+	if err != nil {
+		return model.Order{}, err
+	}
+	order, err := update(current)
+	if err != nil {
+		return model.Order{}, err
+	}
+	if _, err := m.ReplaceOrder(ctx, order); err != nil {
+		return model.Order{}, err
+	}
+	return order, nil
 }
 func (m *mockRepo) GetOrderById(ctx context.Context, id string) (model.Order, error) {
 	if m.getFn != nil {
@@ -36,6 +60,43 @@ func (m *mockRepo) LoadAll(ctx context.Context) ([]model.Order, error) {
 	return nil, nil
 }
 
+func (m *mockRepo) ReplaceOrder(ctx context.Context, o model.Order) (bool, error) {
+	if m.replaceFn != nil {
+		return m.replaceFn(ctx, o)
+	}
+	return false, nil
+}
+func (m *mockRepo) ReplaceOrders(ctx context.Context, list []model.Order) ([]bool, error) {
+	if m.batchFn != nil {
+		return m.batchFn(ctx, list)
+	}
+	return make([]bool, len(list)), nil
+}
+func (m *mockRepo) DeleteOrder(ctx context.Context, id string) error {
+	if m.deleteFn != nil {
+		return m.deleteFn(ctx, id)
+	}
+	return nil
+}
+func (m *mockRepo) ChangeStatus(ctx context.Context, change model.StatusChange) (model.StatusChange, error) {
+	if m.statusFn != nil {
+		return m.statusFn(ctx, change)
+	}
+	return change, nil
+}
+func (m *mockRepo) StatusHistory(ctx context.Context, id string) (model.StatusHistory, error) {
+	if m.historyFn != nil {
+		return m.historyFn(ctx, id)
+	}
+	return model.StatusHistory{OrderUID: id, Status: model.StatusCreated}, nil
+}
+func (m *mockRepo) OrderHistory(ctx context.Context, id string) ([]model.AuditRecord, error) {
+	if m.auditFn != nil {
+		return m.auditFn(ctx, id)
+	}
+	return nil, repository.ErrNotFound
+}
+
 type mockCache struct {
 	mem       map[string]model.Order
 	setCount  int
@@ -50,19 +111,22 @@ func (c *mockCache) Get(id string) (model.Order, bool) {
 }
 func (c *mockCache) Set(o model.Order) {
 	c.setCount++
-	c.mem[o.Order_uid] = o
+	c.mem[o.OrderUID] = o
+}
+func (c *mockCache) Delete(id string) {
+	delete(c.mem, id)
 }
 func (c *mockCache) BulkSet(list []model.Order) {
 	c.bulkCount += len(list)
 	for _, o := range list {
-		c.mem[o.Order_uid] = o
+		c.mem[o.OrderUID] = o
 	}
 }
 
 func TestService_GetOrderByID_CacheHit(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 	cache := newMockCache()
-	exp := model.Order{Order_uid: "id1", Track_number: "TN1"}
+	exp := model.Order{OrderUID: "id1", TrackNumber: "TN1"}
 	cache.mem["id1"] = exp
 
 	repo := &mockRepo{
@@ -87,7 +151,7 @@ func TestService_GetOrderByID_CacheHit(t *testing.T) {
 func TestService_GetOrderByID_CacheMissLoadsFromRepo(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 	cache := newMockCache()
-	exp := model.Order{Order_uid: "id2", Track_number: "TN2"}
+	exp := model.Order{OrderUID: "id2", TrackNumber: "TN2"}
 
 	repo := &mockRepo{
 		getFn: func(ctx context.Context, id string) (model.Order, error) {
@@ -117,7 +181,7 @@ func TestService_Warmup_FillsCache(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 	cache := newMockCache()
 	src := []model.Order{
-		{Order_uid: "A"}, {Order_uid: "B"},
+		{OrderUID: "A"}, {OrderUID: "B"},
 	}
 	repo := &mockRepo{
 		loadAllFn: func(ctx context.Context) ([]model.Order, error) {
@@ -134,8 +198,117 @@ func TestService_Warmup_FillsCache(t *testing.T) {
 		t.Fatalf("ожидали BulkSet по каждому заказу: %d, получили %d", len(src), cache.bulkCount)
 	}
 	for _, o := range src {
-		if _, ok := cache.mem[o.Order_uid]; !ok {
-			t.Fatalf("заказ %s должен оказаться в кэше", o.Order_uid)
+		if _, ok := cache.mem[o.OrderUID]; !ok {
+			t.Fatalf("заказ %s должен оказаться в кэше", o.OrderUID)
+		}
+	}
+}
+
+func TestService_GetOrderByID_WrapsStorageErrors(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	dbErr := errors.New("connection refused")
+	repo := &mockRepo{
+		getFn: func(ctx context.Context, id string) (model.Order, error) {
+			if id == "missing" {
+				return model.Order{}, repository.ErrNotFound
+			}
+			return model.Order{}, dbErr
+		},
+	}
+	svc := service.NewService(repo, newMockCache(), logger)
+	if _, err := svc.GetOrderByID(context.Background(), "missing"); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("ErrNotFound должен пробрасываться как есть, получили %v", err)
+	}
+	_, err := svc.GetOrderByID(context.Background(), "id")
+	if !errors.Is(err, service.ErrDependency) || !errors.Is(err, dbErr) {
+		t.Fatalf("ошибка хранилища должна оборачиваться в ErrDependency, получили %v", err)
+	}
+	if _, err := svc.GetOrderByID(context.Background(), ""); !errors.Is(err, service.ErrInvalidOrder) {
+		t.Fatalf("пустой id должен давать ошибку валидации, получили %v", err)
+	}
+}
+
+func TestService_PatchOrder_MergesAllowedFields(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	cache := newMockCache()
+	// Устаревшая копия в кэше: патч применяется к заказу из БД.
+	cache.mem["id1"] = model.Order{OrderUID: "id1", TrackNumber: "STALE"}
+	var replaced model.Order
+	repo := &mockRepo{
+		getFn: func(ctx context.Context, id string) (model.Order, error) {
+			return model.Order{
+				OrderUID:    "id1",
+				TrackNumber: "TN",
+				Delivery:    model.Delivery{Name: "Test", City: "Moscow", Phone: "+7900"},
+				Items:       []model.Item{{ChrtID: 1}, {ChrtID: 2}},
+			}, nil
+		},
+		replaceFn: func(ctx context.Context, o model.Order) (bool, error) {
+			replaced = o
+			return false, nil
+		},
+	}
+	svc := service.NewService(repo, cache, logger)
+
+	got, err := svc.PatchOrder(context.Background(), "id1",
+		[]byte(`{"delivery":{"city":"Kazan","phone":null},"items":[{"chrt_id":3}]}`))
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if got.Delivery.City != "Kazan" || got.Delivery.Name != "Test" || got.Delivery.Phone != "" {
+		t.Fatalf("delivery смержен неверно: %+v", got.Delivery)
+	}
+	if len(got.Items) != 1 || got.Items[0].ChrtID != 3 || got.TrackNumber != "TN" {
+		t.Fatalf("items должны заменяться целиком, остальное — сохраняться: %+v", got)
+	}
+	if !reflect.DeepEqual(replaced, got) || !reflect.DeepEqual(cache.mem["id1"], got) {
+		t.Fatalf("в репозиторий и кэш должен уйти смерженный заказ")
+	}
+
+	_, err = svc.PatchOrder(context.Background(), "id1", []byte(`{"order_uid":"other"}`))
+	if !errors.Is(err, service.ErrInvalidOrder) {
+		t.Fatalf("патч order_uid должен отклоняться, получили %v", err)
+	}
+}
+
+func TestService_UpsertMany_BatchesAndReportsPerOrder(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	var batches [][]string
+	repo := &mockRepo{batchFn: func(ctx context.Context, list []model.Order) ([]bool, error) {
+		ids := make([]string, 0, len(list))
+		for _, o := range list {
+			ids = append(ids, o.OrderUID)
+		}
+		batches = append(batches, ids)
+		if list[0].OrderUID == "fail" {
+			return nil, errors.New("connection reset")
 		}
+		created := make([]bool, len(list))
+		for i, o := range list {
+			created[i] = o.OrderUID != "old"
+		}
+		return created, nil
+	}}
+	cache := newMockCache()
+	svc := service.NewService(repo, cache, logger)
+	list := []model.Order{
+		{OrderUID: "new"}, {OrderUID: ""}, {OrderUID: "old"},
+		{OrderUID: "fail"}, {OrderUID: "x"},
+	}
+	res := svc.UpsertMany(context.Background(), list, 2)
+	want := []string{service.BulkCreated, service.BulkInvalid, service.BulkUpdated, service.BulkFailed, service.BulkFailed}
+	for i, st := range want {
+		if res[i].Index != i || res[i].Status != st {
+			t.Fatalf("результат #%d: %+v, ожидали %s", i, res[i], st)
+		}
+	}
+	if len(batches) != 2 || len(batches[0]) != 2 || len(batches[1]) != 2 {
+		t.Fatalf("ожидали две пачки по два заказа, получили %v", batches)
+	}
+	if _, ok := cache.mem["fail"]; ok {
+		t.Fatalf("заказы из упавшей пачки не должны попадать в кэш")
+	}
+	if _, ok := cache.mem["new"]; !ok {
+		t.Fatalf("записанные заказы должны попадать в кэш")
 	}
 }
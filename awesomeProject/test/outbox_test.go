@@ -0,0 +1,156 @@
+package test
+
+import (
+	"awesomeProject/internal/outbox"
+	"awesomeProject/internal/repository"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// memOutbox — in-memory outbox с семантикой RelayOutbox: события помечаются опубликованными,
+// только если publish вернул nil.
+type memOutbox struct {
+	mu        sync.Mutex
+	records   []repository.OutboxRecord
+	published map[int64]bool
+	purged    int
+}
+
+func (o *memOutbox) add(uid, typ string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.records = append(o.records, repository.OutboxRecord{
+		ID: int64(len(o.records) + 1), OrderUID: uid, EventType: typ, Payload: []byte(`{"type":"` + typ + `"}`),
+	})
+}
+
+func (o *memOutbox) RelayOutbox(ctx context.Context, limit int,
+	publish func(ctx context.Context, batch []repository.OutboxRecord) error) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var batch []repository.OutboxRecord
+	for _, r := range o.records {
+		if !o.published[r.ID] && len(batch) < limit {
+			batch = append(batch, r)
+		}
+	}
+	if len(batch) == 0 {
+		return 0, nil
+	}
+	if err := publish(ctx, batch); err != nil {
+		return 0, err
+	}
+	for _, r := range batch {
+		o.published[r.ID] = true
+	}
+	return len(batch), nil
+}
+
+func (o *memOutbox) PurgeOutbox(ctx context.Context, before time.Time) (int64, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.purged++
+	return 0, nil
+}
+
+func (o *memOutbox) publishedCount() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.published)
+}
+
+type memPublisher struct {
+	mu    sync.Mutex
+	fails int
+	msgs  []kafka.Message
+}
+
+func (p *memPublisher) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.fails > 0 {
+		p.fails--
+		return errors.New("kafka unavailable")
+	}
+	p.msgs = append(p.msgs, msgs...)
+	return nil
+}
+
+func (p *memPublisher) sent() []kafka.Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]kafka.Message(nil), p.msgs...)
+}
+
+func header(m kafka.Message, key string) string {
+	for _, h := range m.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func waitUntil(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("условие не выполнилось за 2s")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestOutboxRelay_PublishesAndRetries(t *testing.T) {
+	store := &memOutbox{published: map[int64]bool{}}
+	store.add("o1", "OrderCreated")
+	store.add("o1", "OrderUpdated")
+	store.add("o2", "OrderDeleted")
+	pub := &memPublisher{fails: 1}
+	relay := outbox.NewRelay(store, pub, outbox.Config{
+		BatchSize: 2, PollInterval: 10 * time.Millisecond, CleanupInterval: 10 * time.Millisecond,
+	}, discard)
+	go func() { _ = relay.Run(context.Background()) }()
+
+	waitUntil(t, func() bool { return store.publishedCount() == 3 })
+	waitUntil(t, func() bool { store.mu.Lock(); defer store.mu.Unlock(); return store.purged > 0 })
+	if err := relay.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	msgs := pub.sent()
+	if len(msgs) != 3 {
+		t.Fatalf("после неудачной попытки события публикуются повторно ровно один раз: %d", len(msgs))
+	}
+	for i, want := range []struct{ key, id, typ string }{
+		{"o1", "1", "OrderCreated"}, {"o1", "2", "OrderUpdated"}, {"o2", "3", "OrderDeleted"},
+	} {
+		m := msgs[i]
+		if string(m.Key) != want.key || header(m, outbox.HeaderEventID) != want.id ||
+			header(m, outbox.HeaderEventType) != want.typ {
+			t.Fatalf("сообщение %d: key=%s headers=%v", i, m.Key, m.Headers)
+		}
+	}
+}
+
+func TestOutboxRelay_ShutdownPublishesRemaining(t *testing.T) {
+	store := &memOutbox{published: map[int64]bool{}}
+	pub := &memPublisher{}
+	relay := outbox.NewRelay(store, pub, outbox.Config{PollInterval: time.Hour}, discard)
+	go func() { _ = relay.Run(context.Background()) }()
+	time.Sleep(20 * time.Millisecond)
+	store.add("late", "OrderCreated")
+
+	if err := relay.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if len(pub.sent()) != 1 {
+		t.Fatalf("при остановке relay публикует то, что успели записать")
+	}
+}
@@ -0,0 +1,119 @@
+package test
+
+import (
+	"awesomeProject/internal/api"
+	"awesomeProject/internal/auth"
+	"awesomeProject/internal/model"
+	"awesomeProject/internal/pii"
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func piiOrder() model.Order {
+	return model.Order{
+		OrderUID: "o1",
+		Delivery: model.Delivery{
+			Name: "Test Testov", Phone: "+9720000000", Zip: "2639809", City: "Kiryat Mozkin",
+			Address: "Ploshad Mira 15", Region: "Kraiot", Email: "test@gmail.com",
+		},
+		Items: []model.Item{{ChrtID: 1, Name: "Mascaras"}},
+	}
+}
+
+func TestPII_Mask(t *testing.T) {
+	cases := []struct{ kind, in, want string }{
+		{pii.KindPhone, "+9720000000", "+97*****000"},
+		{pii.KindPhone, "12345", "*****"},
+		{pii.KindEmail, "test@gmail.com", "t***@gmail.com"},
+		{pii.KindName, "Test Testov", "T*** T*****"},
+		{pii.KindName, "Иван", "И***"},
+		{pii.KindFull, "Ploshad Mira 15", "***"},
+		{pii.KindPartial, "secret", "s****t"},
+		{pii.KindPhone, "", ""},
+	}
+	for _, tc := range cases {
+		if got := pii.Mask(tc.kind, tc.in); got != tc.want {
+			t.Fatalf("Mask(%s, %q) = %q, ожидали %q", tc.kind, tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestPII_RedactCopiesOrder(t *testing.T) {
+	o := piiOrder()
+	r := pii.Redact(o)
+	d := r.Delivery
+	if d.Phone != "+97*****000" || d.Email != "t***@gmail.com" || d.Name != "T*** T*****" || d.Address != "***" {
+		t.Fatalf("доставка не замаскирована: %+v", d)
+	}
+	if d.City != "Kiryat Mozkin" || d.Zip != "2639809" || r.Items[0].Name != "Mascaras" {
+		t.Fatalf("замаскированы поля без тега: %+v", r)
+	}
+	if o.Delivery.Phone != "+9720000000" {
+		t.Fatal("Redact изменил исходный заказ")
+	}
+	p := pii.Redact(&o)
+	if p == &o || p.Delivery.Phone == o.Delivery.Phone {
+		t.Fatal("указатель должен маскироваться через копию")
+	}
+}
+
+func TestPII_OrdersAreMaskedInLogs(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	o := piiOrder()
+	logger.Info("order", slog.Any("order", o), slog.Any("delivery", o.Delivery))
+	out := buf.String()
+	for _, secret := range []string{"+9720000000", "test@gmail.com", "Testov", "Ploshad"} {
+		if strings.Contains(out, secret) {
+			t.Fatalf("в логе открытые PII %q: %s", secret, out)
+		}
+	}
+	if !strings.Contains(out, `"order_uid":"o1"`) || !strings.Contains(out, "+97*****000") {
+		t.Fatalf("в логе нет заказа: %s", out)
+	}
+}
+
+func TestAPI_PIIMaskingByRole(t *testing.T) {
+	keys, _ := auth.ParseAPIKeys("r:dash:reader,rp:support:reader:pii,a:ops:admin")
+	svc := &stubService{getFn: func(ctx context.Context, id string) (model.Order, error) {
+		return piiOrder(), nil
+	}}
+	get := func(h http.Handler, key string) model.Delivery {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/o1", nil)
+		if key != "" {
+			req.Header.Set(auth.HeaderAPIKey, key)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("ключ %q: ожидали 200, получили %d", key, rec.Code)
+		}
+		var o model.Order
+		_ = json.Unmarshal(rec.Body.Bytes(), &o)
+		return o.Delivery
+	}
+	h := api.NewRouter(svc, api.Config{Auth: keys, PII: api.PIIPolicy{Roles: []auth.Role{auth.RoleAdmin}}})
+	if d := get(h, "r"); d.Phone != "+97*****000" || d.Email != "t***@gmail.com" {
+		t.Fatalf("reader без scope видит PII: %+v", d)
+	}
+	if d := get(h, "rp"); d.Phone != "+9720000000" {
+		t.Fatalf("reader со scope pii не видит PII: %+v", d)
+	}
+	if d := get(h, "a"); d.Phone != "+9720000000" {
+		t.Fatalf("admin не видит PII: %+v", d)
+	}
+
+	if d := get(api.NewRouter(svc, api.Config{}), ""); d.Phone != "+9720000000" {
+		t.Fatalf("без аутентификации по умолчанию PII не маскируются: %+v", d)
+	}
+	if d := get(api.NewRouter(svc, api.Config{PII: api.PIIPolicy{MaskAnonymous: true}}), ""); d.Phone != "+97*****000" {
+		t.Fatalf("MaskAnonymous: %+v", d)
+	}
+}
@@ -0,0 +1,109 @@
+package test
+
+import (
+	"awesomeProject/internal/api"
+	"awesomeProject/internal/auth"
+	"awesomeProject/internal/model"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func serveFrom(h http.Handler, method, target, ip, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.RemoteAddr = ip + ":40000"
+	if key != "" {
+		req.Header.Set(auth.HeaderAPIKey, key)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAPI_RateLimitPerClientAndRoute(t *testing.T) {
+	h := api.NewRouter(&stubService{}, api.Config{Limits: api.LimitConfig{Routes: map[string]api.RateLimit{
+		api.RouteRead: {Rate: 0.001, Burst: 2},
+	}}})
+	for i := range 2 {
+		if rec := serveFrom(h, http.MethodGet, "/api/v1/orders/x", "10.0.0.1", "", ""); rec.Code != http.StatusOK {
+			t.Fatalf("запрос %d в пределах всплеска: ожидали 200, получили %d", i, rec.Code)
+		}
+	}
+	rec := serveFrom(h, http.MethodGet, "/order/x", "10.0.0.1", "", "")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("сверх лимита: ожидали 429 с Retry-After, получили %d %v", rec.Code, rec.Header())
+	}
+	if rec := serveFrom(h, http.MethodGet, "/api/v1/orders/x", "10.0.0.2", "", ""); rec.Code != http.StatusOK {
+		t.Fatalf("другой IP не должен упираться в чужой лимит: %d", rec.Code)
+	}
+	if rec := serveFrom(h, http.MethodPost, "/api/v1/orders", "10.0.0.1", "", `{"order_uid":"a"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("лимит чтения не должен ограничивать запись: %d", rec.Code)
+	}
+}
+
+func TestAPI_RateLimitByAuthenticatedSubject(t *testing.T) {
+	keys, _ := auth.ParseAPIKeys("a:alice:reader,b:bob:reader")
+	h := api.NewRouter(&stubService{}, api.Config{Auth: keys, Limits: api.LimitConfig{Routes: map[string]api.RateLimit{
+		api.RouteRead: {Rate: 0.001, Burst: 1},
+	}}})
+	if rec := serveFrom(h, http.MethodGet, "/api/v1/orders/x", "10.0.0.1", "a", ""); rec.Code != http.StatusOK {
+		t.Fatalf("alice: %d", rec.Code)
+	}
+	if rec := serveFrom(h, http.MethodGet, "/api/v1/orders/x", "10.0.0.9", "a", ""); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("лимит alice не должен зависеть от IP: %d", rec.Code)
+	}
+	if rec := serveFrom(h, http.MethodGet, "/api/v1/orders/x", "10.0.0.1", "b", ""); rec.Code != http.StatusOK {
+		t.Fatalf("bob с того же IP: %d", rec.Code)
+	}
+}
+
+func TestAPI_RateLimitPerIPBeforeAuth(t *testing.T) {
+	keys, _ := auth.ParseAPIKeys("a:alice:reader")
+	h := api.NewRouter(&stubService{}, api.Config{Auth: keys, Limits: api.LimitConfig{
+		PerIP: api.RateLimit{Rate: 0.001, Burst: 2},
+	}})
+	for i, key := range []string{"bogus-1", "bogus-2"} {
+		if rec := serveFrom(h, http.MethodGet, "/api/v1/orders/x", "10.0.0.1", key, ""); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("попытка %d: ожидали 401, получили %d", i, rec.Code)
+		}
+	}
+	if rec := serveFrom(h, http.MethodGet, "/api/v1/orders/x", "10.0.0.1", "a", ""); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("перебор ключей должен исчерпывать лимит IP до аутентификации: %d", rec.Code)
+	}
+	if rec := serveFrom(h, http.MethodGet, "/api/v1/orders/x", "10.0.0.2", "a", ""); rec.Code != http.StatusOK {
+		t.Fatalf("другой IP не должен упираться в чужой лимит: %d", rec.Code)
+	}
+}
+
+func TestAPI_LoadSheddingWhenSaturated(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	svc := &stubService{getFn: func(ctx context.Context, id string) (model.Order, error) {
+		started <- struct{}{}
+		<-release
+		return model.Order{OrderUID: id}, nil
+	}}
+	h := api.NewRouter(svc, api.Config{Limits: api.LimitConfig{MaxInFlight: 1, QueueTimeout: 10 * time.Millisecond}})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		serveFrom(h, http.MethodGet, "/api/v1/orders/slow", "10.0.0.1", "", "")
+	}()
+	<-started
+	rec := serveFrom(h, http.MethodGet, "/api/v1/orders/x", "10.0.0.2", "", "")
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "1" {
+		t.Fatalf("при занятом слоте ожидали 503 с Retry-After, получили %d %v", rec.Code, rec.Header())
+	}
+	close(release)
+	wg.Wait()
+	svc.getFn = nil
+	if rec := serveFrom(h, http.MethodGet, "/api/v1/orders/x", "10.0.0.2", "", ""); rec.Code != http.StatusOK {
+		t.Fatalf("после освобождения слота: %d", rec.Code)
+	}
+}
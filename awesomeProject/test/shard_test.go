@@ -0,0 +1,53 @@
+package test
+
+import (
+	"awesomeProject/internal/repository"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestShardMap(t *testing.T) {
+	t.Setenv("SHARD_TEST_PASSWORD", "secret")
+	path := filepath.Join(t.TempDir(), "shards.json")
+	data := `{"shards": {"s1": "host=pg1 password=${SHARD_TEST_PASSWORD}", "s0": "host=pg0"},
+		"keys": {"0": "s0", "5": "s1"}, "default": "s0"}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	m, err := repository.LoadShardMap(path)
+	if err != nil {
+		t.Fatalf("LoadShardMap: %v", err)
+	}
+	if m.Shards["s1"] != "host=pg1 password=secret" {
+		t.Fatalf("переменные окружения в строке подключения не подставлены: %q", m.Shards["s1"])
+	}
+	if got := m.Names(); len(got) != 2 || got[0] != "s0" || got[1] != "s1" {
+		t.Fatalf("Names: %v", got)
+	}
+	for key, want := range map[string]string{"0": "s0", "5": "s1", "7": "s0", "": "s0"} {
+		if got := m.ShardFor(key); got != want {
+			t.Fatalf("ShardFor(%q) = %s, ожидали %s", key, got, want)
+		}
+	}
+
+	bad := []struct {
+		name string
+		m    repository.ShardMap
+		want string
+	}{
+		{"без шардов", repository.ShardMap{Default: "s0"}, "no shards"},
+		{"неизвестный default", repository.ShardMap{Shards: map[string]string{"s0": ""}, Default: "s9"}, "default"},
+		{"ключ на неизвестный шард", repository.ShardMap{Shards: map[string]string{"s0": ""}, Default: "s0",
+			Keys: map[string]string{"1": "s2"}}, `"s2"`},
+	}
+	for _, tc := range bad {
+		if err := tc.m.Validate(); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("%s: ожидали ошибку с %q, получили %v", tc.name, tc.want, err)
+		}
+	}
+	if _, err := repository.NewSharded(m, map[string]*repository.Repository{"s0": repository.NewRepository(nil)}); err == nil {
+		t.Fatalf("NewSharded без репозитория для s1 должен вернуть ошибку")
+	}
+}
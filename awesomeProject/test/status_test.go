@@ -0,0 +1,152 @@
+package test
+
+import (
+	"awesomeProject/internal/api"
+	"awesomeProject/internal/auth"
+	"awesomeProject/internal/model"
+	"awesomeProject/internal/repository"
+	"awesomeProject/internal/service"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOrderStatus_Transitions(t *testing.T) {
+	cases := []struct {
+		from, to model.OrderStatus
+		ok       bool
+	}{
+		{model.StatusCreated, model.StatusPaid, true},
+		{model.StatusCreated, model.StatusCancelled, true},
+		{model.StatusCreated, model.StatusShipped, false},
+		{model.StatusPaid, model.StatusAssembled, true},
+		{model.StatusAssembled, model.StatusShipped, true},
+		{model.StatusShipped, model.StatusDelivered, true},
+		{model.StatusShipped, model.StatusCancelled, false},
+		{model.StatusDelivered, model.StatusReturned, true},
+		{model.StatusPaid, model.StatusPaid, false},
+		{model.StatusCancelled, model.StatusPaid, false},
+		{model.StatusReturned, model.StatusDelivered, false},
+		{"", model.StatusCreated, false},
+	}
+	for _, tc := range cases {
+		if got := tc.from.CanTransitionTo(tc.to); got != tc.ok {
+			t.Fatalf("%q -> %q: получили %v, ожидали %v", tc.from, tc.to, got, tc.ok)
+		}
+	}
+	if !model.StatusCancelled.Final() || !model.StatusReturned.Final() || model.StatusDelivered.Final() {
+		t.Fatalf("конечными должны быть только cancelled и returned")
+	}
+	if model.OrderStatus("lost").Valid() {
+		t.Fatalf("неизвестный статус не должен считаться корректным")
+	}
+}
+
+func TestService_ChangeStatus(t *testing.T) {
+	var calls int
+	repo := &mockRepo{statusFn: func(ctx context.Context, c model.StatusChange) (model.StatusChange, error) {
+		calls++
+		if c.To == model.StatusShipped {
+			return c, fmt.Errorf("%w: created -> shipped", model.ErrInvalidTransition)
+		}
+		c.From = model.StatusCreated
+		return c, nil
+	}}
+	svc := service.NewService(repo, newMockCache(), discard)
+	ctx := context.Background()
+
+	if _, err := svc.ChangeStatus(ctx, model.StatusChange{OrderUID: "o1", To: "lost"}); !errors.Is(err, service.ErrInvalidOrder) {
+		t.Fatalf("неизвестный статус: ожидали ErrInvalidOrder, получили %v", err)
+	}
+	if _, err := svc.ChangeStatus(ctx, model.StatusChange{To: model.StatusPaid}); !errors.Is(err, service.ErrInvalidOrder) {
+		t.Fatalf("без order_uid: ожидали ErrInvalidOrder, получили %v", err)
+	}
+	if calls != 0 {
+		t.Fatalf("некорректный запрос не должен доходить до репозитория")
+	}
+	_, err := svc.ChangeStatus(ctx, model.StatusChange{OrderUID: "o1", To: model.StatusShipped})
+	if !errors.Is(err, model.ErrInvalidTransition) || errors.Is(err, service.ErrDependency) {
+		t.Fatalf("недопустимый переход не должен считаться ошибкой хранилища: %v", err)
+	}
+	got, err := svc.ChangeStatus(ctx, model.StatusChange{OrderUID: "o1", To: model.StatusPaid})
+	if err != nil || got.From != model.StatusCreated || got.To != model.StatusPaid {
+		t.Fatalf("ChangeStatus: %+v, %v", got, err)
+	}
+}
+
+func TestAPI_OrderStatus(t *testing.T) {
+	var (
+		got    model.StatusChange
+		source string
+	)
+	svc := &stubService{statusFn: func(ctx context.Context, c model.StatusChange) (model.StatusChange, error) {
+		got, source = c, repository.SourceFrom(ctx)
+		if c.To == model.StatusDelivered {
+			return c, fmt.Errorf("%w: created -> delivered", model.ErrInvalidTransition)
+		}
+		if c.To == "lost" {
+			return c, &service.ValidationError{Field: "status", Reason: `unknown status "lost"`}
+		}
+		c.From = model.StatusCreated
+		return c, nil
+	}}
+	h := api.NewRouter(svc, api.Config{})
+
+	rec := serve(h, http.MethodPost, "/api/v1/orders/o1/status", `{"status":"paid","reason":"card"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST status: код %d, тело %s", rec.Code, rec.Body)
+	}
+	if got.OrderUID != "o1" || got.To != model.StatusPaid || got.Reason != "card" || source != "http:ip:192.0.2.1" {
+		t.Fatalf("в сервис передана смена %+v от %q", got, source)
+	}
+	var resp model.StatusChange
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.From != model.StatusCreated {
+		t.Fatalf("ответ %s: %v", rec.Body, err)
+	}
+
+	rec = serve(h, http.MethodPost, "/api/v1/orders/o1/status", `{"status":"delivered"}`)
+	var apiErr api.ErrorResponse
+	if rec.Code != http.StatusConflict || json.Unmarshal(rec.Body.Bytes(), &apiErr) != nil || apiErr.Code != "invalid_transition" {
+		t.Fatalf("недопустимый переход: код %d, тело %s", rec.Code, rec.Body)
+	}
+	if rec := serve(h, http.MethodPost, "/api/v1/orders/o1/status", `{"status":"lost"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("неизвестный статус: код %d", rec.Code)
+	}
+
+	rec = serve(h, http.MethodGet, "/api/v1/orders/o1/status", "")
+	var hist model.StatusHistory
+	if rec.Code != http.StatusOK || json.Unmarshal(rec.Body.Bytes(), &hist) != nil || hist.Status != model.StatusCreated {
+		t.Fatalf("GET status: код %d, тело %s", rec.Code, rec.Body)
+	}
+	if rec := serve(h, http.MethodPut, "/api/v1/orders/o1/status", "{}"); rec.Code != http.StatusMethodNotAllowed ||
+		rec.Header().Get("Allow") != "GET, HEAD, POST" {
+		t.Fatalf("PUT status: код %d, Allow %q", rec.Code, rec.Header().Get("Allow"))
+	}
+}
+
+func TestAPI_OrderStatus_SourceIsCaller(t *testing.T) {
+	keys, _ := auth.ParseAPIKeys("w:warehouse:writer,r:dash:reader")
+	var source string
+	svc := &stubService{statusFn: func(ctx context.Context, c model.StatusChange) (model.StatusChange, error) {
+		source = repository.SourceFrom(ctx)
+		return c, nil
+	}}
+	h := api.NewRouter(svc, api.Config{Auth: keys})
+	for key, want := range map[string]int{"r": http.StatusForbidden, "w": http.StatusOK} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/orders/o1/status", strings.NewReader(`{"status":"assembled"}`))
+		req.Header.Set(auth.HeaderAPIKey, key)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Fatalf("ключ %q: ожидали %d, получили %d (%s)", key, want, rec.Code, rec.Body)
+		}
+	}
+	if source != "http:sub:warehouse" {
+		t.Fatalf("источник смены статуса: %q", source)
+	}
+}